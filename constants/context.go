@@ -0,0 +1,16 @@
+package constants
+
+// Keys of the values built-in middlewares share through IContext.Set/Get.
+const (
+	ContextKeyLogger    = "takibi.logger"
+	ContextKeyRequestID = "takibi.requestId"
+	ContextKeyAuthUser  = "takibi.authUser"
+	ContextKeyCSRFToken = "takibi.csrfToken"
+	ContextKeyBodyLimit = "takibi.bodyLimit"
+	ContextKeyCacheTags = "takibi.cacheTags"
+	ContextKeyCSPNonce  = "takibi.cspNonce"
+	ContextKeySession   = "takibi.session"
+)
+
+// HeaderRequestID is the default header carrying the request ID.
+const HeaderRequestID = "X-Request-Id"
@@ -9,4 +9,11 @@ const (
 	// MinSignedCookieSecretLen is the minimum required byte length for HMAC signing secrets.
 	// gorilla/securecookie recommends 32 or 64 bytes for the hash key.
 	MinSignedCookieSecretLen = 32
+
+	// CookieChunkSize is the longest value a chunked SetCookie writes to one
+	// cookie. Browsers cap a cookie at about 4096 bytes including its name
+	// and attributes, so longer values are split across name.0, name.1, ...
+	CookieChunkSize = 3800
+	// MaxCookieChunks bounds how many cookies one value may span.
+	MaxCookieChunks = 5
 )
@@ -3,9 +3,12 @@ package takibi
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"slices"
+	"strings"
 
 	"github.com/poteto0/takibi/constants"
 	"github.com/poteto0/takibi/interfaces"
@@ -13,14 +16,31 @@ import (
 )
 
 type contextOption struct {
-	maxBodyBytes int64
+	maxBodyBytes        int64
+	trustedProxies      []netip.Prefix
+	trustCFConnectingIP bool
 }
 
 func toContextOption(opt *interfaces.TakibiOption) contextOption {
-	if opt == nil || opt.MaxBodyBytes == 0 {
-		return contextOption{maxBodyBytes: constants.DefaultMaxBodyBytes}
+	co := contextOption{maxBodyBytes: constants.DefaultMaxBodyBytes}
+	if opt == nil {
+		return co
+	}
+	if opt.MaxBodyBytes != 0 {
+		co.maxBodyBytes = opt.MaxBodyBytes
+	}
+	// entries are validated by NewWithOption
+	co.trustedProxies, _ = thttp.ParseTrustedProxies(opt.TrustedProxies)
+	co.trustCFConnectingIP = opt.TrustCFConnectingIP
+	return co
+}
+
+func (co contextOption) requestOption() *thttp.RequestOption {
+	return &thttp.RequestOption{
+		MaxBodyBytes:        co.maxBodyBytes,
+		TrustedProxies:      co.trustedProxies,
+		TrustCFConnectingIP: co.trustCFConnectingIP,
 	}
-	return contextOption{maxBodyBytes: opt.MaxBodyBytes}
 }
 
 type context[Bindings any] struct {
@@ -29,19 +49,21 @@ type context[Bindings any] struct {
 	response      http.ResponseWriter
 	statusCode    int
 	pathParams    map[string]string
-	maxBodyBytes  int64
+	routePath     string
+	option        contextOption
 	validatedData map[string]any
+	store         map[string]any
 }
 
 func NewContext[Bindings any](w http.ResponseWriter, r *http.Request, bindings *Bindings, opt *interfaces.TakibiOption) interfaces.IContext[Bindings] {
 	co := toContextOption(opt)
 	return &context[Bindings]{
-		env:          bindings,
-		request:      thttp.NewRequest(r, &thttp.RequestOption{MaxBodyBytes: co.maxBodyBytes}),
-		response:     w,
-		statusCode:   http.StatusOK,
-		pathParams:   make(map[string]string),
-		maxBodyBytes: co.maxBodyBytes,
+		env:        bindings,
+		request:    thttp.NewRequest(r, co.requestOption()),
+		response:   w,
+		statusCode: http.StatusOK,
+		pathParams: make(map[string]string),
+		option:     co,
 	}
 }
 
@@ -53,12 +75,51 @@ func (c *context[Bindings]) Response() http.ResponseWriter {
 	return c.response
 }
 
+func (c *context[Bindings]) SetResponse(w http.ResponseWriter) {
+	c.response = w
+}
+
+func (c *context[Bindings]) SetRequest(r *http.Request) {
+	c.request = thttp.NewRequest(r, c.option.requestOption())
+	c.request.SetParams(c.pathParams)
+}
+
+func (c *context[Bindings]) Clone() interfaces.IContext[Bindings] {
+	clone := &context[Bindings]{
+		env:           c.env,
+		response:      c.response,
+		statusCode:    c.statusCode,
+		pathParams:    maps.Clone(c.pathParams),
+		routePath:     c.routePath,
+		option:        c.option,
+		validatedData: maps.Clone(c.validatedData),
+		store:         maps.Clone(c.store),
+	}
+	clone.request = thttp.NewRequest(c.request.Raw(), c.option.requestOption())
+	clone.request.SetParams(clone.pathParams)
+	return clone
+}
+
 func (c *context[Bindings]) Reset(w http.ResponseWriter, r *http.Request) {
-	c.request = thttp.NewRequest(r, &thttp.RequestOption{MaxBodyBytes: c.maxBodyBytes})
+	c.request = thttp.NewRequest(r, c.option.requestOption())
 	c.response = w
 	c.statusCode = http.StatusOK
 	clear(c.pathParams)
+	c.routePath = ""
 	c.validatedData = nil
+	c.store = nil
+}
+
+func (c *context[Bindings]) Set(key string, value any) {
+	if c.store == nil {
+		c.store = make(map[string]any)
+	}
+	c.store[key] = value
+}
+
+func (c *context[Bindings]) Get(key string) (any, bool) {
+	v, ok := c.store[key]
+	return v, ok
 }
 
 func (c *context[Bindings]) SetValidated(target string, value any) {
@@ -127,13 +188,19 @@ func (c *context[Bindings]) Redirect(path string) error {
 		return err
 	}
 	parsed, err := url.Parse(path)
-	if err == nil && parsed.Host != "" {
+	if err == nil && parsed.Host != "" && !c.sameOrigin(parsed) {
 		return fmt.Errorf("redirect: absolute URLs are not allowed, use RedirectExternal")
 	}
 	http.Redirect(c.response, c.request.Raw(), path, http.StatusFound)
 	return nil
 }
 
+// sameOrigin reports whether u points at the origin the client addressed,
+// as resolved through trusted proxies.
+func (c *context[Bindings]) sameOrigin(u *url.URL) bool {
+	return u.Scheme == c.request.Scheme() && strings.EqualFold(u.Host, c.request.Host())
+}
+
 func (c *context[Bindings]) RedirectExternal(rawURL string, allowedHosts []string) error {
 	if err := c.checkResponse(); err != nil {
 		return err
@@ -187,4 +254,13 @@ func (c *context[Bindings]) ParamBy(key string) string {
 
 func (c *context[Bindings]) SetParam(params map[string]string) {
 	c.pathParams = params
+	c.request.SetParams(params)
+}
+
+func (c *context[Bindings]) RoutePath() string {
+	return c.routePath
+}
+
+func (c *context[Bindings]) SetRoutePath(path string) {
+	c.routePath = path
 }
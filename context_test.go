@@ -107,6 +107,18 @@ func TestContext_Response(t *testing.T) {
 			assert.JSONEq(t, `{"msg":"hello"}`, w.Body.String())
 		})
 
+		t.Run("same origin follows trusted proxy headers", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.2:4000"
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", "app.example.com")
+			w := httptest.NewRecorder()
+			ctx := NewContext[any](w, req, nil, &interfaces.TakibiOption{TrustedProxies: []string{"10.0.0.0/8"}})
+
+			assert.Nil(t, ctx.Redirect("https://app.example.com/next"))
+			assert.Error(t, ctx.Redirect("http://example.com/next"))
+		})
+
 		t.Run("returns error when response is nil", func(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			ctx := NewContext[any](nil, req, nil, nil)
@@ -138,6 +150,8 @@ func TestContext_Response(t *testing.T) {
 			{"relative path with query", "/redirect?foo=bar", false},
 			{"absolute URL rejected", "https://evil.example.com/steal", true},
 			{"protocol-relative URL rejected", "//evil.example.com", true},
+			{"same-origin absolute URL", "http://example.com/next", false},
+			{"other scheme rejected", "https://example.com/next", true},
 		}
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
@@ -333,3 +347,64 @@ func TestContext_Validated(t *testing.T) {
 		assert.False(t, ok)
 	})
 }
+
+func TestContext_Store(t *testing.T) {
+	t.Run("Set and Get round-trip", func(t *testing.T) {
+		ctx := NewContext[any](httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+
+		ctx.Set("user", "alice")
+
+		v, ok := ctx.Get("user")
+		assert.True(t, ok)
+		assert.Equal(t, "alice", v)
+		_, ok = ctx.Get("missing")
+		assert.False(t, ok)
+	})
+
+	t.Run("Reset clears the store, route path and replaced response", func(t *testing.T) {
+		ctx := NewContext[any](httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+		ctx.Set("user", "alice")
+		ctx.SetRoutePath("/users/:id")
+		ctx.SetResponse(httptest.NewRecorder())
+
+		w := httptest.NewRecorder()
+		ctx.Reset(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		_, ok := ctx.Get("user")
+		assert.False(t, ok)
+		assert.Equal(t, "", ctx.RoutePath())
+		assert.Equal(t, w, ctx.Response())
+	})
+}
+
+func TestContext_Clone(t *testing.T) {
+	ctx := NewContext[any](httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil), nil, nil)
+	ctx.SetParam(map[string]string{"id": "42"})
+	ctx.SetRoutePath("/users/:id")
+	ctx.Set("user", "alice")
+
+	clone := ctx.Clone()
+	clone.Set("user", "bob")
+	ctx.Reset(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, "42", clone.ParamBy("id"))
+	assert.Equal(t, "/users/:id", clone.RoutePath())
+	assert.Equal(t, "/users/42", clone.Req().Raw().URL.Path)
+	v, _ := clone.Get("user")
+	assert.Equal(t, "bob", v)
+	_, ok := ctx.Get("user")
+	assert.False(t, ok)
+}
+
+func TestContext_RoutePath(t *testing.T) {
+	app := New[any](nil)
+	var route string
+	app.Get("/users/:id", func(c interfaces.IContext[any]) error {
+		route = c.RoutePath()
+		return nil
+	})
+
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	assert.Equal(t, "/users/:id", route)
+}
@@ -0,0 +1,28 @@
+package cookie
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieCodecCacheIsBounded(t *testing.T) {
+	// Arrange
+	first := strings.Repeat("s", 32)
+
+	// Act
+	codec := cookieCodec(first, "")
+	for i := range maxCachedCodecs * 2 {
+		cookieCodec(first+strconv.Itoa(i), "")
+	}
+
+	// Assert
+	assert.Equal(t, maxCachedCodecs, codecs.order.Len())
+	assert.Len(t, codecs.items, maxCachedCodecs)
+	_, ok := codecs.get(codecKey{secret: first})
+	assert.False(t, ok)
+	assert.NotSame(t, codec, cookieCodec(first, ""))
+	assert.Same(t, cookieCodec(first, ""), cookieCodec(first, ""))
+}
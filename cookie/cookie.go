@@ -1,7 +1,13 @@
 package cookie
 
 import (
+	"container/list"
+	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/securecookie"
 	"github.com/poteto0/takibi/constants"
@@ -15,16 +21,93 @@ var DefaultCookieOptions = &CookieOptions{
 	SameSite: http.SameSiteStrictMode,
 }
 
+// SetCookie sets the cookie name. With opts.Chunked, a value longer than
+// constants.CookieChunkSize is split across the cookies name.0, name.1, ...,
+// which GetCookie joins back; it returns false when the value would need
+// more than constants.MaxCookieChunks of them.
 func SetCookie[T any](ctx interfaces.IContext[T], name, value string, opts *CookieOptions) bool {
-	cookie := &http.Cookie{
-		Name:  name,
-		Value: value,
+	options := opts
+	if options == nil {
+		options = DefaultCookieOptions
+	}
+
+	if !options.Chunked {
+		writeCookie(ctx, name, value, options)
+		return true
+	}
+	if len(value) <= constants.CookieChunkSize {
+		writeCookie(ctx, name, value, options)
+		// a previous, longer value may have left chunks behind
+		deleteChunks(ctx, name, 0, options)
+		return true
 	}
 
+	n := (len(value) + constants.CookieChunkSize - 1) / constants.CookieChunkSize
+	if n > constants.MaxCookieChunks {
+		return false
+	}
+	for i := range n {
+		chunk := value[i*constants.CookieChunkSize : min((i+1)*constants.CookieChunkSize, len(value))]
+		writeCookie(ctx, chunkName(name, i), chunk, options)
+	}
+	deleteChunks(ctx, name, n, options)
+	if _, err := ctx.Req().Raw().Cookie(cookieName(name, options)); err == nil {
+		writeCookie(ctx, name, "", expired(options))
+	}
+	return true
+}
+
+// GetCookie returns the cookie name. With opts.Chunked, it joins the
+// chunks SetCookie split the value across.
+func GetCookie[T any](ctx interfaces.IContext[T], name string, opts *CookieOptions) (*http.Cookie, bool) {
+	r := ctx.Req().Raw()
+	if c, err := r.Cookie(cookieName(name, opts)); err == nil {
+		return c, true
+	}
+	if opts == nil || !opts.Chunked {
+		return nil, false
+	}
+
+	var joined *http.Cookie
+	var value strings.Builder
+	for i := range constants.MaxCookieChunks {
+		c, err := r.Cookie(cookieName(chunkName(name, i), opts))
+		if err != nil {
+			break
+		}
+		if joined == nil {
+			joinedCookie := *c
+			joinedCookie.Name = cookieName(name, opts)
+			joined = &joinedCookie
+		}
+		value.WriteString(c.Value)
+	}
+	if joined == nil {
+		return nil, false
+	}
+	joined.Value = value.String()
+	return joined, true
+}
+
+// DeleteCookie expires the cookie name and, with opts.Chunked, any chunks
+// of it. opts must carry the Path, Domain, Prefix and Partitioned the
+// cookie was set with, or the browser keeps it.
+func DeleteCookie[T any](ctx interfaces.IContext[T], name string, opts *CookieOptions) {
 	options := opts
 	if options == nil {
 		options = DefaultCookieOptions
 	}
+	writeCookie(ctx, name, "", expired(options))
+	if options.Chunked {
+		deleteChunks(ctx, name, 0, options)
+	}
+}
+
+func writeCookie[T any](ctx interfaces.IContext[T], name, value string, options *CookieOptions) {
+	cookie := &http.Cookie{
+		Name:  name,
+		Value: value,
+	}
 
 	cookie.Path = options.Path
 	cookie.Domain = options.Domain
@@ -33,7 +116,12 @@ func SetCookie[T any](ctx interfaces.IContext[T], name, value string, opts *Cook
 	cookie.HttpOnly = options.HttpOnly
 	cookie.SameSite = options.SameSite
 	cookie.MaxAge = options.MaxAge
+	cookie.Partitioned = options.Partitioned
 
+	// browsers drop partitioned cookies that are not secure
+	if options.Partitioned {
+		cookie.Secure = true
+	}
 	if options.Prefix == constants.CookieSecurePrefixMode {
 		makeCookieSecure(cookie)
 	}
@@ -42,35 +130,131 @@ func SetCookie[T any](ctx interfaces.IContext[T], name, value string, opts *Cook
 	}
 
 	http.SetCookie(ctx.Response(), cookie)
-	return true
 }
 
-func GetCookie[T any](ctx interfaces.IContext[T], name string, opts *CookieOptions) (*http.Cookie, bool) {
+// deleteChunks expires the chunks of name from index from on that the
+// request carries.
+func deleteChunks[T any](ctx interfaces.IContext[T], name string, from int, options *CookieOptions) {
+	r := ctx.Req().Raw()
+	for i := from; i < constants.MaxCookieChunks; i++ {
+		if _, err := r.Cookie(cookieName(chunkName(name, i), options)); err != nil {
+			continue
+		}
+		writeCookie(ctx, chunkName(name, i), "", expired(options))
+	}
+}
+
+func expired(options *CookieOptions) *CookieOptions {
+	e := *options
+	e.MaxAge = -1
+	e.Expires = time.Time{}
+	return &e
+}
+
+func chunkName(name string, i int) string {
+	return name + "." + strconv.Itoa(i)
+}
+
+// cookieName returns name as sent by the browser, with the prefix of
+// opts.Prefix.
+func cookieName(name string, opts *CookieOptions) string {
 	if opts != nil && opts.Prefix == constants.CookieSecurePrefixMode {
-		name = constants.CookieSecurePrefix + name
+		return constants.CookieSecurePrefix + name
 	}
 	if opts != nil && opts.Prefix == constants.CookieHostPrefixMode {
-		name = constants.CookieHostPrefix + name
+		return constants.CookieHostPrefix + name
 	}
+	return name
+}
 
-	c, err := ctx.Req().Raw().Cookie(name)
-	if err != nil {
+// codecs caches securecookie codecs by key pair; a codec is safe for
+// concurrent use and costly to build (it derives the AES cipher). The
+// cache is least-recently-used and bounded, since per-request secrets
+// (e.g. from SecretFunc) would otherwise grow it forever.
+var codecs = &codecCache{
+	order: list.New(),
+	items: map[codecKey]*list.Element{},
+}
+
+// maxCachedCodecs bounds codecs.
+const maxCachedCodecs = 64
+
+type codecKey struct {
+	secret, blockKey string
+}
+
+type codecCache struct {
+	mu    sync.Mutex
+	order *list.List // front is most recently used
+	items map[codecKey]*list.Element
+}
+
+type codecEntry struct {
+	key   codecKey
+	codec *securecookie.SecureCookie
+}
+
+func (cc *codecCache) get(key codecKey) (*securecookie.SecureCookie, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	el, ok := cc.items[key]
+	if !ok {
 		return nil, false
 	}
-	return c, true
+	cc.order.MoveToFront(el)
+	return el.Value.(*codecEntry).codec, true
 }
 
-// newSignedCookieCodec returns a securecookie codec for the given secret, or
-// nil when the secret is shorter than MinSignedCookieSecretLen.
-func newSignedCookieCodec(secret string) *securecookie.SecureCookie {
+func (cc *codecCache) add(key codecKey, codec *securecookie.SecureCookie) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if el, ok := cc.items[key]; ok {
+		cc.order.MoveToFront(el)
+		return
+	}
+	cc.items[key] = cc.order.PushFront(&codecEntry{key: key, codec: codec})
+	for cc.order.Len() > maxCachedCodecs {
+		el := cc.order.Back()
+		cc.order.Remove(el)
+		delete(cc.items, el.Value.(*codecEntry).key)
+	}
+}
+
+// cookieCodec returns the cached codec signing with secret and, when
+// blockKey is set, encrypting with it. It returns nil when the secret is
+// shorter than MinSignedCookieSecretLen or blockKey is not a valid AES key.
+func cookieCodec(secret, blockKey string) *securecookie.SecureCookie {
 	if len(secret) < constants.MinSignedCookieSecretLen {
 		return nil
 	}
-	return securecookie.New([]byte(secret), nil)
+	switch len(blockKey) {
+	case 0, 16, 24, 32:
+	default:
+		return nil
+	}
+
+	key := codecKey{secret: secret, blockKey: blockKey}
+	if codec, ok := codecs.get(key); ok {
+		return codec
+	}
+	var block []byte
+	if blockKey != "" {
+		block = []byte(blockKey)
+	}
+	codec := securecookie.New([]byte(secret), block).
+		MaxLength(constants.CookieChunkSize * constants.MaxCookieChunks)
+	codecs.add(key, codec)
+	return codec
 }
 
-func SetSignedCookie[T any](ctx interfaces.IContext[T], name, value, secret string, opts *CookieOptions) bool {
-	s := newSignedCookieCodec(secret)
+// setCodedCookie encodes value with the first key of the ring.
+func setCodedCookie[T any](ctx interfaces.IContext[T], name, value string, keys []codecKey, opts *CookieOptions) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	s := cookieCodec(keys[0].secret, keys[0].blockKey)
 	if s == nil {
 		return false
 	}
@@ -81,24 +265,111 @@ func SetSignedCookie[T any](ctx interfaces.IContext[T], name, value, secret stri
 	return SetCookie(ctx, name, encoded, opts)
 }
 
-func GetSignedCookie[T any](ctx interfaces.IContext[T], name, secret string, opts *CookieOptions) (*http.Cookie, bool) {
-	s := newSignedCookieCodec(secret)
-	if s == nil {
-		return nil, false
-	}
+// getCodedCookie decodes the cookie with the first key of the ring that
+// accepts it.
+func getCodedCookie[T any](ctx interfaces.IContext[T], name string, keys []codecKey, opts *CookieOptions) (*http.Cookie, bool) {
 	c, ok := GetCookie(ctx, name, opts)
 	if !ok {
 		return nil, false
 	}
 
-	var value string
-	if err := s.Decode(name, c.Value, &value); err != nil {
-		return nil, false
+	for _, key := range keys {
+		s := cookieCodec(key.secret, key.blockKey)
+		if s == nil {
+			continue
+		}
+		var value string
+		if err := s.Decode(name, c.Value, &value); err != nil {
+			continue
+		}
+		decodedCookie := *c
+		decodedCookie.Value = value
+		return &decodedCookie, true
+	}
+	return nil, false
+}
+
+func signedKeys(secrets []string) []codecKey {
+	keys := make([]codecKey, len(secrets))
+	for i, secret := range secrets {
+		keys[i] = codecKey{secret: secret}
+	}
+	return keys
+}
+
+func encryptionKeys(ring []CookieKey) []codecKey {
+	keys := make([]codecKey, len(ring))
+	for i, k := range ring {
+		keys[i] = codecKey{secret: k.Secret, blockKey: k.BlockKey}
+	}
+	return keys
+}
+
+func SetSignedCookie[T any](ctx interfaces.IContext[T], name, value, secret string, opts *CookieOptions) bool {
+	return setCodedCookie(ctx, name, value, []codecKey{{secret: secret}}, opts)
+}
+
+func GetSignedCookie[T any](ctx interfaces.IContext[T], name, secret string, opts *CookieOptions) (*http.Cookie, bool) {
+	return getCodedCookie(ctx, name, []codecKey{{secret: secret}}, opts)
+}
+
+// SetSignedValue JSON-encodes value and sets it as a signed cookie.
+//
+//	cookie.SetSignedValue(ctx, "prefs", Prefs{Theme: "dark"}, secret, nil)
+func SetSignedValue[V any, T any](ctx interfaces.IContext[T], name string, value V, secret string, opts *CookieOptions) bool {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	return SetSignedCookie(ctx, name, string(b), secret, opts)
+}
+
+// GetSignedValue decodes a cookie set with SetSignedValue into a V. It
+// reports false when the cookie is missing, not validly signed, or does not
+// decode into V.
+//
+//	prefs, ok := cookie.GetSignedValue[Prefs](ctx, "prefs", secret, nil)
+func GetSignedValue[V any, T any](ctx interfaces.IContext[T], name, secret string, opts *CookieOptions) (V, bool) {
+	var value V
+	c, ok := GetSignedCookie(ctx, name, secret, opts)
+	if !ok || json.Unmarshal([]byte(c.Value), &value) != nil {
+		return value, false
 	}
+	return value, true
+}
+
+// SetSignedCookieWithKeys signs value with secrets[0]. List secrets newest
+// first: to rotate, prepend the new secret and drop the old one once the
+// cookies it signed have expired.
+func SetSignedCookieWithKeys[T any](ctx interfaces.IContext[T], name, value string, secrets []string, opts *CookieOptions) bool {
+	return setCodedCookie(ctx, name, value, signedKeys(secrets), opts)
+}
+
+// GetSignedCookieWithKeys accepts a cookie signed with any of secrets.
+func GetSignedCookieWithKeys[T any](ctx interfaces.IContext[T], name string, secrets []string, opts *CookieOptions) (*http.Cookie, bool) {
+	return getCodedCookie(ctx, name, signedKeys(secrets), opts)
+}
+
+// SetEncryptedCookie encrypts value with blockKey (AES-128, -192 or -256 for
+// a 16, 24 or 32 byte key) and signs it with secret, so clients can neither
+// read nor alter it. It returns false when either key is invalid.
+func SetEncryptedCookie[T any](ctx interfaces.IContext[T], name, value, secret, blockKey string, opts *CookieOptions) bool {
+	return setCodedCookie(ctx, name, value, []codecKey{{secret: secret, blockKey: blockKey}}, opts)
+}
+
+func GetEncryptedCookie[T any](ctx interfaces.IContext[T], name, secret, blockKey string, opts *CookieOptions) (*http.Cookie, bool) {
+	return getCodedCookie(ctx, name, []codecKey{{secret: secret, blockKey: blockKey}}, opts)
+}
+
+// SetEncryptedCookieWithKeys encrypts value with keys[0]; list keys newest
+// first, as for SetSignedCookieWithKeys.
+func SetEncryptedCookieWithKeys[T any](ctx interfaces.IContext[T], name, value string, keys []CookieKey, opts *CookieOptions) bool {
+	return setCodedCookie(ctx, name, value, encryptionKeys(keys), opts)
+}
 
-	decodedCookie := *c
-	decodedCookie.Value = value
-	return &decodedCookie, true
+// GetEncryptedCookieWithKeys accepts a cookie encrypted with any of keys.
+func GetEncryptedCookieWithKeys[T any](ctx interfaces.IContext[T], name string, keys []CookieKey, opts *CookieOptions) (*http.Cookie, bool) {
+	return getCodedCookie(ctx, name, encryptionKeys(keys), opts)
 }
 
 func GetCookies[T any](ctx interfaces.IContext[T]) []*http.Cookie {
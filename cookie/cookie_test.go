@@ -3,12 +3,14 @@ package cookie_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/poteto0/takibi"
 	"github.com/poteto0/takibi/constants"
 	"github.com/poteto0/takibi/cookie"
+	"github.com/poteto0/takibi/interfaces"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -322,3 +324,375 @@ func TestSignedCookie(t *testing.T) {
 		assert.Nil(t, c)
 	})
 }
+
+func TestEncryptedCookie(t *testing.T) {
+	secret := "secret-key-must-be-32-bytes-long!!"
+	blockKey := "0123456789abcdef"
+
+	t.Run("set and decrypt encrypted cookie", func(t *testing.T) {
+		// Arrange Set
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		ctx := takibi.NewContext[any](w, r, nil, nil)
+
+		// Act Set
+		ok := cookie.SetEncryptedCookie[any](ctx, "name", "takibi", secret, blockKey, nil)
+
+		// Assert Set
+		assert.True(t, ok)
+		cookies := w.Result().Cookies()
+		assert.Len(t, cookies, 1)
+		assert.NotContains(t, cookies[0].Value, "takibi")
+
+		// Arrange Get
+		reqWithCookie := httptest.NewRequest("GET", "/", nil)
+		reqWithCookie.AddCookie(cookies[0])
+		ctxWithCookie := takibi.NewContext[any](w, reqWithCookie, nil, nil)
+
+		// Act Get
+		c, ok := cookie.GetEncryptedCookie[any](ctxWithCookie, "name", secret, blockKey, nil)
+		_, signedOK := cookie.GetSignedCookie[any](ctxWithCookie, "name", secret, nil)
+
+		// Assert Get
+		assert.True(t, ok)
+		assert.Equal(t, "takibi", c.Value)
+		assert.False(t, signedOK)
+	})
+
+	t.Run("fail with wrong block key", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		ctx := takibi.NewContext[any](w, r, nil, nil)
+		cookie.SetEncryptedCookie[any](ctx, "name", "takibi", secret, blockKey, nil)
+
+		reqWithCookie := httptest.NewRequest("GET", "/", nil)
+		reqWithCookie.AddCookie(w.Result().Cookies()[0])
+		ctxWithCookie := takibi.NewContext[any](w, reqWithCookie, nil, nil)
+
+		// Act
+		c, ok := cookie.GetEncryptedCookie[any](ctxWithCookie, "name", secret, "fedcba9876543210", nil)
+
+		// Assert
+		assert.False(t, ok)
+		assert.Nil(t, c)
+	})
+
+	t.Run("fail when block key has invalid length", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		ctx := takibi.NewContext[any](w, r, nil, nil)
+
+		// Act
+		ok := cookie.SetEncryptedCookie[any](ctx, "name", "takibi", secret, "short", nil)
+
+		// Assert
+		assert.False(t, ok)
+		assert.Empty(t, w.Result().Cookies())
+	})
+}
+
+func TestCookieKeyRotation(t *testing.T) {
+	oldSecret := "old-secret-key-must-be-32-bytes!!"
+	newSecret := "new-secret-key-must-be-32-bytes!!"
+
+	setAndGet := func(set func(ctx interfaces.IContext[any]) bool, get func(ctx interfaces.IContext[any]) (*http.Cookie, bool)) (*http.Cookie, bool) {
+		w := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+		if !set(ctx) {
+			return nil, false
+		}
+		reqWithCookie := httptest.NewRequest("GET", "/", nil)
+		reqWithCookie.AddCookie(w.Result().Cookies()[0])
+		return get(takibi.NewContext[any](w, reqWithCookie, nil, nil))
+	}
+
+	t.Run("signed cookie from the old secret is accepted", func(t *testing.T) {
+		// Act
+		c, ok := setAndGet(
+			func(ctx interfaces.IContext[any]) bool {
+				return cookie.SetSignedCookie(ctx, "name", "takibi", oldSecret, nil)
+			},
+			func(ctx interfaces.IContext[any]) (*http.Cookie, bool) {
+				return cookie.GetSignedCookieWithKeys(ctx, "name", []string{newSecret, oldSecret}, nil)
+			},
+		)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, "takibi", c.Value)
+	})
+
+	t.Run("signed cookie is encoded with the newest secret", func(t *testing.T) {
+		// Act
+		c, ok := setAndGet(
+			func(ctx interfaces.IContext[any]) bool {
+				return cookie.SetSignedCookieWithKeys(ctx, "name", "takibi", []string{newSecret, oldSecret}, nil)
+			},
+			func(ctx interfaces.IContext[any]) (*http.Cookie, bool) {
+				return cookie.GetSignedCookie(ctx, "name", newSecret, nil)
+			},
+		)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, "takibi", c.Value)
+	})
+
+	t.Run("retired secret is rejected", func(t *testing.T) {
+		// Act
+		_, ok := setAndGet(
+			func(ctx interfaces.IContext[any]) bool {
+				return cookie.SetSignedCookie(ctx, "name", "takibi", oldSecret, nil)
+			},
+			func(ctx interfaces.IContext[any]) (*http.Cookie, bool) {
+				return cookie.GetSignedCookieWithKeys(ctx, "name", []string{newSecret}, nil)
+			},
+		)
+
+		// Assert
+		assert.False(t, ok)
+	})
+
+	t.Run("encrypted cookie from the old key is accepted", func(t *testing.T) {
+		// Arrange
+		keys := []cookie.CookieKey{
+			{Secret: newSecret, BlockKey: "fedcba9876543210"},
+			{Secret: oldSecret, BlockKey: "0123456789abcdef"},
+		}
+
+		// Act
+		c, ok := setAndGet(
+			func(ctx interfaces.IContext[any]) bool {
+				return cookie.SetEncryptedCookie(ctx, "name", "takibi", oldSecret, "0123456789abcdef", nil)
+			},
+			func(ctx interfaces.IContext[any]) (*http.Cookie, bool) {
+				return cookie.GetEncryptedCookieWithKeys(ctx, "name", keys, nil)
+			},
+		)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, "takibi", c.Value)
+	})
+
+	t.Run("empty key ring sets nothing", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+
+		// Act
+		ok := cookie.SetSignedCookieWithKeys[any](ctx, "name", "takibi", nil, nil)
+
+		// Assert
+		assert.False(t, ok)
+		assert.Empty(t, w.Result().Cookies())
+	})
+}
+
+type prefs struct {
+	Theme string `json:"theme"`
+	Size  int    `json:"size"`
+}
+
+func TestSignedValue(t *testing.T) {
+	secret := "secret-key-must-be-32-bytes-long!!"
+
+	t.Run("set and get typed value", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+
+		// Act
+		ok := cookie.SetSignedValue(ctx, "prefs", prefs{Theme: "dark", Size: 14}, secret, nil)
+		reqWithCookie := httptest.NewRequest("GET", "/", nil)
+		reqWithCookie.AddCookie(w.Result().Cookies()[0])
+		got, gotOK := cookie.GetSignedValue[prefs](takibi.NewContext[any](w, reqWithCookie, nil, nil), "prefs", secret, nil)
+
+		// Assert
+		assert.True(t, ok)
+		assert.True(t, gotOK)
+		assert.Equal(t, prefs{Theme: "dark", Size: 14}, got)
+	})
+
+	t.Run("fail when value does not decode", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+		cookie.SetSignedCookie(ctx, "prefs", "not json", secret, nil)
+		reqWithCookie := httptest.NewRequest("GET", "/", nil)
+		reqWithCookie.AddCookie(w.Result().Cookies()[0])
+
+		// Act
+		_, ok := cookie.GetSignedValue[prefs](takibi.NewContext[any](w, reqWithCookie, nil, nil), "prefs", secret, nil)
+
+		// Assert
+		assert.False(t, ok)
+	})
+}
+
+func TestDeleteCookie(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *cookie.CookieOptions
+		wantName string
+		wantPath string
+	}{
+		{"default options", nil, "name", "/"},
+		{"secure prefix", &cookie.CookieOptions{Path: "/app", Prefix: constants.CookieSecurePrefixMode}, "__Secure-name", "/app"},
+		{"host prefix", &cookie.CookieOptions{Path: "/app", Domain: "example.com", Prefix: constants.CookieHostPrefixMode}, "__Host-name", "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			w := httptest.NewRecorder()
+			ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+
+			// Act
+			cookie.DeleteCookie(ctx, "name", tt.opts)
+
+			// Assert
+			cookies := w.Result().Cookies()
+			assertCookie(t, cookies, tt.wantName, "")
+			assert.Equal(t, -1, cookies[0].MaxAge)
+			assert.Equal(t, tt.wantPath, cookies[0].Path)
+			assert.Empty(t, cookies[0].Domain)
+			assert.True(t, cookies[0].Secure)
+		})
+	}
+}
+
+func TestChunkedCookie(t *testing.T) {
+	long := strings.Repeat("a", constants.CookieChunkSize*2+10)
+	chunked := &cookie.CookieOptions{Path: "/", Chunked: true}
+
+	t.Run("long value stays one cookie without Chunked", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+
+		// Act
+		ok := cookie.SetCookie(ctx, "name", long, nil)
+
+		// Assert
+		assert.True(t, ok)
+		cookies := w.Result().Cookies()
+		assert.Len(t, cookies, 1)
+		assert.Equal(t, "name", cookies[0].Name)
+		assert.Equal(t, long, cookies[0].Value)
+	})
+
+	t.Run("long value is split and joined", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+
+		// Act
+		ok := cookie.SetCookie(ctx, "name", long, chunked)
+		cookies := w.Result().Cookies()
+		reqWithCookie := httptest.NewRequest("GET", "/", nil)
+		for _, c := range cookies {
+			reqWithCookie.AddCookie(c)
+		}
+		c, gotOK := cookie.GetCookie(takibi.NewContext[any](w, reqWithCookie, nil, nil), "name", chunked)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Len(t, cookies, 3)
+		assert.Equal(t, "name.0", cookies[0].Name)
+		assert.Len(t, cookies[2].Value, 10)
+		assert.True(t, gotOK)
+		assert.Equal(t, "name", c.Name)
+		assert.Equal(t, long, c.Value)
+	})
+
+	t.Run("signed value is chunked", func(t *testing.T) {
+		// Arrange
+		secret := "secret-key-must-be-32-bytes-long!!"
+		w := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+
+		// Act
+		ok := cookie.SetSignedCookie(ctx, "name", long, secret, chunked)
+		reqWithCookie := httptest.NewRequest("GET", "/", nil)
+		for _, c := range w.Result().Cookies() {
+			reqWithCookie.AddCookie(c)
+		}
+		c, gotOK := cookie.GetSignedCookie(takibi.NewContext[any](w, reqWithCookie, nil, nil), "name", secret, chunked)
+
+		// Assert
+		assert.True(t, ok)
+		assert.True(t, gotOK)
+		assert.Equal(t, long, c.Value)
+	})
+
+	t.Run("shorter value expires stale chunks", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: "name.0", Value: "a"})
+		r.AddCookie(&http.Cookie{Name: "name.1", Value: "b"})
+		ctx := takibi.NewContext[any](w, r, nil, nil)
+
+		// Act
+		ok := cookie.SetCookie(ctx, "name", "short", chunked)
+
+		// Assert
+		assert.True(t, ok)
+		cookies := w.Result().Cookies()
+		assert.Len(t, cookies, 3)
+		assert.Equal(t, "short", cookies[0].Value)
+		assert.Equal(t, "name.0", cookies[1].Name)
+		assert.Equal(t, -1, cookies[1].MaxAge)
+		assert.Equal(t, "name.1", cookies[2].Name)
+	})
+
+	t.Run("delete expires chunks", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: "__Host-name.0", Value: "a"})
+		ctx := takibi.NewContext[any](w, r, nil, nil)
+
+		// Act
+		cookie.DeleteCookie(ctx, "name", &cookie.CookieOptions{Prefix: constants.CookieHostPrefixMode, Chunked: true})
+
+		// Assert
+		cookies := w.Result().Cookies()
+		assert.Len(t, cookies, 2)
+		assert.Equal(t, "__Host-name.0", cookies[1].Name)
+		assert.Equal(t, -1, cookies[1].MaxAge)
+	})
+
+	t.Run("fail when value needs too many chunks", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+
+		// Act
+		ok := cookie.SetCookie(ctx, "name", strings.Repeat("a", constants.CookieChunkSize*constants.MaxCookieChunks+1), chunked)
+
+		// Assert
+		assert.False(t, ok)
+		assert.Empty(t, w.Result().Cookies())
+	})
+}
+
+func TestPartitionedCookie(t *testing.T) {
+	// Arrange
+	w := httptest.NewRecorder()
+	ctx := takibi.NewContext[any](w, httptest.NewRequest("GET", "/", nil), nil, nil)
+
+	// Act
+	cookie.SetCookie(ctx, "embed", "1", &cookie.CookieOptions{
+		Path:        "/",
+		SameSite:    http.SameSiteNoneMode,
+		Partitioned: true,
+	})
+
+	// Assert
+	header := w.Header().Get("Set-Cookie")
+	assert.Contains(t, header, "Partitioned")
+	assert.Contains(t, header, "Secure")
+}
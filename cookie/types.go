@@ -14,4 +14,20 @@ type CookieOptions struct {
 	SameSite http.SameSite
 	MaxAge   int
 	Prefix   string // "secure" | "host" | ""
+	// Partitioned sets the CHIPS attribute, keying the cookie to the
+	// top-level site when it is set from an embedded cross-site frame. It
+	// implies Secure.
+	Partitioned bool
+	// Chunked lets SetCookie split a value longer than
+	// constants.CookieChunkSize across several cookies, and GetCookie and
+	// DeleteCookie handle them as one.
+	Chunked bool
+}
+
+// CookieKey is one entry of an encrypted cookie key ring.
+type CookieKey struct {
+	// Secret signs the cookie; at least MinSignedCookieSecretLen bytes.
+	Secret string
+	// BlockKey encrypts the cookie; 16, 24 or 32 bytes.
+	BlockKey string
 }
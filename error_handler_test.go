@@ -2,6 +2,7 @@ package takibi_test
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -27,6 +28,25 @@ func TestDefaultErrorHandler(t *testing.T) {
 	assert.Equal(t, "Internal Server Error", w.Body.String())
 }
 
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "teapot" }
+func (teapotError) StatusCode() int { return http.StatusTeapot }
+
+func TestDefaultErrorHandler_StatusError(t *testing.T) {
+	app := takibi.New[Bindings](nil)
+	app.Get("/teapot", func(ctx interfaces.IContext[Bindings]) error {
+		return fmt.Errorf("brewing: %w", teapotError{})
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/teapot", nil)
+	app.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "I'm a teapot", w.Body.String())
+}
+
 func TestCustomErrorHandler(t *testing.T) {
 	app := takibi.New[Bindings](nil)
 
@@ -1,6 +1,28 @@
 package interfaces
 
+import (
+	"errors"
+	"net/http"
+)
+
 type HandlerFunc[Bindings any] = func(ctx IContext[Bindings]) error
 type MiddlewareFunc[Bindings any] func(c IContext[Bindings], next HandlerFunc[Bindings]) error
 type ErrorHandlerFunc[Bindings any] func(ctx IContext[Bindings], err error) error
 type BlowErrorHandlerFunc[Bindings any] func(c IContext[Bindings], err error)
+
+// IStatusError is implemented by errors that carry the HTTP status the default
+// error handler answers with, e.g. 429 from middlewares.RateLimit.
+type IStatusError interface {
+	error
+	StatusCode() int
+}
+
+// StatusCodeOf returns the status the default error handler answers err
+// with: the one carried by an IStatusError in its chain, or 500.
+func StatusCodeOf(err error) int {
+	var se IStatusError
+	if errors.As(err, &se) {
+		return se.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
@@ -8,7 +8,19 @@ type IContext[Bindings any] interface {
 	Env() *Bindings
 	Req() IRequest
 	Response() http.ResponseWriter
+	// SetResponse replaces the response writer for the rest of the request,
+	// so middlewares can wrap it (e.g. to record the status or buffer output)
+	SetResponse(w http.ResponseWriter)
+	// SetRequest replaces the request for the rest of the request, keeping
+	// the matched params, so middlewares can derive its context
+	// (r.WithContext), e.g. to carry values Render passes to components
+	SetRequest(r *http.Request)
 	Reset(w http.ResponseWriter, r *http.Request)
+	// Clone returns a copy of the context (request, params, route, store and
+	// validated data) for a goroutine that may outlive the request. The copy
+	// is never pooled, and values Set on either side are not seen by the
+	// other.
+	Clone() IContext[Bindings]
 
 	// Response
 	Status(code int) IContext[Bindings]
@@ -16,7 +28,9 @@ type IContext[Bindings any] interface {
 	Bytes(data []byte) error
 	Json(data any) error
 	// Redirect sends a 302 response to a relative path.
-	// Returns an error if url is an absolute URL or protocol-relative URL.
+	// Returns an error if url is an absolute URL or protocol-relative URL,
+	// unless it is an absolute URL on the request's own scheme and host
+	// (Req().Scheme() and Req().Host(), resolved through trusted proxies).
 	// Use RedirectExternal for redirecting to external hosts.
 	Redirect(path string) error
 
@@ -56,6 +70,16 @@ type IContext[Bindings any] interface {
 	ParamBy(key string) string
 	SetParam(params map[string]string)
 
+	// RoutePath returns the registered pattern of the matched route
+	// (e.g. "/users/:id"), or "" when no route matched
+	RoutePath() string
+	SetRoutePath(path string)
+
+	// Request-scoped store shared by middlewares and handlers,
+	// cleared on every request
+	Set(key string, value any)
+	Get(key string) (any, bool)
+
 	// Validated data store — keyed by target name (e.g. "form", "json", "query")
 	SetValidated(target string, value any)
 	Validated(target string) (any, bool)
@@ -2,6 +2,9 @@ package interfaces
 
 type INode[Bindings any] interface {
 	Handler() HandlerFunc[Bindings]
+	// Path returns the pattern the handler was registered with,
+	// or "" for nodes without a handler
+	Path() string
 	ComposedHandler() HandlerFunc[Bindings]
 	Add(path string, handler HandlerFunc[Bindings]) (err error)
 	// Find locates the node for path. On a matched route, callers use
@@ -13,7 +13,20 @@ type IRequest interface {
 	// and normalized to lowercase, or "" when missing/unparsable
 	MediaType() string
 
+	/* Client */
+	// RealIP returns the client address, resolved through the forwarding
+	// headers of TakibiOption.TrustedProxies (CF-Connecting-IP on Workers)
+	RealIP() string
+	// Scheme returns "https" or "http" as seen by the client
+	Scheme() string
+	// Host returns the host the client addressed
+	Host() string
+
 	/* Parameters */
+	// MaxBodyBytes returns the body size limit of TakibiOption.MaxBodyBytes
+	// applied by Unmarshall and form parsing
+	MaxBodyBytes() int64
+
 	// get request body as map
 	Json() (map[string]any, error)
 
@@ -23,6 +36,18 @@ type IRequest interface {
 	// UnmarshallForm binds form values (urlencoded / multipart) into dest by `form` tag
 	UnmarshallForm(dest any) error
 
+	// BindAll fills dest from the body (`json` / `form` tags) and from the
+	// `param`, `query`, `header` and `cookie` tags in one pass.
+	// failures are returned as *thttp.BindError carrying the source and field
+	BindAll(dest any) error
+
+	// BindFrom fills dest from a single source by its tag:
+	// thttp.BindSourceParam, BindSourceQuery, BindSourceHeader or BindSourceCookie
+	BindFrom(source string, dest any) error
+
+	// SetParams records the matched path parameters used by `param` tags
+	SetParams(params map[string]string)
+
 	// get query parameters as map
 	Queries() map[string][]string
 
@@ -31,6 +31,12 @@ type ITakibi[Bindings any] interface {
 
 	Use(path string, middleware ...MiddlewareFunc[Bindings]) error
 
+	// Pre registers middlewares run on every request before routing, so
+	// they may rewrite the method or path the router matches, e.g.
+	// middlewares.MethodOverride. Errors go to the OnError handler.
+	//	- ! Pre middlewares of a sub app mounted with Route are not run.
+	Pre(middleware ...MiddlewareFunc[Bindings])
+
 	// Route registers sub app
 	//
 	// EX:
@@ -8,6 +8,16 @@ type TakibiOption struct {
 	// MaxBodyBytes limits request body size decoded by Unmarshall.
 	// 0 uses the default (constants.DefaultMaxBodyBytes = 10 MiB).
 	MaxBodyBytes int64
+	// TrustedProxies lists the CIDRs ("10.0.0.0/8") or addresses of the
+	// reverse proxies in front of the app. Only requests arriving from them
+	// have their Forwarded and X-Forwarded-For/Proto/Host headers honored
+	// by Req().RealIP(), Scheme() and Host(). NewWithOption panics on an
+	// invalid entry.
+	TrustedProxies []string
+	// TrustCFConnectingIP makes RealIP() prefer the CF-Connecting-IP header
+	// of a trusted proxy. Enable it only when TrustedProxies are Cloudflare's
+	// ranges: any other proxy passes a client-sent value through unchanged.
+	TrustCFConnectingIP bool
 }
 
 var DefaultTakibiOption = TakibiOption{
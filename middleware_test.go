@@ -1,6 +1,7 @@
 package takibi
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -81,3 +82,58 @@ func TestMiddleware(t *testing.T) {
 		assert.Equal(t, expected, order)
 	})
 }
+
+func TestPreMiddleware(t *testing.T) {
+	var order []string
+	app := New[any](nil)
+
+	app.Pre(func(c interfaces.IContext[any], next interfaces.HandlerFunc[any]) error {
+		order = append(order, "pre")
+		if c.Req().Raw().URL.Path == "/old" {
+			r := c.Req().Raw().Clone(c.Req().Raw().Context())
+			r.URL.Path = "/new"
+			c.SetRequest(r)
+		}
+		if c.Req().Raw().URL.Path == "/denied" {
+			return errors.New("denied")
+		}
+		return next(c)
+	})
+	app.Use("*", func(c interfaces.IContext[any], next interfaces.HandlerFunc[any]) error {
+		order = append(order, "use")
+		return next(c)
+	})
+	app.Get("/new", func(c interfaces.IContext[any]) error {
+		order = append(order, "handler")
+		return c.Text(c.RoutePath())
+	})
+	app.OnError(func(c interfaces.IContext[any], err error) error {
+		return c.Status(http.StatusForbidden).Text(err.Error())
+	})
+
+	t.Run("runs before routing and may rewrite the path", func(t *testing.T) {
+		order = nil
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", nil))
+
+		assert.Equal(t, "/new", rec.Body.String())
+		assert.Equal(t, []string{"pre", "use", "handler"}, order)
+	})
+
+	t.Run("runs for unmatched routes", func(t *testing.T) {
+		order = nil
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, []string{"pre", "use"}, order)
+	})
+
+	t.Run("errors go to the error handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/denied", nil))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "denied", rec.Body.String())
+	})
+}
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// SecureCompare reports whether a and b are equal in constant time. Both are
+// hashed first so the comparison leaks neither content nor length; use it in
+// custom auth Validators.
+func SecureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// secureContains reports whether v equals any of candidates, comparing every
+// candidate so the time taken does not reveal which one matched.
+func secureContains(candidates []string, v string) bool {
+	found := false
+	for _, c := range candidates {
+		if SecureCompare(c, v) {
+			found = true
+		}
+	}
+	return found
+}
+
+// authCredentials returns the credentials of an Authorization header using
+// the given scheme (matched case-insensitively).
+func authCredentials(header, scheme string) (string, bool) {
+	prefix, rest, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(prefix, scheme) {
+		return "", false
+	}
+	rest = strings.TrimSpace(rest)
+	return rest, rest != ""
+}
+
+// unauthorized runs the configured handler, or answers a plain 401.
+func unauthorized[Bindings any](c interfaces.IContext[Bindings], handler interfaces.HandlerFunc[Bindings]) error {
+	if handler != nil {
+		return handler(c)
+	}
+	return c.Status(http.StatusUnauthorized).Text(http.StatusText(http.StatusUnauthorized))
+}
+
+// quoteRealm formats a realm auth-param, escaping quotes and backslashes.
+func quoteRealm(realm string) string {
+	return `realm="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(realm) + `"`
+}
@@ -0,0 +1,182 @@
+package middlewares_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+type authBindings struct {
+	Secret string
+}
+
+func authRoutes(app interfaces.ITakibi[authBindings]) {
+	app.Get("/", func(c interfaces.IContext[authBindings]) error {
+		user, _ := c.Get(constants.ContextKeyAuthUser)
+		name, _ := user.(string)
+		return c.Text("ok " + name)
+	})
+}
+
+func basic(user, password string) interfaces.CampOption {
+	return func(r *http.Request) { r.SetBasicAuth(user, password) }
+}
+
+func TestSecureCompare(t *testing.T) {
+	assert.True(t, middlewares.SecureCompare("secret", "secret"))
+	assert.False(t, middlewares.SecureCompare("secret", "secreT"))
+	assert.False(t, middlewares.SecureCompare("secret", "secret-longer"))
+}
+
+func TestBasicAuth(t *testing.T) {
+	app := newTestApp(&authBindings{Secret: "from-env"}, authRoutes, middlewares.BasicAuth(middlewares.BasicAuthConfig[authBindings]{
+		Realm: `Admin "area"`,
+		Users: map[string]string{"alice": "wonderland"},
+		Validator: func(c interfaces.IContext[authBindings], user, password string) (bool, error) {
+			if user == "broken" {
+				return false, errors.New("store down")
+			}
+			return user == "bob" && middlewares.SecureCompare(password, c.Env().Secret), nil
+		},
+	}))
+	recordErrors(app)
+
+	tests := []struct {
+		name   string
+		opts   []interfaces.CampOption
+		status int
+		body   string
+	}{
+		{"static user", []interfaces.CampOption{basic("alice", "wonderland")}, http.StatusOK, "ok alice"},
+		{"validator reads env", []interfaces.CampOption{basic("bob", "from-env")}, http.StatusOK, "ok bob"},
+		{"wrong password", []interfaces.CampOption{basic("alice", "nope")}, http.StatusUnauthorized, "Unauthorized"},
+		{"unknown user", []interfaces.CampOption{basic("mallory", "wonderland")}, http.StatusUnauthorized, "Unauthorized"},
+		{"missing header", nil, http.StatusUnauthorized, "Unauthorized"},
+		{"malformed header", []interfaces.CampOption{interfaces.Header("Authorization", "Basic !!!")}, http.StatusUnauthorized, "Unauthorized"},
+		{"validator error", []interfaces.CampOption{basic("broken", "x")}, http.StatusInternalServerError, "store down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := app.Camp(http.MethodGet, "/", tt.opts...)
+
+			assert.Equal(t, tt.status, resp.StatusCode())
+			body, _ := readBody(resp)
+			assert.Equal(t, tt.body, body)
+			if tt.status == http.StatusUnauthorized {
+				assert.Equal(t, `Basic realm="Admin \"area\"", charset="UTF-8"`, resp.Raw().Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	t.Run("requires Users or Validator", func(t *testing.T) {
+		assert.Panics(t, func() {
+			middlewares.BasicAuth(middlewares.BasicAuthConfig[authBindings]{})
+		})
+	})
+}
+
+func TestBearerAuth(t *testing.T) {
+	app := newTestApp(&authBindings{Secret: "from-env"}, authRoutes, middlewares.BearerAuth(middlewares.BearerAuthConfig[authBindings]{
+		Realm:  "api",
+		Tokens: []string{"t1", "t2"},
+		Validator: func(c interfaces.IContext[authBindings], token string) (bool, error) {
+			return middlewares.SecureCompare(token, c.Env().Secret), nil
+		},
+	}))
+
+	t.Run("accepts listed and validated tokens", func(t *testing.T) {
+		for _, token := range []string{"t2", "from-env"} {
+			resp := app.Camp(http.MethodGet, "/", interfaces.Header("Authorization", "bearer "+token))
+			assert.Equal(t, http.StatusOK, resp.StatusCode(), token)
+		}
+	})
+
+	t.Run("missing token gets a bare challenge", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/", interfaces.Header("Authorization", "Basic abc"))
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+		assert.Equal(t, `Bearer realm="api"`, resp.Raw().Header.Get("WWW-Authenticate"))
+	})
+
+	t.Run("rejected token is reported as invalid_token", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/", interfaces.Header("Authorization", "Bearer nope"))
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+		assert.Equal(t, `Bearer realm="api", error="invalid_token"`, resp.Raw().Header.Get("WWW-Authenticate"))
+	})
+
+	t.Run("custom unauthorized response", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: "from-env"}, authRoutes, middlewares.BearerAuth(middlewares.BearerAuthConfig[authBindings]{
+			Tokens: []string{"t1"},
+			Unauthorized: func(c interfaces.IContext[authBindings]) error {
+				return c.Status(http.StatusUnauthorized).Json(map[string]string{"error": "token required"})
+			},
+		}))
+
+		resp := app.Camp(http.MethodGet, "/")
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+		assert.Equal(t, "Bearer", resp.Raw().Header.Get("WWW-Authenticate"))
+		data, err := resp.Json()
+		assert.NoError(t, err)
+		assert.Equal(t, "token required", data["error"])
+	})
+}
+
+func TestKeyAuth(t *testing.T) {
+	app := newTestApp(&authBindings{Secret: "from-env"}, authRoutes, middlewares.KeyAuth(middlewares.KeyAuthConfig[authBindings]{
+		KeyLookup: "header:X-API-Key, query:api_key, cookie:api_key",
+		Keys:      []string{"k1"},
+		Validator: func(c interfaces.IContext[authBindings], key string) (bool, error) {
+			return middlewares.SecureCompare(key, c.Env().Secret), nil
+		},
+	}))
+
+	tests := []struct {
+		name   string
+		path   string
+		opts   []interfaces.CampOption
+		status int
+	}{
+		{"header", "/", []interfaces.CampOption{interfaces.Header("X-API-Key", "k1")}, http.StatusOK},
+		{"query", "/?api_key=from-env", nil, http.StatusOK},
+		{"cookie", "/", []interfaces.CampOption{interfaces.Header("Cookie", "api_key=k1")}, http.StatusOK},
+		{"header wins over query", "/?api_key=k1", []interfaces.CampOption{interfaces.Header("X-API-Key", "bad")}, http.StatusUnauthorized},
+		{"wrong key", "/?api_key=bad", nil, http.StatusUnauthorized},
+		{"missing key", "/", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := app.Camp(http.MethodGet, tt.path, tt.opts...)
+
+			assert.Equal(t, tt.status, resp.StatusCode())
+		})
+	}
+
+	t.Run("default lookup is the X-API-Key header", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: "from-env"}, authRoutes, middlewares.KeyAuth(middlewares.KeyAuthConfig[authBindings]{Keys: []string{"k1"}}))
+
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/", interfaces.Header("X-API-Key", "k1")).StatusCode())
+		assert.Equal(t, http.StatusUnauthorized, app.Camp(http.MethodGet, "/?X-API-Key=k1").StatusCode())
+	})
+
+	t.Run("invalid lookup panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			middlewares.KeyAuth(middlewares.KeyAuthConfig[authBindings]{KeyLookup: "form:key", Keys: []string{"k"}})
+		})
+		assert.Panics(t, func() {
+			middlewares.KeyAuth(middlewares.KeyAuthConfig[authBindings]{KeyLookup: "header"})
+		})
+	})
+}
+
+func readBody(resp interfaces.ICampResponse) (string, error) {
+	b, err := io.ReadAll(resp.Raw().Body)
+	return string(b), err
+}
@@ -0,0 +1,8 @@
+//go:build !wasm
+
+package middlewares
+
+// runInBackground runs task after the response without blocking it.
+func runInBackground(task func()) {
+	go task()
+}
@@ -0,0 +1,11 @@
+//go:build wasm
+
+package middlewares
+
+import "github.com/syumai/workers/cloudflare"
+
+// runInBackground runs task after the response without blocking it, kept
+// alive by the fetch event's waitUntil so the Worker is not torn down first.
+func runInBackground(task func()) {
+	cloudflare.WaitUntil(task)
+}
@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+type BasicAuthConfig[Bindings any] struct {
+	// Realm is sent in the WWW-Authenticate challenge; "" uses "Restricted".
+	Realm string
+	// Users maps user names to passwords, compared in constant time.
+	Users map[string]string
+	// Validator checks the credentials when Users is not enough, e.g. against
+	// secrets in c.Env(). A returned error goes to the error handler.
+	Validator func(c interfaces.IContext[Bindings], user, password string) (bool, error)
+	// Unauthorized writes the response for missing or wrong credentials after
+	// the challenge header is set; nil answers a plain 401.
+	Unauthorized interfaces.HandlerFunc[Bindings]
+}
+
+// BasicAuth guards the route with HTTP Basic authentication (RFC 7617). The
+// credentials pass when they match Users or the Validator accepts them; the
+// authenticated user name is stored under constants.ContextKeyAuthUser.
+// Otherwise a WWW-Authenticate challenge is sent with a 401.
+//
+//	app.Use("/admin/*", middlewares.BasicAuth(middlewares.BasicAuthConfig[Bindings]{
+//		Validator: func(c interfaces.IContext[Bindings], user, password string) (bool, error) {
+//			return middlewares.SecureCompare(password, c.Env().AdminPassword), nil
+//		},
+//	}))
+func BasicAuth[Bindings any](config BasicAuthConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := config
+	if cfg.Realm == "" {
+		cfg.Realm = "Restricted"
+	}
+	if cfg.Users == nil && cfg.Validator == nil {
+		panic("middlewares: BasicAuth requires Users or a Validator")
+	}
+	challenge := "Basic " + quoteRealm(cfg.Realm) + `, charset="UTF-8"`
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		user, password, ok := basicCredentials(c.Req().HeaderBy("Authorization"))
+		if ok {
+			valid := false
+			if cfg.Users != nil {
+				expected, known := cfg.Users[user]
+				valid = SecureCompare(expected, password) && known
+			}
+			if !valid && cfg.Validator != nil {
+				var err error
+				if valid, err = cfg.Validator(c, user, password); err != nil {
+					return err
+				}
+			}
+			if valid {
+				c.Set(constants.ContextKeyAuthUser, user)
+				return next(c)
+			}
+		}
+
+		c.Response().Header().Set("WWW-Authenticate", challenge)
+		return unauthorized(c, cfg.Unauthorized)
+	}
+}
+
+// basicCredentials decodes the user and password of a Basic Authorization
+// header.
+func basicCredentials(header string) (string, string, bool) {
+	encoded, ok := authCredentials(header, "Basic")
+	if !ok {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"strings"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+type BearerAuthConfig[Bindings any] struct {
+	// Realm is sent in the WWW-Authenticate challenge; "" omits it.
+	Realm string
+	// Tokens lists the accepted tokens, compared in constant time.
+	Tokens []string
+	// Validator checks the token when Tokens is not enough, e.g. against
+	// secrets in c.Env(). A returned error goes to the error handler.
+	Validator func(c interfaces.IContext[Bindings], token string) (bool, error)
+	// Unauthorized writes the response for a missing or rejected token after
+	// the challenge header is set; nil answers a plain 401.
+	Unauthorized interfaces.HandlerFunc[Bindings]
+}
+
+// BearerAuth guards the route with a bearer token (RFC 6750) taken from the
+// Authorization header. The token passes when it is one of Tokens or the
+// Validator accepts it. Otherwise a 401 is sent with a WWW-Authenticate
+// challenge, carrying error="invalid_token" when a token was presented.
+//
+//	app.Use("/api/*", middlewares.BearerAuth(middlewares.BearerAuthConfig[Bindings]{
+//		Validator: func(c interfaces.IContext[Bindings], token string) (bool, error) {
+//			return middlewares.SecureCompare(token, c.Env().APIToken), nil
+//		},
+//	}))
+func BearerAuth[Bindings any](config BearerAuthConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := config
+	if cfg.Tokens == nil && cfg.Validator == nil {
+		panic("middlewares: BearerAuth requires Tokens or a Validator")
+	}
+	var params []string
+	if cfg.Realm != "" {
+		params = append(params, quoteRealm(cfg.Realm))
+	}
+	challenge := bearerChallenge(params)
+	invalidChallenge := bearerChallenge(append(params, `error="invalid_token"`))
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		token, ok := authCredentials(c.Req().HeaderBy("Authorization"), "Bearer")
+		if !ok {
+			c.Response().Header().Set("WWW-Authenticate", challenge)
+			return unauthorized(c, cfg.Unauthorized)
+		}
+
+		valid := secureContains(cfg.Tokens, token)
+		if !valid && cfg.Validator != nil {
+			var err error
+			if valid, err = cfg.Validator(c, token); err != nil {
+				return err
+			}
+		}
+		if !valid {
+			c.Response().Header().Set("WWW-Authenticate", invalidChallenge)
+			return unauthorized(c, cfg.Unauthorized)
+		}
+		return next(c)
+	}
+}
+
+// bearerChallenge formats a Bearer WWW-Authenticate value with its auth-params.
+func bearerChallenge(params []string) string {
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// BodyLimit caps the request body of the routes it guards at limit bytes,
+// however the body is read (Unmarshall, forms, validator.FormFile or the raw
+// Body). A declared Content-Length over the limit is rejected before the
+// handler runs; a body that turns out larger while being read fails the read.
+// Either way the chain returns an error matching ErrBodyTooLarge (and, for
+// reads, *http.MaxBytesError), answered with 413 by the default error handler.
+//
+// It narrows, never widens, TakibiOption.MaxBodyBytes, which still applies to
+// Unmarshall.
+//
+//	app.Use("/upload/*", middlewares.BodyLimit[Bindings](50<<20))
+func BodyLimit[Bindings any](limit int64) interfaces.MiddlewareFunc[Bindings] {
+	if limit <= 0 {
+		panic("middlewares: BodyLimit requires a positive limit")
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		req := c.Req().Raw()
+		if req.ContentLength > limit {
+			return &statusError{code: http.StatusRequestEntityTooLarge, err: ErrBodyTooLarge}
+		}
+		if v, ok := c.Get(constants.ContextKeyBodyLimit); ok {
+			// an outer, stricter limit stays in force
+			if outer, ok := v.(int64); ok && outer < limit {
+				limit = outer
+			}
+		}
+		c.Set(constants.ContextKeyBodyLimit, limit)
+		if req.Body != nil && req.Body != http.NoBody {
+			req.Body = http.MaxBytesReader(c.Response(), req.Body, limit)
+		}
+
+		err := next(c)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &statusError{code: http.StatusRequestEntityTooLarge, err: fmt.Errorf("%w: %w", ErrBodyTooLarge, err)}
+		}
+		return err
+	}
+}
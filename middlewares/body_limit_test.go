@@ -0,0 +1,254 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/poteto0/takibi/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+type echoPayload struct {
+	Message string `json:"message"`
+}
+
+func bodyRoutes(app interfaces.ITakibi[any]) {
+	app.Post("/raw", func(c interfaces.IContext[any]) error {
+		b, err := io.ReadAll(c.Req().Raw().Body)
+		if err != nil {
+			return err
+		}
+		return c.Text(string(b))
+	})
+	app.Post("/json", func(c interfaces.IContext[any]) error {
+		var p echoPayload
+		if err := c.Req().Unmarshall(&p); err != nil {
+			return err
+		}
+		return c.Text(p.Message)
+	})
+	app.Post("/upload", validator.FormFile(func(f *multipart.Form, c interfaces.IContext[any]) (*multipart.Form, error) {
+		return f, nil
+	}), func(c interfaces.IContext[any]) error {
+		return c.Text("uploaded")
+	})
+}
+
+// unsizedBody hides the length so the limit is hit while reading.
+func unsizedBody(s string) interfaces.CampOption {
+	return interfaces.Body(strings.NewReader(s))
+}
+
+func TestBodyLimit(t *testing.T) {
+	app := newTestApp(nil, bodyRoutes, middlewares.BodyLimit[any](16))
+	seen := recordErrors(app)
+
+	t.Run("within the limit", func(t *testing.T) {
+		resp := app.Camp(http.MethodPost, "/raw", unsizedBody("0123456789"))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+	})
+
+	t.Run("declared Content-Length over the limit", func(t *testing.T) {
+		*seen = nil
+		req := httptest.NewRequest(http.MethodPost, "/raw", strings.NewReader(strings.Repeat("x", 32)))
+		w := httptest.NewRecorder()
+
+		app.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.ErrorIs(t, *seen, middlewares.ErrBodyTooLarge)
+	})
+
+	for _, path := range []string{"/raw", "/json"} {
+		t.Run("streamed body over the limit: "+path, func(t *testing.T) {
+			*seen = nil
+
+			resp := app.Camp(http.MethodPost, path,
+				interfaces.Header("Content-Type", "application/json"),
+				unsizedBody(`{"message":"`+strings.Repeat("x", 32)+`"}`),
+			)
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+			assert.ErrorIs(t, *seen, middlewares.ErrBodyTooLarge)
+			var mbe *http.MaxBytesError
+			assert.ErrorAs(t, *seen, &mbe)
+		})
+	}
+
+	t.Run("applies to validator.FormFile", func(t *testing.T) {
+		*seen = nil
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		_, _ = fw.Write([]byte(strings.Repeat("x", 64)))
+		_ = mw.Close()
+		contentType := mw.FormDataContentType()
+
+		resp := app.Camp(http.MethodPost, "/upload",
+			interfaces.Header("Content-Type", contentType),
+			interfaces.Body(body),
+		)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+		assert.ErrorIs(t, *seen, middlewares.ErrBodyTooLarge)
+	})
+
+	t.Run("inner limit can't widen an outer one", func(t *testing.T) {
+		app := newTestApp(nil, bodyRoutes, middlewares.BodyLimit[any](8), middlewares.BodyLimit[any](1024))
+
+		resp := app.Camp(http.MethodPost, "/raw", unsizedBody(strings.Repeat("x", 16)))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+	})
+
+	t.Run("default error handler answers 413", func(t *testing.T) {
+		app := newTestApp(nil, bodyRoutes, middlewares.BodyLimit[any](4))
+
+		resp := app.Camp(http.MethodPost, "/raw", unsizedBody("too long"))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+	})
+
+	t.Run("requires a positive limit", func(t *testing.T) {
+		assert.Panics(t, func() { middlewares.BodyLimit[any](0) })
+	})
+}
+
+func compressBody(t *testing.T, encoding, s string) io.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestDecompress(t *testing.T) {
+	app := newTestApp(nil, bodyRoutes, middlewares.Decompress[any]())
+	seen := recordErrors(app)
+
+	for _, tt := range []struct{ header, encoding string }{
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate", "raw-deflate"},
+	} {
+		t.Run("inflates "+tt.encoding+" sent as "+tt.header, func(t *testing.T) {
+			resp := app.Camp(http.MethodPost, "/json",
+				interfaces.Header("Content-Type", "application/json"),
+				interfaces.Header("Content-Encoding", tt.header),
+				interfaces.Body(compressBody(t, tt.encoding, `{"message":"hello"}`)),
+			)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode())
+			body, _ := readBody(resp)
+			assert.Equal(t, "hello", body)
+		})
+	}
+
+	t.Run("plain bodies pass through", func(t *testing.T) {
+		resp := app.Camp(http.MethodPost, "/raw", unsizedBody("plain"))
+
+		body, _ := readBody(resp)
+		assert.Equal(t, "plain", body)
+	})
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		*seen = nil
+
+		resp := app.Camp(http.MethodPost, "/raw", interfaces.Header("Content-Encoding", "br"), unsizedBody("x"))
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode())
+		assert.ErrorIs(t, *seen, middlewares.ErrUnsupportedEncoding)
+	})
+
+	t.Run("corrupt body", func(t *testing.T) {
+		*seen = nil
+		corrupt, _ := io.ReadAll(compressBody(t, "gzip", strings.Repeat("data ", 100)))
+		corrupt[len(corrupt)-6] ^= 0xff // damage the CRC trailer
+
+		resp := app.Camp(http.MethodPost, "/raw", interfaces.Header("Content-Encoding", "gzip"), interfaces.Body(bytes.NewReader(corrupt)))
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+		assert.ErrorIs(t, *seen, middlewares.ErrMalformedBody)
+	})
+
+	t.Run("not gzip at all", func(t *testing.T) {
+		resp := app.Camp(http.MethodPost, "/raw", interfaces.Header("Content-Encoding", "gzip"), unsizedBody("plain"))
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+
+	t.Run("zip bomb is stopped at the limit", func(t *testing.T) {
+		app := newTestApp(nil, bodyRoutes, middlewares.BodyLimit[any](1<<10), middlewares.Decompress[any]())
+		seen := recordErrors(app)
+		bomb := compressBody(t, "gzip", strings.Repeat("0", 1<<20))
+
+		resp := app.Camp(http.MethodPost, "/raw", interfaces.Header("Content-Encoding", "gzip"), interfaces.Body(bomb))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+		assert.ErrorIs(t, *seen, middlewares.ErrBodyTooLarge)
+	})
+
+	t.Run("explicit MaxBytes", func(t *testing.T) {
+		app := newTestApp(nil, bodyRoutes, middlewares.Decompress[any](middlewares.DecompressConfig{MaxBytes: 10}))
+
+		resp := app.Camp(http.MethodPost, "/raw",
+			interfaces.Header("Content-Encoding", "gzip"),
+			interfaces.Body(compressBody(t, "gzip", strings.Repeat("x", 11))),
+		)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+	})
+
+	t.Run("MaxBodyBytes caps the inflated body without BodyLimit", func(t *testing.T) {
+		app := takibi.NewWithOption[any](nil, interfaces.TakibiOption{MaxBodyBytes: 10})
+		app.Use("*", middlewares.Decompress[any]())
+		bodyRoutes(app)
+
+		resp := app.Camp(http.MethodPost, "/raw",
+			interfaces.Header("Content-Encoding", "gzip"),
+			interfaces.Body(compressBody(t, "gzip", strings.Repeat("x", 11))),
+		)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+	})
+
+	t.Run("BodyLimit overflow inside the gzip header", func(t *testing.T) {
+		app := newTestApp(nil, bodyRoutes, middlewares.BodyLimit[any](4), middlewares.Decompress[any]())
+		seen := recordErrors(app)
+
+		resp := app.Camp(http.MethodPost, "/raw",
+			interfaces.Header("Content-Encoding", "gzip"),
+			interfaces.Body(compressBody(t, "gzip", "x")),
+		)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+		assert.ErrorIs(t, *seen, middlewares.ErrBodyTooLarge)
+		assert.NotErrorIs(t, *seen, middlewares.ErrMalformedBody)
+	})
+}
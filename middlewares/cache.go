@@ -0,0 +1,437 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// Values of the X-Cache header set by Cache.
+const (
+	CacheHit   = "HIT"
+	CacheMiss  = "MISS"
+	CacheStale = "STALE"
+)
+
+type CacheConfig[Bindings any] struct {
+	// Store keeps the responses; nil uses a NewMemoryCacheStore(1000)
+	// private to this middleware.
+	Store CacheStore
+	// DefaultTTL is the freshness lifetime of responses without max-age or
+	// s-maxage; 0 caches only responses that declare one.
+	DefaultTTL time.Duration
+	// KeyFunc returns the cache key of the request; nil keys by host and
+	// request URI. HEAD requests are answered from the GET entry either way,
+	// but their responses are never stored.
+	KeyFunc func(c interfaces.IContext[Bindings]) string
+	// MaxBodyBytes is the largest body stored; larger or flushed (streamed)
+	// responses are sent uncached.
+	MaxBodyBytes int
+	// Skip reports whether the request bypasses the cache.
+	Skip func(c interfaces.IContext[Bindings]) bool
+}
+
+func DefaultCacheConfig[Bindings any]() CacheConfig[Bindings] {
+	return CacheConfig[Bindings]{
+		MaxBodyBytes: 1 << 20,
+	}
+}
+
+// Cache stores complete GET responses (status, headers and body) and replays
+// them to GET and HEAD requests while they are fresh. Freshness comes from
+// the response's Cache-Control: s-maxage, else max-age, else
+// CacheConfig.DefaultTTL.
+// Responses marked no-store, no-cache or private, setting cookies, varying on
+// "*", or answering a request with Authorization (unless public or s-maxage)
+// are never stored. A request with Cache-Control no-cache bypasses the lookup
+// and refreshes the entry; no-store bypasses the cache entirely.
+//
+// Entries are keyed by method and URL, then by the values of the request
+// headers named in the response's Vary. Within stale-while-revalidate an
+// expired entry is still served while the handlers run again in the
+// background, on a Clone of the context, to refresh it; one refresh per entry
+// runs at a time, kept alive by waitUntil on Workers. Replayed responses
+// carry Age and X-Cache headers; a header already set by an outer middleware
+// for the current request is kept.
+//
+// Handlers tag responses with CacheTags and drop them later through
+// CacheStore.InvalidateTags:
+//
+//	store := middlewares.NewMemoryCacheStore(10_000)
+//	app.Use("/articles/*", middlewares.Cache[Bindings](middlewares.CacheConfig[Bindings]{Store: store}))
+//	// after an update
+//	store.InvalidateTags(ctx, "article:"+id)
+func Cache[Bindings any](config ...CacheConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultCacheConfig[Bindings]()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryCacheStore(0)
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultCacheConfig[Bindings]().MaxBodyBytes
+	}
+	keyOf := cfg.KeyFunc
+	if keyOf == nil {
+		keyOf = func(c interfaces.IContext[Bindings]) string {
+			return c.Req().Host() + c.Req().Raw().URL.RequestURI()
+		}
+	}
+
+	var (
+		mu           sync.Mutex
+		revalidating = map[string]struct{}{}
+	)
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		req := c.Req().Raw()
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			return next(c)
+		}
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return next(c)
+		}
+		directives := parseCacheControl(req.Header.Values("Cache-Control"))
+		if _, ok := directives["no-store"]; ok {
+			return next(c)
+		}
+
+		ctx := req.Context()
+		base := http.MethodGet + " " + keyOf(c)
+		res := c.Response()
+		if _, ok := directives["no-cache"]; !ok {
+			key, entry, err := lookupCache(c, cfg.Store, base)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				now := time.Now()
+				if now.Before(entry.Expires) {
+					serveCached(res, req, entry, now, CacheHit)
+					return nil
+				}
+				serveCached(res, req, entry, now, CacheStale)
+				if req.Method != http.MethodGet {
+					// a HEAD response cannot refresh the GET entry
+					return nil
+				}
+
+				mu.Lock()
+				_, busy := revalidating[key]
+				if !busy {
+					revalidating[key] = struct{}{}
+				}
+				mu.Unlock()
+				if busy {
+					return nil
+				}
+
+				// the clone outlives this request, so it gets a request of
+				// its own that is neither cancelled nor pooled with it
+				bg := req.Clone(context.WithoutCancel(ctx))
+				bg.Body = http.NoBody
+				bg.Header.Del("If-None-Match")
+				bg.Header.Del("If-Modified-Since")
+				revalidation := c.Clone()
+				revalidation.SetRequest(bg)
+				cw := &cacheWriter{header: http.Header{}, max: cfg.MaxBodyBytes}
+				revalidation.SetResponse(cw)
+
+				runInBackground(func() {
+					defer func() {
+						if r := recover(); r != nil {
+							LoggerFrom(revalidation).ErrorContext(bg.Context(), "cache: revalidation panicked", "panic", r)
+						}
+						mu.Lock()
+						delete(revalidating, key)
+						mu.Unlock()
+					}()
+					err := next(revalidation)
+					if err == nil {
+						err = storeCached(revalidation, cfg, base, cw)
+					}
+					if err != nil {
+						LoggerFrom(revalidation).WarnContext(bg.Context(), "cache: revalidation failed", "error", err)
+					}
+				})
+				return nil
+			}
+		}
+
+		res.Header().Set("X-Cache", CacheMiss)
+		cw := &cacheWriter{ResponseWriter: res, max: cfg.MaxBodyBytes}
+		c.SetResponse(cw)
+		err := next(c)
+		c.SetResponse(res)
+		if err != nil {
+			return err
+		}
+		if err := storeCached(c, cfg, base, cw); err != nil {
+			LoggerFrom(c).WarnContext(ctx, "cache: store failed", "error", err)
+		}
+		return nil
+	}
+}
+
+// CacheTags adds invalidation tags to the response Cache stores for the
+// current request.
+//
+//	middlewares.CacheTags(c, "articles", "article:"+id)
+func CacheTags(c interface {
+	Get(string) (any, bool)
+	Set(string, any)
+}, tags ...string) {
+	current, _ := c.Get(constants.ContextKeyCacheTags)
+	list, _ := current.([]string)
+	for _, tag := range tags {
+		if !slices.Contains(list, tag) {
+			list = append(list, tag)
+		}
+	}
+	c.Set(constants.ContextKeyCacheTags, list)
+}
+
+// lookupCache resolves the entry for the request, following a Vary marker
+// to the variant matching its headers. It returns the variant's key.
+func lookupCache[Bindings any](c interfaces.IContext[Bindings], store CacheStore, base string) (string, *CachedResponse, error) {
+	req := c.Req().Raw()
+	entry, ok, err := store.Get(req.Context(), base)
+	if err != nil || !ok {
+		return base, nil, err
+	}
+	if len(entry.Vary) == 0 {
+		return base, entry, nil
+	}
+	key := variantKey(base, entry.Vary, req.Header)
+	entry, ok, err = store.Get(req.Context(), key)
+	if err != nil || !ok {
+		return key, nil, err
+	}
+	return key, entry, nil
+}
+
+// storeCached stores the recorded response when its Cache-Control allows it.
+// Only GET responses are stored: a HEAD response has no body and may come
+// from a different route (or a 404) than GET would.
+func storeCached[Bindings any](c interfaces.IContext[Bindings], cfg CacheConfig[Bindings], base string, cw *cacheWriter) error {
+	req := c.Req().Raw()
+	if req.Method != http.MethodGet {
+		return nil
+	}
+	if cw.uncacheable || !cacheableStatus(cw.status) || cw.snapshot == nil {
+		return nil
+	}
+	h := cw.snapshot
+	directives := parseCacheControl(h.Values("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return nil
+		}
+	}
+	if h.Get("Set-Cookie") != "" {
+		return nil
+	}
+	_, public := directives["public"]
+	_, shared := directives["s-maxage"]
+	if req.Header.Get("Authorization") != "" && !public && !shared {
+		return nil
+	}
+
+	ttl := cfg.DefaultTTL
+	if v, ok := directives["s-maxage"]; ok {
+		ttl = seconds(v)
+	} else if v, ok := directives["max-age"]; ok {
+		ttl = seconds(v)
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	var vary []string
+	for _, v := range h.Values("Vary") {
+		for field := range strings.SplitSeq(v, ",") {
+			field = http.CanonicalHeaderKey(strings.TrimSpace(field))
+			if field == "*" {
+				return nil
+			}
+			if field != "" && !slices.Contains(vary, field) {
+				vary = append(vary, field)
+			}
+		}
+	}
+
+	now := time.Now()
+	tagsValue, _ := c.Get(constants.ContextKeyCacheTags)
+	tags, _ := tagsValue.([]string)
+	header := h.Clone()
+	header.Del("X-Cache")
+	header.Del("Age")
+	entry := &CachedResponse{
+		Status:     cw.status,
+		Header:     header,
+		Body:       cw.buf,
+		StoredAt:   now,
+		Expires:    now.Add(ttl),
+		StaleUntil: now.Add(ttl),
+		Tags:       tags,
+	}
+	if v, ok := directives["stale-while-revalidate"]; ok {
+		entry.StaleUntil = entry.Expires.Add(seconds(v))
+	}
+
+	ctx := req.Context()
+	key := base
+	if len(vary) > 0 {
+		marker := &CachedResponse{
+			StoredAt:   now,
+			Expires:    entry.Expires,
+			StaleUntil: entry.StaleUntil,
+			Vary:       vary,
+			Tags:       tags,
+		}
+		if err := cfg.Store.Set(ctx, base, marker); err != nil {
+			return err
+		}
+		key = variantKey(base, vary, req.Header)
+	}
+	return cfg.Store.Set(ctx, key, entry)
+}
+
+// serveCached replays entry, answering a matching conditional request with
+// 304.
+func serveCached(w http.ResponseWriter, r *http.Request, entry *CachedResponse, now time.Time, state string) {
+	h := w.Header()
+	for name, values := range entry.Header {
+		if _, ok := h[name]; !ok {
+			h[name] = slices.Clone(values)
+		}
+	}
+	h.Set("Age", strconv.Itoa(int(now.Sub(entry.StoredAt)/time.Second)))
+	h.Set("X-Cache", state)
+	if entry.Status == http.StatusOK && notModified(r, h.Get("ETag"), h.Get("Last-Modified")) {
+		writeNotModified(w)
+		return
+	}
+	h.Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(entry.Body)
+	}
+}
+
+func variantKey(base string, vary []string, h http.Header) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(h.Values(name), ","))
+	}
+	return b.String()
+}
+
+// cacheableStatus lists the statuses RFC 9111 allows caching by default.
+func cacheableStatus(code int) bool {
+	switch code {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+		http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// parseCacheControl returns the directives of Cache-Control header values,
+// lowercased, with unquoted arguments.
+func parseCacheControl(values []string) map[string]string {
+	directives := map[string]string{}
+	for _, v := range values {
+		for part := range strings.SplitSeq(v, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+func seconds(v string) time.Duration {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+// cacheWriter records the status, headers and body of a response while
+// passing it through. With a nil ResponseWriter (revalidation) it only
+// records.
+type cacheWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	max         int
+	status      int
+	snapshot    http.Header
+	buf         []byte
+	uncacheable bool
+}
+
+func (w *cacheWriter) Header() http.Header {
+	if w.ResponseWriter == nil {
+		return w.header
+	}
+	return w.ResponseWriter.Header()
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.snapshot = w.Header().Clone()
+	}
+	if w.ResponseWriter != nil {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.uncacheable {
+		if len(w.buf)+len(b) > w.max {
+			w.uncacheable = true
+			w.buf = nil
+		} else {
+			w.buf = append(w.buf, b...)
+		}
+	}
+	if w.ResponseWriter == nil {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush marks the response as streamed, which is never cached.
+func (w *cacheWriter) Flush() {
+	w.uncacheable = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
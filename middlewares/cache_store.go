@@ -0,0 +1,138 @@
+package middlewares
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a stored response together with its freshness data.
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// StoredAt is when the response was generated, for the Age header.
+	StoredAt time.Time
+	// Expires is the end of the fresh period.
+	Expires time.Time
+	// StaleUntil is the end of the stale-while-revalidate period; it equals
+	// Expires without one. Stores may drop the entry after it.
+	StaleUntil time.Time
+	// Vary lists the request headers selecting among variants. An entry
+	// with Vary is a marker: the variant itself is stored under a key
+	// extended with the request's values of those headers.
+	Vary []string
+	// Tags are the invalidation tags set with CacheTags.
+	Tags []string
+}
+
+// CacheStore keeps responses for Cache.
+type CacheStore interface {
+	// Get returns the entry stored under key; ok is false on a miss.
+	Get(ctx context.Context, key string) (resp *CachedResponse, ok bool, err error)
+	// Set stores resp under key, replacing any previous entry.
+	Set(ctx context.Context, key string, resp *CachedResponse) error
+	// InvalidateTags drops every entry carrying one of tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// MemoryCacheStore is a size-bounded, least-recently-used CacheStore in
+// process memory.
+type MemoryCacheStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used
+	items    map[string]*list.Element
+	tags     map[string]map[string]struct{}
+}
+
+type memoryCacheItem struct {
+	key  string
+	resp *CachedResponse
+}
+
+// NewMemoryCacheStore returns a store holding at most capacity entries
+// (1000 when capacity <= 0).
+func NewMemoryCacheStore(capacity int) *MemoryCacheStore {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &MemoryCacheStore{
+		capacity: capacity,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+		tags:     map[string]map[string]struct{}{},
+	}
+}
+
+func (s *MemoryCacheStore) Get(_ context.Context, key string) (*CachedResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := el.Value.(*memoryCacheItem)
+	if time.Now().After(item.resp.StaleUntil) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return item.resp, true, nil
+}
+
+func (s *MemoryCacheStore) Set(_ context.Context, key string, resp *CachedResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	s.items[key] = s.order.PushFront(&memoryCacheItem{key: key, resp: resp})
+	for _, tag := range resp.Tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = map[string]struct{}{}
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+func (s *MemoryCacheStore) InvalidateTags(_ context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if el, ok := s.items[key]; ok {
+				s.remove(el)
+			}
+		}
+	}
+	return nil
+}
+
+// Len returns the number of stored entries.
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryCacheStore) remove(el *list.Element) {
+	item := el.Value.(*memoryCacheItem)
+	s.order.Remove(el)
+	delete(s.items, item.key)
+	for _, tag := range item.resp.Tags {
+		delete(s.tags[tag], item.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
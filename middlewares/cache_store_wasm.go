@@ -0,0 +1,100 @@
+//go:build wasm
+
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syumai/workers/cloudflare/cache"
+)
+
+// ErrCacheTagsUnsupported is returned by WorkersCacheStore.InvalidateTags
+// without a PurgeTags hook: the Workers Cache API cannot delete by tag.
+var ErrCacheTagsUnsupported = errors.New("cache: tag invalidation needs WorkersCacheStore.PurgeTags")
+
+// WorkersCacheStore is a CacheStore on the Workers Cache API, local to the
+// data center serving the request. Entries are stored under synthetic URLs
+// with a Cache-Tag header listing their tags, so a zone purge by tag (from
+// PurgeTags) drops them. The Cache API is unavailable on workers.dev
+// domains.
+type WorkersCacheStore struct {
+	// Namespace selects a named cache; "" uses caches.default.
+	Namespace string
+	// PurgeTags drops the entries carrying tags, typically by calling the
+	// Cloudflare purge-by-tag API for the zone.
+	PurgeTags func(ctx context.Context, tags []string) error
+}
+
+func (s *WorkersCacheStore) Get(_ context.Context, key string) (*CachedResponse, bool, error) {
+	res, err := s.cache().Match(workersCacheRequest(key), nil)
+	if errors.Is(err, cache.ErrCacheNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+
+	var entry CachedResponse
+	if err := json.NewDecoder(res.Body).Decode(&entry); err != nil {
+		return nil, false, err
+	}
+	if time.Now().After(entry.StaleUntil) {
+		return nil, false, nil
+	}
+	return &entry, true, nil
+}
+
+func (s *WorkersCacheStore) Set(_ context.Context, key string, resp *CachedResponse) error {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	ttl := int(time.Until(resp.StaleUntil) / time.Second)
+	if ttl <= 0 {
+		return nil
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Cache-Control", "public, max-age="+strconv.Itoa(ttl))
+	if len(resp.Tags) > 0 {
+		header.Set("Cache-Tag", strings.Join(resp.Tags, ","))
+	}
+	return s.cache().Put(workersCacheRequest(key), &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	})
+}
+
+func (s *WorkersCacheStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	if s.PurgeTags == nil {
+		return ErrCacheTagsUnsupported
+	}
+	return s.PurgeTags(ctx, tags)
+}
+
+func (s *WorkersCacheStore) cache() *cache.Cache {
+	if s.Namespace != "" {
+		return cache.New(cache.WithNamespace(s.Namespace))
+	}
+	return cache.New()
+}
+
+func workersCacheRequest(key string) *http.Request {
+	return &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Scheme: "https", Host: "takibi-cache.internal", Path: "/", RawQuery: "k=" + url.QueryEscape(key)},
+		Header: http.Header{},
+	}
+}
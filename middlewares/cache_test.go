@@ -0,0 +1,253 @@
+package middlewares_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+// cacheRoutes counts the handler runs in calls.
+func cacheRoutes(calls *int) func(app interfaces.ITakibi[any]) {
+	return func(app interfaces.ITakibi[any]) {
+		handle := func(cacheControl string) interfaces.HandlerFunc[any] {
+			return func(c interfaces.IContext[any]) error {
+				*calls++
+				if cacheControl != "" {
+					c.Response().Header().Set("Cache-Control", cacheControl)
+				}
+				return c.Text(fmt.Sprintf("call %d", *calls))
+			}
+		}
+		app.Get("/fresh", handle("max-age=60"))
+		app.Head("/fresh", handle("max-age=60"))
+		app.Get("/shared", handle("max-age=0, s-maxage=60"))
+		app.Get("/private", handle("private, max-age=60"))
+		app.Get("/no-store", handle("no-store"))
+		app.Get("/plain", handle(""))
+		app.Get("/stale", handle("max-age=60, stale-while-revalidate=30"))
+		app.Get("/lang", func(c interfaces.IContext[any]) error {
+			*calls++
+			c.Response().Header().Set("Cache-Control", "max-age=60")
+			c.Response().Header().Set("Vary", "Accept-Language")
+			return c.Text(c.Req().HeaderBy("Accept-Language"))
+		})
+		app.Get("/cookie", func(c interfaces.IContext[any]) error {
+			*calls++
+			c.Response().Header().Set("Cache-Control", "max-age=60")
+			c.Response().Header().Set("Set-Cookie", "sid=1")
+			return c.Text("cookie")
+		})
+		app.Get("/tagged/:id", func(c interfaces.IContext[any]) error {
+			*calls++
+			middlewares.CacheTags(c, "article:"+c.ParamBy("id"))
+			c.Response().Header().Set("Cache-Control", "max-age=60")
+			return c.Text(fmt.Sprintf("article %s call %d", c.ParamBy("id"), *calls))
+		})
+	}
+}
+
+func TestCache_StoresFreshResponses(t *testing.T) {
+	calls := 0
+	app := newTestApp(nil, cacheRoutes(&calls), middlewares.Cache[any]())
+
+	first := app.Camp(http.MethodGet, "/fresh")
+	second := app.Camp(http.MethodGet, "/fresh")
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, middlewares.CacheMiss, first.Raw().Header.Get("X-Cache"))
+	assert.Equal(t, middlewares.CacheHit, second.Raw().Header.Get("X-Cache"))
+	assert.Equal(t, "0", second.Raw().Header.Get("Age"))
+	assert.Equal(t, "text/plain", second.Raw().Header.Get("Content-Type"))
+	body, _ := readBody(second)
+	assert.Equal(t, "call 1", body)
+
+	t.Run("HEAD shares the GET entry", func(t *testing.T) {
+		res := app.Camp(http.MethodHead, "/fresh")
+		assert.Equal(t, middlewares.CacheHit, res.Raw().Header.Get("X-Cache"))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("request no-cache refreshes the entry", func(t *testing.T) {
+		res := app.Camp(http.MethodGet, "/fresh", interfaces.Header("Cache-Control", "no-cache"))
+		body, _ := readBody(res)
+		assert.Equal(t, "call 2", body)
+		body, _ = readBody(app.Camp(http.MethodGet, "/fresh"))
+		assert.Equal(t, "call 2", body)
+	})
+
+	t.Run("request no-store bypasses the cache", func(t *testing.T) {
+		res := app.Camp(http.MethodGet, "/fresh", interfaces.Header("Cache-Control", "no-store"))
+		assert.Empty(t, res.Raw().Header.Get("X-Cache"))
+		assert.Equal(t, 3, calls)
+	})
+}
+
+func TestCache_ResponseDirectives(t *testing.T) {
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/shared", true},
+		{"/private", false},
+		{"/no-store", false},
+		{"/plain", false},
+		{"/cookie", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			calls := 0
+			app := newTestApp(nil, cacheRoutes(&calls), middlewares.Cache[any]())
+
+			app.Camp(http.MethodGet, tt.path)
+			app.Camp(http.MethodGet, tt.path)
+
+			if tt.cached {
+				assert.Equal(t, 1, calls)
+			} else {
+				assert.Equal(t, 2, calls)
+			}
+		})
+	}
+}
+
+func TestCache_AuthorizedRequests(t *testing.T) {
+	calls := 0
+	app := newTestApp(nil, cacheRoutes(&calls), middlewares.Cache[any]())
+	auth := interfaces.Header("Authorization", "Bearer t")
+
+	app.Camp(http.MethodGet, "/fresh", auth)
+	app.Camp(http.MethodGet, "/fresh", auth)
+	assert.Equal(t, 2, calls, "max-age alone does not allow sharing")
+
+	app.Camp(http.MethodGet, "/shared", auth)
+	app.Camp(http.MethodGet, "/shared", auth)
+	assert.Equal(t, 3, calls, "s-maxage does")
+}
+
+func TestCache_Vary(t *testing.T) {
+	calls := 0
+	app := newTestApp(nil, cacheRoutes(&calls), middlewares.Cache[any]())
+	get := func(lang string) string {
+		body, _ := readBody(app.Camp(http.MethodGet, "/lang", interfaces.Header("Accept-Language", lang)))
+		return body
+	}
+
+	assert.Equal(t, "ja", get("ja"))
+	assert.Equal(t, "en", get("en"))
+	assert.Equal(t, "ja", get("ja"))
+	assert.Equal(t, "en", get("en"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestCache_ConditionalHit(t *testing.T) {
+	calls := 0
+	app := newTestApp(nil, func(app interfaces.ITakibi[any]) {
+		app.Get("/doc", func(c interfaces.IContext[any]) error {
+			calls++
+			c.Response().Header().Set("Cache-Control", "max-age=60")
+			c.Response().Header().Set("ETag", `"v1"`)
+			return c.Text("doc")
+		})
+	}, middlewares.Cache[any]())
+
+	app.Camp(http.MethodGet, "/doc")
+	res := app.Camp(http.MethodGet, "/doc", interfaces.Header("If-None-Match", `"v1"`))
+
+	assert.Equal(t, http.StatusNotModified, res.StatusCode())
+	assert.Equal(t, middlewares.CacheHit, res.Raw().Header.Get("X-Cache"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	calls := 0
+	store := middlewares.NewMemoryCacheStore(10)
+	app := newTestApp(nil, cacheRoutes(&calls), middlewares.Cache[any](middlewares.CacheConfig[any]{Store: store}))
+	now := time.Now()
+	assert.NoError(t, store.Set(context.Background(), "GET /stale", &middlewares.CachedResponse{
+		Status:     http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       []byte("old"),
+		StoredAt:   now.Add(-90 * time.Second),
+		Expires:    now.Add(-30 * time.Second),
+		StaleUntil: now.Add(time.Minute),
+	}))
+
+	stale := app.Camp(http.MethodGet, "/stale")
+	body, _ := readBody(stale)
+	assert.Equal(t, "old", body)
+	assert.Equal(t, middlewares.CacheStale, stale.Raw().Header.Get("X-Cache"))
+	assert.Equal(t, "90", stale.Raw().Header.Get("Age"))
+
+	// the refresh runs in the background, after the stale answer
+	assert.Eventually(t, func() bool {
+		entry, ok, _ := store.Get(context.Background(), "GET /stale")
+		return ok && time.Now().Before(entry.Expires)
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, calls)
+
+	fresh := app.Camp(http.MethodGet, "/stale")
+	body, _ = readBody(fresh)
+	assert.Equal(t, "call 1", body)
+	assert.Equal(t, middlewares.CacheHit, fresh.Raw().Header.Get("X-Cache"))
+}
+
+func TestCache_HeadIsNotStored(t *testing.T) {
+	store := middlewares.NewMemoryCacheStore(10)
+	app := newTestApp(nil, func(app interfaces.ITakibi[any]) {
+		app.Get("/get-only", func(c interfaces.IContext[any]) error {
+			return c.Text("body")
+		})
+	}, middlewares.Cache[any](middlewares.CacheConfig[any]{Store: store, DefaultTTL: time.Minute}))
+
+	head := app.Camp(http.MethodHead, "/get-only")
+	assert.Equal(t, http.StatusNotFound, head.Raw().StatusCode)
+	assert.Equal(t, 0, store.Len())
+
+	get := app.Camp(http.MethodGet, "/get-only")
+	assert.Equal(t, http.StatusOK, get.Raw().StatusCode)
+	body, _ := readBody(get)
+	assert.Equal(t, "body", body)
+}
+
+func TestCache_InvalidateTags(t *testing.T) {
+	calls := 0
+	store := middlewares.NewMemoryCacheStore(10)
+	app := newTestApp(nil, cacheRoutes(&calls), middlewares.Cache[any](middlewares.CacheConfig[any]{Store: store}))
+
+	app.Camp(http.MethodGet, "/tagged/1")
+	app.Camp(http.MethodGet, "/tagged/2")
+	assert.NoError(t, store.InvalidateTags(context.Background(), "article:1"))
+
+	body, _ := readBody(app.Camp(http.MethodGet, "/tagged/1"))
+	assert.Equal(t, "article 1 call 3", body)
+	body, _ = readBody(app.Camp(http.MethodGet, "/tagged/2"))
+	assert.Equal(t, "article 2 call 2", body)
+}
+
+func TestMemoryCacheStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	store := middlewares.NewMemoryCacheStore(2)
+	entry := func() *middlewares.CachedResponse {
+		return &middlewares.CachedResponse{StaleUntil: time.Now().Add(time.Minute), Tags: []string{"t"}}
+	}
+
+	_ = store.Set(ctx, "a", entry())
+	_ = store.Set(ctx, "b", entry())
+	_, _, _ = store.Get(ctx, "a")
+	_ = store.Set(ctx, "c", entry())
+
+	_, okA, _ := store.Get(ctx, "a")
+	_, okB, _ := store.Get(ctx, "b")
+	assert.True(t, okA)
+	assert.False(t, okB)
+	assert.Equal(t, 2, store.Len())
+
+	assert.NoError(t, store.InvalidateTags(ctx, "t"))
+	assert.Equal(t, 0, store.Len())
+}
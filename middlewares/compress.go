@@ -0,0 +1,338 @@
+package middlewares
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// CompressWriter is a streaming compressor. *gzip.Writer and *flate.Writer
+// satisfy it, as do the brotli and zstd writers of the common third-party
+// packages.
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Encoder is a content coding Compress can negotiate. Writers are pooled and
+// Reset between responses.
+type Encoder interface {
+	// Encoding is the Content-Encoding token, e.g. "gzip" or "br".
+	Encoding() string
+	// NewWriter returns a compressor writing to w.
+	NewWriter(w io.Writer) CompressWriter
+}
+
+type funcEncoder struct {
+	encoding  string
+	newWriter func(w io.Writer) CompressWriter
+}
+
+func (e *funcEncoder) Encoding() string                     { return e.encoding }
+func (e *funcEncoder) NewWriter(w io.Writer) CompressWriter { return e.newWriter(w) }
+
+// NewEncoder adapts a writer constructor into an Encoder, e.g. for brotli:
+//
+//	middlewares.NewEncoder("br", func(w io.Writer) middlewares.CompressWriter {
+//		return brotli.NewWriter(w)
+//	})
+func NewEncoder(encoding string, newWriter func(w io.Writer) CompressWriter) Encoder {
+	return &funcEncoder{encoding: encoding, newWriter: newWriter}
+}
+
+// GzipEncoder compresses with gzip at the given level (gzip.DefaultCompression
+// when out of range).
+func GzipEncoder(level int) Encoder {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	return NewEncoder("gzip", func(w io.Writer) CompressWriter {
+		gw, _ := gzip.NewWriterLevel(w, level)
+		return gw
+	})
+}
+
+// DeflateEncoder compresses with deflate at the given level
+// (flate.DefaultCompression when out of range).
+func DeflateEncoder(level int) Encoder {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = flate.DefaultCompression
+	}
+	return NewEncoder("deflate", func(w io.Writer) CompressWriter {
+		fw, _ := flate.NewWriter(w, level)
+		return fw
+	})
+}
+
+type CompressConfig struct {
+	// Encoders lists the supported codings in server preference order, used
+	// to break ties between equally weighted Accept-Encoding entries.
+	Encoders []Encoder
+	// MinLength is the smallest body, in bytes, worth compressing.
+	MinLength int
+	// SkipContentTypes lists media types (or prefixes ending in "/") that are
+	// already compressed and are sent as is.
+	SkipContentTypes []string
+}
+
+func DefaultCompressConfig() CompressConfig {
+	return CompressConfig{
+		Encoders:  []Encoder{GzipEncoder(gzip.DefaultCompression), DeflateEncoder(flate.DefaultCompression)},
+		MinLength: 1024,
+		SkipContentTypes: []string{
+			"image/", "video/", "audio/", "font/woff", "font/woff2",
+			"application/zip", "application/gzip", "application/x-gzip",
+			"application/zstd", "application/x-7z-compressed", "application/x-rar-compressed",
+			"application/pdf", "application/wasm", "application/octet-stream",
+		},
+	}
+}
+
+// Compress compresses response bodies with the best coding the client accepts
+// (Accept-Encoding). The body is buffered until MinLength bytes are written,
+// so small responses, responses that already carry a Content-Encoding and
+// SkipContentTypes are passed through unchanged; compressed responses drop
+// Content-Length. Vary: Accept-Encoding is always added. A Flush (as used
+// when streaming with ctx.Stream) flushes the compressor first, so streamed
+// chunks reach the client immediately.
+//
+//	app.Use("*", middlewares.Compress[Bindings]())
+func Compress[Bindings any](config ...CompressConfig) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultCompressConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	pools := make(map[string]*sync.Pool, len(cfg.Encoders))
+	for _, e := range cfg.Encoders {
+		pools[e.Encoding()] = &sync.Pool{New: func() any { return e.NewWriter(io.Discard) }}
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		res := c.Response()
+		addVary(res.Header(), "Accept-Encoding")
+
+		req := c.Req().Raw()
+		enc := negotiateEncoding(req.Header.Get("Accept-Encoding"), cfg.Encoders)
+		if enc == nil || req.Method == http.MethodHead {
+			return next(c)
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: res,
+			encoding:       enc.Encoding(),
+			pool:           pools[enc.Encoding()],
+			minLength:      cfg.MinLength,
+			skipTypes:      cfg.SkipContentTypes,
+		}
+		c.SetResponse(cw)
+		err := next(c)
+		closeErr := cw.close()
+		// responses written later, e.g. by the error handler, go out as is
+		c.SetResponse(res)
+		if err != nil {
+			return err
+		}
+		return closeErr
+	}
+}
+
+// compressWriter buffers the start of a body to decide whether to compress
+// it, then streams through the pooled compressor or passes through.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding  string
+	pool      *sync.Pool
+	minLength int
+	skipTypes []string
+
+	status  int
+	buf     []byte
+	decided bool
+	zw      CompressWriter // non-nil once compressing
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	// bodiless or informational responses are never compressed
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.zw != nil {
+			return w.zw.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minLength {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush commits the buffered start of a streamed body (compressing it unless
+// its type or encoding says otherwise) and pushes everything written so far to
+// the client.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.start(true); err != nil {
+			return
+		}
+	}
+	if w.zw != nil {
+		_ = w.zw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close ends the body: a body that never reached MinLength goes out
+// uncompressed, and the compressor is finished and returned to the pool.
+func (w *compressWriter) close() error {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// nothing was written; leave the response to the error handler
+			return nil
+		}
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.start(len(w.buf) >= w.minLength); err != nil {
+			return err
+		}
+	}
+	if w.zw == nil {
+		return nil
+	}
+	err := w.zw.Close()
+	w.zw.Reset(io.Discard)
+	w.pool.Put(w.zw)
+	w.zw = nil
+	return err
+}
+
+// start decides on compression, sends the header and the buffered bytes.
+func (w *compressWriter) start(compress bool) error {
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// sniff before compressing, or net/http would sniff the compressed bytes
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	compress = compress && h.Get("Content-Encoding") == "" && !w.skipType(h.Get("Content-Type"))
+	w.decide(compress)
+
+	buf := w.buf
+	w.buf = nil
+	if w.zw != nil {
+		_, err := w.zw.Write(buf)
+		return err
+	}
+	if len(buf) > 0 {
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+	return nil
+}
+
+// decide fixes whether the body is compressed and writes the status line.
+func (w *compressWriter) decide(compress bool) {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		zw := w.pool.Get().(CompressWriter)
+		zw.Reset(w.ResponseWriter)
+		w.zw = zw
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *compressWriter) skipType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	return slices.ContainsFunc(w.skipTypes, func(t string) bool {
+		if strings.HasSuffix(t, "/") {
+			return strings.HasPrefix(mediaType, t)
+		}
+		return mediaType == t
+	})
+}
+
+// negotiateEncoding picks the encoder with the highest Accept-Encoding weight;
+// ties go to the earlier encoder. nil means send the body uncompressed.
+func negotiateEncoding(accept string, encoders []Encoder) Encoder {
+	if accept == "" {
+		return nil
+	}
+	weights := map[string]float64{}
+	for part := range strings.SplitSeq(accept, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		weights[strings.ToLower(strings.TrimSpace(coding))] = q
+	}
+
+	var (
+		best  Encoder
+		bestQ float64
+	)
+	for _, e := range encoders {
+		q, ok := weights[e.Encoding()]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	return best
+}
+
+// addVary appends value to the Vary header unless it is already listed.
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for field := range strings.SplitSeq(v, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
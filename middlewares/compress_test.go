@@ -0,0 +1,191 @@
+package middlewares_test
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+var largeText = strings.Repeat("takibi compresses this line. ", 100)
+
+func compressRoutes(app interfaces.ITakibi[any]) {
+	app.Get("/large", func(c interfaces.IContext[any]) error { return c.Text(largeText) })
+	app.Get("/small", func(c interfaces.IContext[any]) error { return c.Text("tiny") })
+	app.Get("/json", func(c interfaces.IContext[any]) error {
+		return c.Json(map[string]string{"data": largeText})
+	})
+	app.Get("/png", func(c interfaces.IContext[any]) error {
+		c.Response().Header().Set("Content-Type", "image/png")
+		return c.Bytes([]byte(largeText))
+	})
+	app.Get("/encoded", func(c interfaces.IContext[any]) error {
+		c.Response().Header().Set("Content-Encoding", "br")
+		return c.Text(largeText)
+	})
+	app.Get("/nocontent", func(c interfaces.IContext[any]) error {
+		c.Response().WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	app.Get("/fail", func(c interfaces.IContext[any]) error { return errors.New("boom") })
+}
+
+func gunzip(t *testing.T, r io.Reader) string {
+	t.Helper()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompress(t *testing.T) {
+	app := newTestApp(nil, compressRoutes, middlewares.Compress[any]())
+
+	t.Run("gzips large bodies", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/large", interfaces.Header("Accept-Encoding", "gzip"))
+
+		h := resp.Raw().Header
+		assert.Equal(t, "gzip", h.Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", h.Get("Vary"))
+		assert.Empty(t, h.Get("Content-Length"))
+		assert.Equal(t, "text/plain", h.Get("Content-Type"))
+		assert.Equal(t, largeText, gunzip(t, resp.Raw().Body))
+	})
+
+	t.Run("compresses ctx.Json", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/json", interfaces.Header("Accept-Encoding", "gzip"))
+
+		assert.Equal(t, "gzip", resp.Raw().Header.Get("Content-Encoding"))
+		assert.Contains(t, gunzip(t, resp.Raw().Body), largeText)
+	})
+
+	t.Run("prefers the highest weight", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/large", interfaces.Header("Accept-Encoding", "gzip;q=0.5, deflate"))
+
+		assert.Equal(t, "deflate", resp.Raw().Header.Get("Content-Encoding"))
+		b, err := io.ReadAll(flate.NewReader(resp.Raw().Body))
+		assert.NoError(t, err)
+		assert.Equal(t, largeText, string(b))
+	})
+
+	t.Run("ties go to the server order", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/large", interfaces.Header("Accept-Encoding", "deflate, gzip"))
+
+		assert.Equal(t, "gzip", resp.Raw().Header.Get("Content-Encoding"))
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		status int
+	}{
+		{"no Accept-Encoding", "/large", "", http.StatusOK},
+		{"unsupported coding", "/large", "br", http.StatusOK},
+		{"refused coding", "/large", "gzip;q=0, *;q=0", http.StatusOK},
+		{"small body", "/small", "gzip", http.StatusOK},
+		{"incompressible type", "/png", "gzip", http.StatusOK},
+		{"no content", "/nocontent", "gzip", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run("passes through: "+tt.name, func(t *testing.T) {
+			resp := app.Camp(http.MethodGet, tt.path, interfaces.Header("Accept-Encoding", tt.accept))
+
+			assert.Equal(t, tt.status, resp.StatusCode())
+			assert.Empty(t, resp.Raw().Header.Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", resp.Raw().Header.Get("Vary"))
+		})
+	}
+
+	t.Run("keeps an existing Content-Encoding", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/encoded", interfaces.Header("Accept-Encoding", "gzip"))
+
+		assert.Equal(t, "br", resp.Raw().Header.Get("Content-Encoding"))
+		body, _ := readBody(resp)
+		assert.Equal(t, largeText, body)
+	})
+
+	t.Run("error handler response is sent as is", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/fail", interfaces.Header("Accept-Encoding", "gzip"))
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
+		body, _ := readBody(resp)
+		assert.Equal(t, "Internal Server Error", body)
+	})
+
+	t.Run("wildcard and custom encoder", func(t *testing.T) {
+		app := newTestApp(nil, compressRoutes, middlewares.Compress[any](middlewares.CompressConfig{
+			Encoders: []middlewares.Encoder{
+				middlewares.NewEncoder("x-test", func(w io.Writer) middlewares.CompressWriter {
+					gw, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
+					return gw
+				}),
+			},
+			MinLength: 1,
+		}))
+
+		resp := app.Camp(http.MethodGet, "/small", interfaces.Header("Accept-Encoding", "*"))
+
+		assert.Equal(t, "x-test", resp.Raw().Header.Get("Content-Encoding"))
+		assert.Equal(t, "tiny", gunzip(t, resp.Raw().Body))
+	})
+}
+
+func TestCompress_StreamFlushes(t *testing.T) {
+	chunks := make(chan struct{})
+	app := newTestApp(nil, func(app interfaces.ITakibi[any]) {
+		app.Get("/events", func(c interfaces.IContext[any]) error {
+			c.Response().Header().Set("Content-Type", "text/event-stream")
+			for _, msg := range []string{"data: one\n\n", "data: two\n\n"} {
+				if err := c.Stream([]byte(msg)); err != nil {
+					return err
+				}
+				if err := http.NewResponseController(c.Response()).Flush(); err != nil {
+					return err
+				}
+				<-chunks
+			}
+			return nil
+		})
+	}, middlewares.Compress[any]())
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	zr, err := gzip.NewReader(resp.Body)
+	if !assert.NoError(t, err) {
+		return
+	}
+	lines := bufio.NewReader(zr)
+	first, err := lines.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "data: one\n", first, "first chunk arrives before the handler returns")
+
+	chunks <- struct{}{}
+	_, _ = lines.ReadString('\n')
+	second, _ := lines.ReadString('\n')
+	assert.Equal(t, "data: two\n", second)
+	chunks <- struct{}{}
+}
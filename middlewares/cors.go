@@ -2,6 +2,7 @@ package middlewares
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -9,12 +10,20 @@ import (
 )
 
 type CorsConfig struct {
+	// AllowOrigins lists the allowed origins: "*" for any, an exact origin
+	// ("https://example.com"), or a subdomain pattern
+	// ("https://*.example.com") matching any subdomain but not the apex.
 	AllowOrigins     []string
 	AllowMethods     []string
 	AllowHeaders     []string
 	AllowCredentials bool
 	ExposeHeaders    []string
 	MaxAge           int
+	// AllowPrivateNetwork answers preflights carrying
+	// Access-Control-Request-Private-Network, sent before a public site
+	// reaches a private-network server, with
+	// Access-Control-Allow-Private-Network.
+	AllowPrivateNetwork bool
 }
 
 func DefaultCorsConfig() CorsConfig {
@@ -26,55 +35,150 @@ func DefaultCorsConfig() CorsConfig {
 	}
 }
 
+// Cors sets the CORS response headers for allowed origins and answers
+// preflights (OPTIONS with Origin and Access-Control-Request-Method) with 204.
+// Other OPTIONS requests reach the route handler.
+//
+// Unless every origin is allowed with "*", the answer depends on the
+// request's Origin, so responses carry Vary: Origin for caches.
+//
+//	app.Use("*", middlewares.Cors[Bindings](middlewares.CorsConfig{
+//		AllowOrigins: []string{"https://*.example.com"},
+//	}))
 func Cors[Bindings any](config ...CorsConfig) interfaces.MiddlewareFunc[Bindings] {
+	return CorsWithOriginFunc[Bindings](nil, config...)
+}
+
+// CorsWithOriginFunc is Cors with originFunc deciding the origins
+// AllowOrigins does not match, e.g. against a per-tenant allowlist read
+// from c.Env().
+//
+//	app.Use("*", middlewares.CorsWithOriginFunc(func(c interfaces.IContext[Bindings], origin string) bool {
+//		return slices.Contains(c.Env().TenantOrigins, origin)
+//	}, middlewares.CorsConfig{AllowOrigins: []string{"https://app.example.com"}}))
+func CorsWithOriginFunc[Bindings any](originFunc func(c interfaces.IContext[Bindings], origin string) bool, config ...CorsConfig) interfaces.MiddlewareFunc[Bindings] {
 	cfg := DefaultCorsConfig()
 	if len(config) > 0 {
 		cfg = config[0]
 	}
 
+	allowAll := slices.Contains(cfg.AllowOrigins, "*")
+	var exact []string
+	var patterns []originPattern
+	for _, o := range cfg.AllowOrigins {
+		if p, ok := parseOriginPattern(o); ok {
+			patterns = append(patterns, p)
+		} else if o != "*" {
+			exact = append(exact, o)
+		}
+	}
+	varies := !allowAll
+
+	allowed := func(c interfaces.IContext[Bindings], origin string) bool {
+		if slices.Contains(exact, origin) {
+			return true
+		}
+		for _, p := range patterns {
+			if p.match(origin) {
+				return true
+			}
+		}
+		return originFunc != nil && originFunc(c, origin)
+	}
+
 	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
 		req := c.Req().Raw()
-		res := c.Response()
+		h := c.Response().Header()
 		origin := req.Header.Get("Origin")
+		preflight := req.Method == http.MethodOptions && origin != "" &&
+			req.Header.Get("Access-Control-Request-Method") != ""
 
-		// Check allowed origins
-		allowOrigin := ""
-		for _, o := range cfg.AllowOrigins {
-			if o == "*" {
-				allowOrigin = "*"
-				break
-			}
-			if o == origin {
-				allowOrigin = origin
-				break
+		if varies {
+			addVary(h, "Origin")
+		}
+		if preflight && varies {
+			addVary(h, "Access-Control-Request-Method")
+			addVary(h, "Access-Control-Request-Headers")
+			if cfg.AllowPrivateNetwork {
+				addVary(h, "Access-Control-Request-Private-Network")
 			}
 		}
 
+		allowOrigin := ""
+		switch {
+		case allowAll:
+			allowOrigin = "*"
+		case origin != "" && allowed(c, origin):
+			allowOrigin = origin
+		}
+
 		if allowOrigin != "" {
-			res.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			h.Set("Access-Control-Allow-Origin", allowOrigin)
 			if cfg.AllowCredentials {
-				res.Header().Set("Access-Control-Allow-Credentials", "true")
+				h.Set("Access-Control-Allow-Credentials", "true")
 			}
-			if len(cfg.ExposeHeaders) > 0 {
-				res.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposeHeaders, ", "))
+		}
+
+		if !preflight {
+			if allowOrigin != "" && len(cfg.ExposeHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposeHeaders, ", "))
 			}
+			return next(c)
 		}
 
-		// Handle preflight
-		if req.Method == http.MethodOptions {
+		if allowOrigin != "" {
 			if len(cfg.AllowMethods) > 0 {
-				res.Header().Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowMethods, ", "))
+				h.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowMethods, ", "))
 			}
 			if len(cfg.AllowHeaders) > 0 {
-				res.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowHeaders, ", "))
+				h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowHeaders, ", "))
 			}
 			if cfg.MaxAge > 0 {
-				res.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+				h.Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			}
+			if cfg.AllowPrivateNetwork && req.Header.Get("Access-Control-Request-Private-Network") == "true" {
+				h.Set("Access-Control-Allow-Private-Network", "true")
 			}
-			res.WriteHeader(http.StatusNoContent)
-			return nil
 		}
+		c.Response().WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+// originPattern is an AllowOrigins entry with a leading "*." host label.
+type originPattern struct {
+	scheme string // "https://"
+	suffix string // ".example.com", with the port if any
+}
 
-		return next(c)
+func parseOriginPattern(o string) (originPattern, bool) {
+	scheme, rest, ok := strings.Cut(o, "://")
+	if !ok || !strings.HasPrefix(rest, "*.") || len(rest) < 3 || strings.Contains(rest[1:], "*") {
+		return originPattern{}, false
+	}
+	return originPattern{scheme: scheme + "://", suffix: rest[1:]}, true
+}
+
+// match reports whether origin has the pattern's scheme and a host made of
+// one or more DNS labels in front of its suffix.
+func (p originPattern) match(origin string) bool {
+	sub, ok := strings.CutPrefix(origin, p.scheme)
+	if !ok {
+		return false
+	}
+	sub, ok = strings.CutSuffix(sub, p.suffix)
+	if !ok || sub == "" {
+		return false
+	}
+	for label := range strings.SplitSeq(sub, ".") {
+		if label == "" {
+			return false
+		}
+		for _, ch := range label {
+			if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-') {
+				return false
+			}
+		}
 	}
+	return true
 }
@@ -3,6 +3,7 @@ package middlewares_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 
 	"github.com/poteto0/takibi"
@@ -51,6 +52,7 @@ func TestCors(t *testing.T) {
 
 		req := httptest.NewRequest(http.MethodOptions, "/", nil)
 		req.Header.Set("Origin", "http://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
 		rec := httptest.NewRecorder()
 		ctx := takibi.NewContext[any](rec, req, nil, nil)
 
@@ -105,3 +107,99 @@ func TestCors(t *testing.T) {
 		assert.Equal(t, "X-Custom-Header, X-Another-Header", rec.Header().Get("Access-Control-Expose-Headers"))
 	})
 }
+
+type corsBindings struct {
+	TenantOrigins []string
+}
+
+func TestCors_OriginMatching(t *testing.T) {
+	app := takibi.New(&corsBindings{TenantOrigins: []string{"https://shop.tenant.test"}})
+	app.Use("*", middlewares.CorsWithOriginFunc(func(c interfaces.IContext[corsBindings], origin string) bool {
+		return slices.Contains(c.Env().TenantOrigins, origin)
+	}, middlewares.CorsConfig{
+		AllowOrigins: []string{"https://app.example.com", "https://*.example.org"},
+	}))
+	app.Get("/", func(c interfaces.IContext[corsBindings]) error {
+		return c.Text("ok")
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"http://a.example.org", false},
+		{"https://evil.com/.example.org", false},
+		{"https://a.example.org.evil.com", false},
+		{"https://shop.tenant.test", true},
+		{"https://other.tenant.test", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			res := app.Camp(http.MethodGet, "/", interfaces.Header("Origin", tt.origin))
+
+			if tt.allowed {
+				assert.Equal(t, tt.origin, res.Raw().Header.Get("Access-Control-Allow-Origin"))
+			} else {
+				assert.Empty(t, res.Raw().Header.Get("Access-Control-Allow-Origin"))
+			}
+			assert.Equal(t, "Origin", res.Raw().Header.Get("Vary"))
+		})
+	}
+
+	t.Run("vary without origin", func(t *testing.T) {
+		res := app.Camp(http.MethodGet, "/")
+		assert.Equal(t, "Origin", res.Raw().Header.Get("Vary"))
+	})
+}
+
+func TestCors_Preflight(t *testing.T) {
+	app := takibi.New[any](nil)
+	app.Use("*", middlewares.Cors[any](middlewares.CorsConfig{
+		AllowOrigins:        []string{"https://app.example.com"},
+		AllowMethods:        []string{http.MethodGet, http.MethodPut},
+		AllowPrivateNetwork: true,
+	}))
+	app.Options("/items", func(c interfaces.IContext[any]) error {
+		c.Response().Header().Set("Allow", "GET, PUT, OPTIONS")
+		return c.Status(http.StatusOK).Text("")
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		res := app.Camp(http.MethodOptions, "/items",
+			interfaces.Header("Origin", "https://app.example.com"),
+			interfaces.Header("Access-Control-Request-Method", http.MethodPut),
+			interfaces.Header("Access-Control-Request-Private-Network", "true"),
+		)
+		h := res.Raw().Header
+
+		assert.Equal(t, http.StatusNoContent, res.StatusCode())
+		assert.Equal(t, "GET, PUT", h.Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "true", h.Get("Access-Control-Allow-Private-Network"))
+		assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Access-Control-Request-Private-Network"}, h.Values("Vary"))
+		assert.Empty(t, h.Get("Allow"))
+	})
+
+	t.Run("disallowed origin gets no grants", func(t *testing.T) {
+		res := app.Camp(http.MethodOptions, "/items",
+			interfaces.Header("Origin", "https://evil.example"),
+			interfaces.Header("Access-Control-Request-Method", http.MethodPut),
+		)
+
+		assert.Equal(t, http.StatusNoContent, res.StatusCode())
+		assert.Empty(t, res.Raw().Header.Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, res.Raw().Header.Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("plain OPTIONS reaches the handler", func(t *testing.T) {
+		res := app.Camp(http.MethodOptions, "/items", interfaces.Header("Origin", "https://app.example.com"))
+
+		assert.Equal(t, http.StatusOK, res.StatusCode())
+		assert.Equal(t, "GET, PUT, OPTIONS", res.Raw().Header.Get("Allow"))
+		assert.Equal(t, "https://app.example.com", res.Raw().Header.Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, res.Raw().Header.Get("Access-Control-Allow-Methods"))
+	})
+}
@@ -0,0 +1,335 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/cookie"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// CSRF protection modes.
+const (
+	// CSRFModeToken requires a double-submit token on unsafe requests.
+	CSRFModeToken = "token"
+	// CSRFModeOrigin rejects unsafe cross-origin requests by their
+	// Sec-Fetch-Site or Origin header; no token or cookie is involved.
+	CSRFModeOrigin = "origin"
+	// CSRFModeTokenAndOrigin applies both checks.
+	CSRFModeTokenAndOrigin = "token+origin"
+)
+
+// Reasons an unsafe request is rejected. They are passed to
+// CSRFConfig.Forbidden.
+var (
+	ErrCSRFTokenMissing  = errors.New("csrf: token missing")
+	ErrCSRFTokenMismatch = errors.New("csrf: token mismatch")
+	ErrCSRFCrossOrigin   = errors.New("csrf: cross-origin request")
+)
+
+type CSRFConfig[Bindings any] struct {
+	// Mode selects the checks; "" uses CSRFModeToken.
+	Mode string
+	// Secret signs the token cookie and must be at least
+	// constants.MinSignedCookieSecretLen bytes. Token modes require Secret or
+	// SecretFunc.
+	Secret string
+	// SecretFunc supplies the secret per request, e.g. from c.Env().
+	SecretFunc func(c interfaces.IContext[Bindings]) string
+	// CookieName names the token cookie; "" uses "_csrf".
+	CookieName string
+	// CookieOptions configures the token cookie; nil uses Path "/", HttpOnly,
+	// Secure and SameSite=Lax.
+	CookieOptions *cookie.CookieOptions
+	// TokenLookup lists where the submitted token is read from as
+	// comma-separated "source:name" pairs (header, form, query or cookie)
+	// tried in order; "" uses "header:X-CSRF-Token,form:_csrf".
+	TokenLookup string
+	// TrustedOrigins lists extra origins (e.g. "https://app.example.com")
+	// allowed to send unsafe requests in the origin modes. The request's own
+	// origin is always allowed.
+	TrustedOrigins []string
+	// Skip reports whether the request is exempt, e.g. a webhook endpoint.
+	Skip func(c interfaces.IContext[Bindings]) bool
+	// Forbidden writes the response for a rejected request, receiving one of
+	// the ErrCSRF* reasons; nil answers a plain 403.
+	Forbidden func(c interfaces.IContext[Bindings], err error) error
+}
+
+func DefaultCSRFConfig[Bindings any]() CSRFConfig[Bindings] {
+	return CSRFConfig[Bindings]{
+		Mode:        CSRFModeToken,
+		CookieName:  "_csrf",
+		TokenLookup: "header:X-CSRF-Token,form:_csrf",
+	}
+}
+
+var defaultCSRFCookieOptions = &cookie.CookieOptions{
+	Path:     "/",
+	HttpOnly: true,
+	Secure:   true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// csrfToken is what CSRF stores in the context for CSRFToken and CSRFField.
+type csrfToken struct {
+	value string
+	field string
+}
+
+// CSRF protects unsafe requests (anything but GET, HEAD, OPTIONS and TRACE)
+// against cross-site request forgery.
+//
+// In the token modes every request gets a random token, kept in a cookie
+// signed with cookie.SetSignedCookie so it can't be planted by a sibling
+// subdomain. Unsafe requests must echo the token through TokenLookup; handlers
+// read it with CSRFToken, and templ forms embed it with CSRFField. In the
+// origin modes an unsafe request passes only when Sec-Fetch-Site is
+// same-origin or none, or, for browsers without it, when Origin is the
+// request's own origin or one of TrustedOrigins; requests carrying neither
+// header (non-browser clients) pass.
+//
+//	app.Use("*", middlewares.CSRF(middlewares.CSRFConfig[Bindings]{
+//		SecretFunc: func(c interfaces.IContext[Bindings]) string { return c.Env().CSRFSecret },
+//	}))
+func CSRF[Bindings any](config ...CSRFConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultCSRFConfig[Bindings]()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = CSRFModeToken
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "_csrf"
+	}
+	if cfg.CookieOptions == nil {
+		cfg.CookieOptions = defaultCSRFCookieOptions
+	}
+	if cfg.TokenLookup == "" {
+		cfg.TokenLookup = "header:X-CSRF-Token,form:_csrf"
+	}
+
+	checkToken := cfg.Mode == CSRFModeToken || cfg.Mode == CSRFModeTokenAndOrigin
+	checkOrigin := cfg.Mode == CSRFModeOrigin || cfg.Mode == CSRFModeTokenAndOrigin
+	if !checkToken && !checkOrigin {
+		panic("middlewares: CSRF: unknown mode " + cfg.Mode)
+	}
+	if checkToken && cfg.SecretFunc == nil && len(cfg.Secret) < constants.MinSignedCookieSecretLen {
+		panic("middlewares: CSRF requires a Secret of at least 32 bytes or a SecretFunc")
+	}
+	extractors, err := parseCSRFTokenLookup(cfg.TokenLookup)
+	if err != nil {
+		panic("middlewares: CSRF: " + err.Error())
+	}
+	field := csrfFormField(cfg.TokenLookup)
+
+	forbidden := func(c interfaces.IContext[Bindings], err error) error {
+		if cfg.Forbidden != nil {
+			return cfg.Forbidden(c, err)
+		}
+		return c.Status(http.StatusForbidden).Text(http.StatusText(http.StatusForbidden))
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return next(c)
+		}
+		req := c.Req().Raw()
+		safe := isSafeMethod(req.Method)
+
+		if checkOrigin && !safe && !sameOriginRequest(c.Req(), cfg.TrustedOrigins) {
+			return forbidden(c, ErrCSRFCrossOrigin)
+		}
+
+		if checkToken {
+			secret := cfg.Secret
+			if cfg.SecretFunc != nil {
+				secret = cfg.SecretFunc(c)
+			}
+			if len(secret) < constants.MinSignedCookieSecretLen {
+				return errors.New("csrf: secret shorter than 32 bytes")
+			}
+
+			expected := ""
+			if stored, ok := cookie.GetSignedCookie(c, cfg.CookieName, secret, cfg.CookieOptions); ok {
+				expected = stored.Value
+			}
+
+			if !safe {
+				submitted := ""
+				for _, extract := range extractors {
+					if submitted = extract(c.Req()); submitted != "" {
+						break
+					}
+				}
+				switch {
+				case expected == "" || submitted == "":
+					return forbidden(c, ErrCSRFTokenMissing)
+				case !SecureCompare(expected, submitted):
+					return forbidden(c, ErrCSRFTokenMismatch)
+				}
+			}
+
+			if expected == "" {
+				expected = newCSRFToken()
+				if !cookie.SetSignedCookie(c, cfg.CookieName, expected, secret, cfg.CookieOptions) {
+					return errors.New("csrf: failed to set token cookie")
+				}
+			}
+			c.Set(constants.ContextKeyCSRFToken, csrfToken{value: expected, field: field})
+		}
+
+		return next(c)
+	}
+}
+
+// CSRFToken returns the request's CSRF token, or "" when CSRF is not
+// installed in a token mode. Send it back in the X-CSRF-Token header (or the
+// configured lookup) on unsafe requests.
+func CSRFToken(c interface {
+	Get(string) (any, bool)
+}) string {
+	if v, ok := c.Get(constants.ContextKeyCSRFToken); ok {
+		if t, ok := v.(csrfToken); ok {
+			return t.value
+		}
+	}
+	return ""
+}
+
+// CSRFField returns a templ component rendering the hidden form input that
+// carries the request's CSRF token, named after the first form source of
+// TokenLookup:
+//
+//	templ SignupForm(csrf templ.Component) {
+//		<form method="post">
+//			@csrf
+//		</form>
+//	}
+//
+//	c.Render(&interfaces.RenderConfig{Component: pages.SignupForm(middlewares.CSRFField(c))})
+func CSRFField(c interface {
+	Get(string) (any, bool)
+}) templ.Component {
+	var t csrfToken
+	if v, ok := c.Get(constants.ContextKeyCSRFToken); ok {
+		t, _ = v.(csrfToken)
+	}
+	return templ.ComponentFunc(func(_ context.Context, w io.Writer) error {
+		if t.value == "" {
+			return nil
+		}
+		_, err := io.WriteString(w, `<input type="hidden" name="`+html.EscapeString(t.field)+
+			`" value="`+html.EscapeString(t.value)+`">`)
+		return err
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// sameOriginRequest reports whether a browser request comes from the request's
+// own origin or a trusted one. The request's scheme and host are the ones
+// resolved through trusted proxies. Requests without Sec-Fetch-Site and Origin are not browser
+// form posts and pass.
+func sameOriginRequest(r interfaces.IRequest, trusted []string) bool {
+	origin := r.HeaderBy("Origin")
+	if origin != "" && slices.Contains(trusted, origin) {
+		return true
+	}
+	switch r.HeaderBy("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Scheme == r.Scheme() && u.Host == r.Host()
+}
+
+// parseCSRFTokenLookup turns a TokenLookup value into extractors. Besides
+// the sources of parseKeyLookup it reads "form", which only CSRF accepts: a
+// form token is a per-session value, not a credential.
+func parseCSRFTokenLookup(lookup string) ([]keyExtractor, error) {
+	var extractors []keyExtractor
+	for part := range strings.SplitSeq(lookup, ",") {
+		name, ok := strings.CutPrefix(strings.TrimSpace(part), "form:")
+		if !ok {
+			parsed, err := parseKeyLookup(part)
+			if err != nil {
+				return nil, err
+			}
+			extractors = append(extractors, parsed...)
+			continue
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid key lookup %q", part)
+		}
+		extractors = append(extractors, func(r interfaces.IRequest) string {
+			return csrfFormValue(r, name)
+		})
+	}
+	return extractors, nil
+}
+
+// csrfFormValue reads field from an urlencoded or multipart body, bounded by
+// the app's MaxBodyBytes since CSRF usually runs before any route BodyLimit.
+// The parsed form stays available to later binding.
+func csrfFormValue(r interfaces.IRequest, field string) string {
+	req := r.Raw()
+	if req.PostForm == nil && req.Body != nil && req.Body != http.NoBody {
+		limit := r.MaxBodyBytes()
+		req.Body = http.MaxBytesReader(nil, req.Body, limit)
+		var err error
+		switch r.MediaType() {
+		case "application/x-www-form-urlencoded":
+			err = req.ParseForm()
+		case "multipart/form-data":
+			err = req.ParseMultipartForm(limit)
+		default:
+			return ""
+		}
+		if err != nil {
+			return ""
+		}
+	}
+	return req.PostFormValue(field)
+}
+
+// csrfFormField returns the name of the first form source of lookup, or
+// "_csrf" when there is none.
+func csrfFormField(lookup string) string {
+	for part := range strings.SplitSeq(lookup, ",") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(part), "form:"); ok {
+			return name
+		}
+	}
+	return "_csrf"
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
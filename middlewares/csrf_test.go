@@ -0,0 +1,291 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+const csrfSecret = "0123456789abcdef0123456789abcdef"
+
+func csrfRoutes(app interfaces.ITakibi[authBindings]) {
+	app.Get("/form", func(c interfaces.IContext[authBindings]) error {
+		return c.Text(middlewares.CSRFToken(c))
+	})
+	app.Post("/submit", func(c interfaces.IContext[authBindings]) error {
+		return c.Text("ok")
+	})
+}
+
+// csrfForbidden answers rejections with 403 and the error message, keeping
+// the error in reason.
+func csrfForbidden(reason *error) func(c interfaces.IContext[authBindings], err error) error {
+	return func(c interfaces.IContext[authBindings], err error) error {
+		*reason = err
+		return c.Status(http.StatusForbidden).Text(err.Error())
+	}
+}
+
+// fetchCSRF performs a GET and returns the token and the signed cookie header.
+func fetchCSRF(t *testing.T, app interfaces.ITakibi[authBindings]) (string, string) {
+	t.Helper()
+	resp := app.Camp(http.MethodGet, "/form")
+	token, _ := readBody(resp)
+	cookies := resp.Raw().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected one cookie, got %d", len(cookies))
+	}
+	return token, cookies[0].Name + "=" + cookies[0].Value
+}
+
+func TestCSRF_Token(t *testing.T) {
+	reason := new(error)
+	app := newTestApp(&authBindings{Secret: csrfSecret}, csrfRoutes, middlewares.CSRF(middlewares.CSRFConfig[authBindings]{Secret: csrfSecret, Forbidden: csrfForbidden(reason)}))
+	token, cookieHeader := fetchCSRF(t, app)
+
+	t.Run("GET issues a token and a signed cookie", func(t *testing.T) {
+		assert.NotEmpty(t, token)
+		assert.NotContains(t, cookieHeader, token)
+		assert.True(t, strings.HasPrefix(cookieHeader, "_csrf="))
+	})
+
+	t.Run("token is stable while the cookie is sent", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/form", interfaces.Header("Cookie", cookieHeader))
+
+		body, _ := readBody(resp)
+		assert.Equal(t, token, body)
+		assert.Empty(t, resp.Raw().Cookies())
+	})
+
+	t.Run("header token passes", func(t *testing.T) {
+		resp := app.Camp(http.MethodPost, "/submit",
+			interfaces.Header("Cookie", cookieHeader),
+			interfaces.Header("X-CSRF-Token", token),
+		)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+	})
+
+	t.Run("form token passes", func(t *testing.T) {
+		form := url.Values{"_csrf": {token}, "name": {"takibi"}}
+		var name string
+		app.Post("/form-submit", func(c interfaces.IContext[authBindings]) error {
+			var dest struct {
+				Name string `form:"name"`
+			}
+			if err := c.Req().UnmarshallForm(&dest); err != nil {
+				return err
+			}
+			name = dest.Name
+			return c.Text("ok")
+		})
+
+		resp := app.Camp(http.MethodPost, "/form-submit",
+			interfaces.Header("Cookie", cookieHeader),
+			interfaces.Header("Content-Type", "application/x-www-form-urlencoded"),
+			interfaces.Body(strings.NewReader(form.Encode())),
+		)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, "takibi", name, "form stays readable after the token lookup")
+	})
+
+	tests := []struct {
+		name string
+		opts []interfaces.CampOption
+		want error
+	}{
+		{"no cookie", []interfaces.CampOption{interfaces.Header("X-CSRF-Token", token)}, middlewares.ErrCSRFTokenMissing},
+		{"no token", []interfaces.CampOption{interfaces.Header("Cookie", cookieHeader)}, middlewares.ErrCSRFTokenMissing},
+		{"wrong token", []interfaces.CampOption{interfaces.Header("Cookie", cookieHeader), interfaces.Header("X-CSRF-Token", "forged")}, middlewares.ErrCSRFTokenMismatch},
+		{"unsigned cookie", []interfaces.CampOption{interfaces.Header("Cookie", "_csrf=forged"), interfaces.Header("X-CSRF-Token", "forged")}, middlewares.ErrCSRFTokenMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*reason = nil
+
+			resp := app.Camp(http.MethodPost, "/submit", tt.opts...)
+
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+			assert.ErrorIs(t, *reason, tt.want)
+		})
+	}
+}
+
+func TestCSRF_Origin(t *testing.T) {
+	reason := new(error)
+	app := newTestApp(&authBindings{Secret: csrfSecret}, csrfRoutes, middlewares.CSRF(middlewares.CSRFConfig[authBindings]{
+		Mode:           middlewares.CSRFModeOrigin,
+		TrustedOrigins: []string{"https://app.example.com"},
+		Forbidden:      csrfForbidden(reason),
+	}))
+
+	tests := []struct {
+		name   string
+		opts   []interfaces.CampOption
+		status int
+	}{
+		{"same-origin fetch metadata", []interfaces.CampOption{interfaces.Header("Sec-Fetch-Site", "same-origin")}, http.StatusOK},
+		{"user-initiated navigation", []interfaces.CampOption{interfaces.Header("Sec-Fetch-Site", "none")}, http.StatusOK},
+		{"cross-site fetch metadata", []interfaces.CampOption{interfaces.Header("Sec-Fetch-Site", "cross-site")}, http.StatusForbidden},
+		{"same-site is not same-origin", []interfaces.CampOption{interfaces.Header("Sec-Fetch-Site", "same-site")}, http.StatusForbidden},
+		{"trusted origin", []interfaces.CampOption{interfaces.Header("Sec-Fetch-Site", "same-site"), interfaces.Header("Origin", "https://app.example.com")}, http.StatusOK},
+		{"matching Origin without fetch metadata", []interfaces.CampOption{interfaces.Header("Origin", "http://example.com")}, http.StatusOK},
+		{"foreign Origin without fetch metadata", []interfaces.CampOption{interfaces.Header("Origin", "https://evil.example")}, http.StatusForbidden},
+		{"Origin with another scheme", []interfaces.CampOption{interfaces.Header("Origin", "https://example.com")}, http.StatusForbidden},
+		{"non-browser request", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*reason = nil
+
+			resp := app.Camp(http.MethodPost, "http://example.com/submit", tt.opts...)
+
+			assert.Equal(t, tt.status, resp.StatusCode())
+			if tt.status == http.StatusForbidden {
+				assert.ErrorIs(t, *reason, middlewares.ErrCSRFCrossOrigin)
+			}
+		})
+	}
+
+	t.Run("no cookie is issued", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/form")
+
+		assert.Empty(t, resp.Raw().Cookies())
+	})
+}
+
+func TestCSRF_TokenAndOrigin(t *testing.T) {
+	reason := new(error)
+	app := newTestApp(&authBindings{Secret: csrfSecret}, csrfRoutes, middlewares.CSRF(middlewares.CSRFConfig[authBindings]{
+		Mode: middlewares.CSRFModeTokenAndOrigin,
+		SecretFunc: func(c interfaces.IContext[authBindings]) string {
+			return c.Env().Secret
+		},
+		Forbidden: csrfForbidden(reason),
+	}))
+	token, cookieHeader := fetchCSRF(t, app)
+
+	resp := app.Camp(http.MethodPost, "/submit",
+		interfaces.Header("Cookie", cookieHeader),
+		interfaces.Header("X-CSRF-Token", token),
+		interfaces.Header("Sec-Fetch-Site", "cross-site"),
+	)
+
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+	assert.ErrorIs(t, *reason, middlewares.ErrCSRFCrossOrigin)
+}
+
+func TestCSRF_Options(t *testing.T) {
+	t.Run("Skip exempts a route", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: csrfSecret}, csrfRoutes, middlewares.CSRF(middlewares.CSRFConfig[authBindings]{
+			Secret: csrfSecret,
+			Skip: func(c interfaces.IContext[authBindings]) bool {
+				return c.Req().Raw().URL.Path == "/submit"
+			},
+		}))
+
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodPost, "/submit").StatusCode())
+	})
+
+	t.Run("query lookup", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: csrfSecret}, csrfRoutes, middlewares.CSRF(middlewares.CSRFConfig[authBindings]{
+			Secret:      csrfSecret,
+			TokenLookup: "query:csrf",
+		}))
+		token, cookieHeader := fetchCSRF(t, app)
+
+		resp := app.Camp(http.MethodPost, "/submit?csrf="+url.QueryEscape(token), interfaces.Header("Cookie", cookieHeader))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+	})
+
+	t.Run("form token is read within MaxBodyBytes", func(t *testing.T) {
+		app := takibi.NewWithOption(&authBindings{}, interfaces.TakibiOption{MaxBodyBytes: 64})
+		app.Use("*", middlewares.CSRF(middlewares.CSRFConfig[authBindings]{Secret: csrfSecret}))
+		csrfRoutes(app)
+		token, cookieHeader := fetchCSRF(t, app)
+		post := func(form url.Values) int {
+			return app.Camp(http.MethodPost, "/submit",
+				interfaces.Header("Cookie", cookieHeader),
+				interfaces.Header("Content-Type", "application/x-www-form-urlencoded"),
+				interfaces.Body(strings.NewReader(form.Encode())),
+			).StatusCode()
+		}
+
+		assert.Equal(t, http.StatusOK, post(url.Values{"_csrf": {token}}))
+		assert.Equal(t, http.StatusForbidden, post(url.Values{"_csrf": {token}, "pad": {strings.Repeat("x", 100)}}))
+	})
+
+	t.Run("default rejection is a plain 403", func(t *testing.T) {
+		app := newTestApp(&authBindings{}, csrfRoutes, middlewares.CSRF(middlewares.CSRFConfig[authBindings]{Secret: csrfSecret}))
+
+		resp := app.Camp(http.MethodPost, "/submit")
+
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+	})
+
+	t.Run("short SecretFunc secret is an error", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: csrfSecret}, csrfRoutes, middlewares.CSRF(middlewares.CSRFConfig[authBindings]{
+			SecretFunc: func(c interfaces.IContext[authBindings]) string { return "short" },
+		}))
+
+		assert.Equal(t, http.StatusInternalServerError, app.Camp(http.MethodGet, "/form").StatusCode())
+	})
+
+	t.Run("misconfiguration panics", func(t *testing.T) {
+		assert.Panics(t, func() { middlewares.CSRF[authBindings]() })
+		assert.Panics(t, func() {
+			middlewares.CSRF(middlewares.CSRFConfig[authBindings]{Secret: "short"})
+		})
+		assert.Panics(t, func() {
+			middlewares.CSRF(middlewares.CSRFConfig[authBindings]{Mode: "strict", Secret: csrfSecret})
+		})
+	})
+}
+
+func TestCSRFField(t *testing.T) {
+	var rendered string
+	app := newTestApp(&authBindings{}, func(app interfaces.ITakibi[authBindings]) {
+		app.Get("/", func(c interfaces.IContext[authBindings]) error {
+			var buf bytes.Buffer
+			if err := middlewares.CSRFField(c).Render(context.Background(), &buf); err != nil {
+				return err
+			}
+			rendered = buf.String()
+			return c.Text(middlewares.CSRFToken(c))
+		})
+	}, middlewares.CSRF(middlewares.CSRFConfig[authBindings]{
+		Secret:      csrfSecret,
+		TokenLookup: "header:X-CSRF-Token,form:authenticity_token",
+	}))
+
+	resp := app.Camp(http.MethodGet, "/")
+
+	token, _ := readBody(resp)
+	assert.Equal(t, `<input type="hidden" name="authenticity_token" value="`+token+`">`, rendered)
+
+	t.Run("renders nothing without the middleware", func(t *testing.T) {
+		app := takibi.New(&authBindings{})
+		var err error
+		app.Get("/", func(c interfaces.IContext[authBindings]) error {
+			var buf bytes.Buffer
+			err = middlewares.CSRFField(c).Render(context.Background(), &buf)
+			rendered = buf.String()
+			return nil
+		})
+
+		app.Camp(http.MethodGet, "/")
+
+		assert.NoError(t, err)
+		assert.Empty(t, rendered)
+	})
+}
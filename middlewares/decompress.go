@@ -0,0 +1,150 @@
+package middlewares
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// Decoder opens a decompressing reader over a request body.
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+type DecompressConfig struct {
+	// MaxBytes caps the decompressed body. 0 uses the limit of an enclosing
+	// BodyLimit, or TakibiOption.MaxBodyBytes without one.
+	MaxBytes int64
+	// Decoders maps Content-Encoding tokens to decoders; nil supports gzip,
+	// x-gzip and deflate.
+	Decoders map[string]Decoder
+}
+
+func DefaultDecompressConfig() DecompressConfig {
+	return DecompressConfig{
+		Decoders: map[string]Decoder{
+			"gzip":    gzipDecoder,
+			"x-gzip":  gzipDecoder,
+			"deflate": deflateDecoder,
+		},
+	}
+}
+
+// Decompress transparently inflates request bodies sent with a supported
+// Content-Encoding, so handlers and validators read plain bytes. The inflated
+// size is capped (see DecompressConfig.MaxBytes), which defeats zip bombs: a
+// body inflating past the cap fails the read with an error matching
+// ErrBodyTooLarge (413). An unknown encoding is rejected with
+// ErrUnsupportedEncoding (415) and a corrupt body with ErrMalformedBody (400).
+//
+// Register it after BodyLimit so the limit applies to the inflated body too:
+//
+//	app.Use("/api/*", middlewares.BodyLimit[Bindings](1<<20))
+//	app.Use("/api/*", middlewares.Decompress[Bindings]())
+func Decompress[Bindings any](config ...DecompressConfig) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultDecompressConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Decoders == nil {
+		cfg.Decoders = DefaultDecompressConfig().Decoders
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		req := c.Req().Raw()
+		encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || req.Body == nil || req.Body == http.NoBody {
+			return next(c)
+		}
+		decode, ok := cfg.Decoders[encoding]
+		if !ok {
+			return &statusError{
+				code: http.StatusUnsupportedMediaType,
+				err:  fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding),
+			}
+		}
+
+		limit := cfg.MaxBytes
+		if limit <= 0 {
+			limit = c.Req().MaxBodyBytes()
+			if v, ok := c.Get(constants.ContextKeyBodyLimit); ok {
+				if n, ok := v.(int64); ok {
+					limit = n
+				}
+			}
+		}
+
+		decoded, err := decode(req.Body)
+		if err != nil {
+			// BodyLimit may cut the body off inside the encoding header
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return &statusError{code: http.StatusRequestEntityTooLarge, err: fmt.Errorf("%w: %w", ErrBodyTooLarge, err)}
+			}
+			return malformedBody(err)
+		}
+		req.Body = http.MaxBytesReader(c.Response(), &decodeErrorReader{r: decoded, body: req.Body}, limit)
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+		req.ContentLength = -1
+
+		err = next(c)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) && !errors.Is(err, ErrBodyTooLarge) {
+			return &statusError{code: http.StatusRequestEntityTooLarge, err: fmt.Errorf("%w: %w", ErrBodyTooLarge, err)}
+		}
+		return err
+	}
+}
+
+// decodeErrorReader reports decoding failures as ErrMalformedBody while
+// passing through errors of the underlying body (e.g. a BodyLimit overflow).
+type decodeErrorReader struct {
+	r    io.ReadCloser
+	body io.ReadCloser
+}
+
+func (d *decodeErrorReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if !errors.As(err, &tooLarge) {
+			err = malformedBody(err)
+		}
+	}
+	return n, err
+}
+
+func (d *decodeErrorReader) Close() error {
+	d.r.Close()
+	return d.body.Close()
+}
+
+func malformedBody(err error) error {
+	return &statusError{code: http.StatusBadRequest, err: fmt.Errorf("%w: %w", ErrMalformedBody, err)}
+}
+
+func gzipDecoder(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// deflateDecoder accepts the zlib-wrapped stream HTTP specifies for
+// "deflate" as well as the raw deflate some clients send.
+func deflateDecoder(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
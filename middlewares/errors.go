@@ -0,0 +1,28 @@
+package middlewares
+
+import "errors"
+
+// Request errors answered with a specific status by the default error handler;
+// match them with errors.Is.
+var (
+	// ErrBodyTooLarge is returned for bodies over the BodyLimit or
+	// Decompress limit (413).
+	ErrBodyTooLarge = errors.New("request body too large")
+	// ErrUnsupportedEncoding is returned by Decompress for a
+	// Content-Encoding it can't decode (415).
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+	// ErrMalformedBody is returned by Decompress for a body that is not valid
+	// for its Content-Encoding (400).
+	ErrMalformedBody = errors.New("malformed request body")
+)
+
+// statusError carries the status the default error handler answers with
+// (interfaces.IStatusError) while unwrapping to its cause.
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string   { return e.err.Error() }
+func (e *statusError) Unwrap() error   { return e.err }
+func (e *statusError) StatusCode() int { return e.code }
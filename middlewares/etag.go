@@ -0,0 +1,282 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// Conditional request outcomes returned by CheckConditional.
+var (
+	// ErrNotModified means the client's cached copy is current. ETag answers
+	// it with a bodiless 304.
+	ErrNotModified = &statusError{code: http.StatusNotModified, err: errors.New("not modified")}
+	// ErrPreconditionFailed means an If-Match or If-Unmodified-Since
+	// precondition failed; the default error handler answers 412.
+	ErrPreconditionFailed = &statusError{code: http.StatusPreconditionFailed, err: errors.New("precondition failed")}
+)
+
+type ETagConfig struct {
+	// Weak generates weak (W/"...") ETags, for bodies that are semantically
+	// but not byte-for-byte stable.
+	Weak bool
+	// MaxBufferBytes is the largest body buffered to compute an ETag; larger
+	// or flushed (streamed) responses are sent without one.
+	MaxBufferBytes int
+}
+
+func DefaultETagConfig() ETagConfig {
+	return ETagConfig{
+		MaxBufferBytes: 1 << 20,
+	}
+}
+
+// ETag buffers successful GET and HEAD responses to tag them with an ETag
+// computed from the body, unless the handler set one itself, and answers a
+// matching If-None-Match (or, without one, a satisfied If-Modified-Since
+// against Last-Modified) with 304 Not Modified. A HEAD response is only
+// tagged when its handler wrote the body GET would send; a bodiless one is
+// left untagged rather than given the tag of an empty body.
+//
+// For other methods the resource's version is only known to the handler, so
+// If-Match and If-Unmodified-Since are checked by handlers calling
+// CheckConditional before changing it; ETag answers the ErrPreconditionFailed
+// it returns with 412 itself, so a custom OnError does not need to map it.
+//
+//	app.Use("*", middlewares.ETag[Bindings]())
+func ETag[Bindings any](config ...ETagConfig) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultETagConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.MaxBufferBytes <= 0 {
+		cfg.MaxBufferBytes = DefaultETagConfig().MaxBufferBytes
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		req := c.Req().Raw()
+		res := c.Response()
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			err := next(c)
+			if errors.Is(err, ErrPreconditionFailed) &&
+				(req.Header.Get("If-Match") != "" || req.Header.Get("If-Unmodified-Since") != "") {
+				return c.Status(http.StatusPreconditionFailed).Text(http.StatusText(http.StatusPreconditionFailed))
+			}
+			return err
+		}
+
+		ew := &etagWriter{ResponseWriter: res, max: cfg.MaxBufferBytes}
+		c.SetResponse(ew)
+		err := next(c)
+		c.SetResponse(res)
+
+		if errors.Is(err, ErrNotModified) {
+			writeNotModified(res)
+			return nil
+		}
+		if ew.passthrough {
+			return err
+		}
+		if err != nil {
+			// keep whatever the handler wrote before failing
+			ew.commit()
+			return err
+		}
+
+		h := res.Header()
+		if ew.status == 0 && len(ew.buf) == 0 {
+			return nil
+		}
+		if ew.status == 0 || ew.status == http.StatusOK {
+			if h.Get("ETag") == "" && (req.Method == http.MethodGet || len(ew.buf) > 0) {
+				h.Set("ETag", computeETag(ew.buf, cfg.Weak))
+			}
+			if notModified(req, h.Get("ETag"), h.Get("Last-Modified")) {
+				writeNotModified(res)
+				return nil
+			}
+		}
+		ew.commit()
+		return nil
+	}
+}
+
+// CheckConditional sets the ETag and Last-Modified headers from the
+// resource's current validators (either may be empty/zero) and evaluates the
+// request's conditional headers against them. Return its error unchanged:
+//
+//   - GET/HEAD: ErrNotModified when the client's copy is current (If-None-Match,
+//     or If-Modified-Since without it); ETag turns it into a 304.
+//   - other methods: ErrPreconditionFailed (412) when If-Match, or
+//     If-Unmodified-Since without it, does not hold.
+//
+// etag may be given bare (v42), quoted ("v42") or weak (W/"v42").
+//
+//	if err := middlewares.CheckConditional(c, doc.Version, doc.UpdatedAt); err != nil {
+//		return err
+//	}
+func CheckConditional[Bindings any](c interfaces.IContext[Bindings], etag string, lastModified time.Time) error {
+	h := c.Response().Header()
+	if etag != "" {
+		etag = quoteETag(etag)
+		h.Set("ETag", etag)
+	}
+	lm := ""
+	if !lastModified.IsZero() {
+		lm = lastModified.UTC().Format(http.TimeFormat)
+		h.Set("Last-Modified", lm)
+	}
+
+	req := c.Req().Raw()
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		if notModified(req, etag, lm) {
+			return ErrNotModified
+		}
+		return nil
+	}
+
+	if ifMatch := req.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, true) {
+			return ErrPreconditionFailed
+		}
+		return nil
+	}
+	if since, err := http.ParseTime(req.Header.Get("If-Unmodified-Since")); err == nil && lm != "" {
+		if lastModified.Truncate(time.Second).After(since) {
+			return ErrPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since without it.
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && matchETag(inm, etag, false)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified == "" {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	return err == nil && !modified.After(since)
+}
+
+// matchETag reports whether etag is listed in an If-Match/If-None-Match
+// value, using the strong or weak comparison of RFC 9110.
+func matchETag(header, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strong {
+			if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// writeNotModified sends a 304, dropping the representation headers that
+// describe a body it does not have.
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// etagWriter buffers a response up to max bytes; beyond that, or on Flush, it
+// passes everything through untagged.
+type etagWriter struct {
+	http.ResponseWriter
+	max         int
+	status      int
+	buf         []byte
+	passthrough bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if len(w.buf)+len(b) > w.max {
+		if err := w.commit(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	return len(b), nil
+}
+
+// Flush switches to pass-through so streamed responses reach the client.
+func (w *etagWriter) Flush() {
+	if !w.passthrough {
+		_ = w.commit()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// commit sends the buffered status and body and switches to pass-through.
+func (w *etagWriter) commit() error {
+	w.passthrough = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
@@ -0,0 +1,193 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+var docUpdated = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func etagRoutes(app interfaces.ITakibi[any]) {
+	app.Get("/page", func(c interfaces.IContext[any]) error {
+		return c.Text("<h1>docs</h1>")
+	})
+	app.Head("/page", func(c interfaces.IContext[any]) error {
+		return c.Text("<h1>docs</h1>")
+	})
+	app.Head("/headers-only", func(c interfaces.IContext[any]) error {
+		c.Response().Header().Set("Content-Type", "text/plain")
+		return c.Status(http.StatusOK).Text("")
+	})
+	app.Get("/large", func(c interfaces.IContext[any]) error {
+		return c.Text(strings.Repeat("x", 64))
+	})
+	app.Get("/missing", func(c interfaces.IContext[any]) error {
+		return c.Status(http.StatusNotFound).Text("nope")
+	})
+	app.Get("/doc", func(c interfaces.IContext[any]) error {
+		if err := middlewares.CheckConditional(c, "v2", docUpdated); err != nil {
+			return err
+		}
+		return c.Text("doc v2")
+	})
+	app.Put("/doc", func(c interfaces.IContext[any]) error {
+		if err := middlewares.CheckConditional(c, "v2", docUpdated); err != nil {
+			return err
+		}
+		return c.Text("updated")
+	})
+}
+
+func TestETag_Computed(t *testing.T) {
+	app := newTestApp(nil, etagRoutes, middlewares.ETag[any]())
+
+	first := app.Camp(http.MethodGet, "/page")
+	etag := first.Raw().Header.Get("ETag")
+
+	assert.Equal(t, http.StatusOK, first.StatusCode())
+	assert.Regexp(t, `^"[A-Za-z0-9_-]{22}"$`, etag)
+	body, _ := readBody(first)
+	assert.Equal(t, "<h1>docs</h1>", body)
+
+	t.Run("stable across requests", func(t *testing.T) {
+		assert.Equal(t, etag, app.Camp(http.MethodGet, "/page").Raw().Header.Get("ETag"))
+	})
+
+	t.Run("matching If-None-Match is a 304", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/page", interfaces.Header("If-None-Match", `"other", `+etag))
+
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode())
+		assert.Equal(t, etag, resp.Raw().Header.Get("ETag"))
+		assert.Empty(t, resp.Raw().Header.Get("Content-Type"))
+		body, _ := readBody(resp)
+		assert.Empty(t, body)
+	})
+
+	t.Run("weak comparison for If-None-Match", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/page", interfaces.Header("If-None-Match", "W/"+etag))
+
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode())
+	})
+
+	t.Run("stale If-None-Match gets the body", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/page", interfaces.Header("If-None-Match", `"stale"`))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+	})
+
+	t.Run("HEAD shares the GET tag", func(t *testing.T) {
+		resp := app.Camp(http.MethodHead, "/page")
+
+		assert.Equal(t, etag, resp.Raw().Header.Get("ETag"))
+	})
+
+	t.Run("bodiless HEAD is not tagged", func(t *testing.T) {
+		resp := app.Camp(http.MethodHead, "/headers-only")
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Empty(t, resp.Raw().Header.Get("ETag"))
+	})
+
+	t.Run("non-200 responses are not tagged", func(t *testing.T) {
+		resp := app.Camp(http.MethodGet, "/missing")
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+		assert.Empty(t, resp.Raw().Header.Get("ETag"))
+	})
+}
+
+func TestETag_Options(t *testing.T) {
+	t.Run("weak tags", func(t *testing.T) {
+		app := newTestApp(nil, etagRoutes, middlewares.ETag[any](middlewares.ETagConfig{Weak: true}))
+
+		etag := app.Camp(http.MethodGet, "/page").Raw().Header.Get("ETag")
+
+		assert.True(t, strings.HasPrefix(etag, `W/"`))
+		assert.Equal(t, http.StatusNotModified, app.Camp(http.MethodGet, "/page", interfaces.Header("If-None-Match", etag)).StatusCode())
+	})
+
+	t.Run("bodies over the buffer are sent untagged", func(t *testing.T) {
+		app := newTestApp(nil, etagRoutes, middlewares.ETag[any](middlewares.ETagConfig{MaxBufferBytes: 32}))
+
+		resp := app.Camp(http.MethodGet, "/large")
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Empty(t, resp.Raw().Header.Get("ETag"))
+		body, _ := readBody(resp)
+		assert.Len(t, body, 64)
+	})
+}
+
+func TestCheckConditional(t *testing.T) {
+	app := newTestApp(nil, etagRoutes, middlewares.ETag[any]())
+	lastModified := docUpdated.Format(http.TimeFormat)
+
+	tests := []struct {
+		name   string
+		method string
+		header [2]string
+		status int
+	}{
+		{"GET without conditions", http.MethodGet, [2]string{}, http.StatusOK},
+		{"GET If-None-Match bare tag", http.MethodGet, [2]string{"If-None-Match", `"v2"`}, http.StatusNotModified},
+		{"GET If-None-Match wildcard", http.MethodGet, [2]string{"If-None-Match", "*"}, http.StatusNotModified},
+		{"GET If-None-Match old", http.MethodGet, [2]string{"If-None-Match", `"v1"`}, http.StatusOK},
+		{"GET If-Modified-Since current", http.MethodGet, [2]string{"If-Modified-Since", lastModified}, http.StatusNotModified},
+		{"GET If-Modified-Since older", http.MethodGet, [2]string{"If-Modified-Since", docUpdated.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{"PUT without conditions", http.MethodPut, [2]string{}, http.StatusOK},
+		{"PUT If-Match current", http.MethodPut, [2]string{"If-Match", `"v2"`}, http.StatusOK},
+		{"PUT If-Match wildcard", http.MethodPut, [2]string{"If-Match", "*"}, http.StatusOK},
+		{"PUT If-Match stale", http.MethodPut, [2]string{"If-Match", `"v1"`}, http.StatusPreconditionFailed},
+		{"PUT If-Match weak never matches", http.MethodPut, [2]string{"If-Match", `W/"v2"`}, http.StatusPreconditionFailed},
+		{"PUT If-Unmodified-Since current", http.MethodPut, [2]string{"If-Unmodified-Since", lastModified}, http.StatusOK},
+		{"PUT If-Unmodified-Since older", http.MethodPut, [2]string{"If-Unmodified-Since", docUpdated.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []interfaces.CampOption
+			if tt.header[0] != "" {
+				opts = append(opts, interfaces.Header(tt.header[0], tt.header[1]))
+			}
+
+			resp := app.Camp(tt.method, "/doc", opts...)
+
+			assert.Equal(t, tt.status, resp.StatusCode())
+			assert.Equal(t, `"v2"`, resp.Raw().Header.Get("ETag"))
+			assert.Equal(t, lastModified, resp.Raw().Header.Get("Last-Modified"))
+		})
+	}
+
+	t.Run("failed precondition is a 412 under a custom OnError", func(t *testing.T) {
+		app := newTestApp(nil, etagRoutes, middlewares.ETag[any]())
+		app.OnError(func(c interfaces.IContext[any], err error) error {
+			return c.Status(http.StatusInternalServerError).Text("error")
+		})
+
+		resp := app.Camp(http.MethodPut, "/doc", interfaces.Header("If-Match", `"v1"`))
+
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode())
+		body, _ := readBody(resp)
+		assert.Equal(t, "Precondition Failed", body)
+	})
+
+	t.Run("without the middleware ErrNotModified still answers 304", func(t *testing.T) {
+		app := takibi.New[any](nil)
+		app.Get("/doc", func(c interfaces.IContext[any]) error {
+			if err := middlewares.CheckConditional(c, "v2", time.Time{}); err != nil {
+				return err
+			}
+			return c.Text("doc")
+		})
+
+		resp := app.Camp(http.MethodGet, "/doc", interfaces.Header("If-None-Match", `"v2"`))
+
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode())
+	})
+}
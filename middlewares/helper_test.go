@@ -0,0 +1,30 @@
+package middlewares_test
+
+import (
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// newTestApp returns an app on env running mws on every route, with the
+// routes under test registered by routes, for tests driving a middleware
+// through routing and the error handler with Camp.
+func newTestApp[Bindings any](env *Bindings, routes func(app interfaces.ITakibi[Bindings]), mws ...interfaces.MiddlewareFunc[Bindings]) interfaces.ITakibi[Bindings] {
+	app := takibi.New(env)
+	for _, mw := range mws {
+		app.Use("*", mw)
+	}
+	routes(app)
+	return app
+}
+
+// recordErrors makes app answer errors with their status (see
+// interfaces.StatusCodeOf) and message, and returns where the last one is
+// kept.
+func recordErrors[Bindings any](app interfaces.ITakibi[Bindings]) *error {
+	var seen error
+	app.OnError(func(c interfaces.IContext[Bindings], err error) error {
+		seen = err
+		return c.Status(interfaces.StatusCodeOf(err)).Text(err.Error())
+	})
+	return &seen
+}
@@ -0,0 +1,143 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/thttp"
+)
+
+// ErrIPForbidden is the reason IPFilter passes to IPFilterConfig.Forbidden;
+// the error also names the rejected address.
+var ErrIPForbidden = errors.New("ip filter: address not allowed")
+
+// IPRules is a parsed allow/deny list. Deny wins over allow; a non-empty allow
+// list rejects every address it does not contain.
+type IPRules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewIPRules parses allow and deny lists of CIDRs ("10.0.0.0/8") and single
+// addresses ("192.0.2.1").
+func NewIPRules(allow, deny []string) (*IPRules, error) {
+	allowed, err := thttp.ParsePrefixes(allow)
+	if err != nil {
+		return nil, fmt.Errorf("ip filter: allow: %w", err)
+	}
+	denied, err := thttp.ParsePrefixes(deny)
+	if err != nil {
+		return nil, fmt.Errorf("ip filter: deny: %w", err)
+	}
+	return &IPRules{allow: allowed, deny: denied}, nil
+}
+
+// Allowed reports whether ip passes the rules. An unparsable ip never does.
+func (r *IPRules) Allowed(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	if containsAddr(r.deny, addr) {
+		return false
+	}
+	return len(r.allow) == 0 || containsAddr(r.allow, addr)
+}
+
+// IPFilterList holds IPRules that can be replaced at runtime, e.g. when the
+// office ranges are reloaded from configuration. Requests in flight keep the
+// rules they started with.
+type IPFilterList struct {
+	rules atomic.Pointer[IPRules]
+}
+
+// NewIPFilterList returns a list holding NewIPRules(allow, deny).
+func NewIPFilterList(allow, deny []string) (*IPFilterList, error) {
+	l := &IPFilterList{}
+	if err := l.Update(allow, deny); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update parses the new lists and swaps them in atomically. On error the
+// current rules stay in force.
+func (l *IPFilterList) Update(allow, deny []string) error {
+	rules, err := NewIPRules(allow, deny)
+	if err != nil {
+		return err
+	}
+	l.rules.Store(rules)
+	return nil
+}
+
+// Rules returns the rules currently in force.
+func (l *IPFilterList) Rules() *IPRules {
+	return l.rules.Load()
+}
+
+type IPFilterConfig[Bindings any] struct {
+	// Allow lists the CIDRs and addresses admitted; empty admits every
+	// address not denied.
+	Allow []string
+	// Deny lists the CIDRs and addresses rejected, even when allowed.
+	Deny []string
+	// List supplies reloadable rules instead of Allow and Deny.
+	List *IPFilterList
+	// Forbidden writes the response for a rejected request, receiving an
+	// error wrapping ErrIPForbidden; nil answers a plain 403.
+	Forbidden func(c interfaces.IContext[Bindings], err error) error
+}
+
+// IPFilter admits requests by client IP, as resolved by Req().RealIP()
+// through TakibiOption.TrustedProxies, so a forwarded address is only
+// believed when a trusted proxy sent it. A request whose address cannot be
+// determined is rejected. IPFilter panics when the config has neither lists
+// nor List, or a list entry is invalid.
+//
+// Restrict a sub-app before mounting it:
+//
+//	admin := takibi.New[Bindings](nil)
+//	admin.Use("*", middlewares.IPFilter(middlewares.IPFilterConfig[Bindings]{
+//		Allow: []string{"203.0.113.0/24", "2001:db8:10::/48"},
+//	}))
+//	admin.Get("/users", listUsers)
+//	app.Route("/admin", admin)
+func IPFilter[Bindings any](config IPFilterConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	list := config.List
+	if list == nil {
+		if len(config.Allow) == 0 && len(config.Deny) == 0 {
+			panic("middlewares: IPFilter requires Allow, Deny or List")
+		}
+		var err error
+		if list, err = NewIPFilterList(config.Allow, config.Deny); err != nil {
+			panic("middlewares: IPFilter: " + err.Error())
+		}
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		ip := c.Req().RealIP()
+		if list.Rules().Allowed(ip) {
+			return next(c)
+		}
+		err := fmt.Errorf("%w: %q", ErrIPForbidden, ip)
+		if config.Forbidden != nil {
+			return config.Forbidden(c, err)
+		}
+		return c.Status(http.StatusForbidden).Text(http.StatusText(http.StatusForbidden))
+	}
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
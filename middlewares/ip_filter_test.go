@@ -0,0 +1,129 @@
+package middlewares_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveFrom(app interfaces.ITakibi[any], path, remoteAddr string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w
+}
+
+func TestIPRules_Allowed(t *testing.T) {
+	rules, err := middlewares.NewIPRules(
+		[]string{"203.0.113.0/24", "2001:db8::/32"},
+		[]string{"203.0.113.66"},
+	)
+	assert.NoError(t, err)
+
+	assert.True(t, rules.Allowed("203.0.113.5"))
+	assert.True(t, rules.Allowed("::ffff:203.0.113.5"))
+	assert.True(t, rules.Allowed("2001:db8::1"))
+	assert.False(t, rules.Allowed("203.0.113.66"), "deny wins")
+	assert.False(t, rules.Allowed("198.51.100.1"))
+	assert.False(t, rules.Allowed("not-an-ip"))
+
+	denyOnly, _ := middlewares.NewIPRules(nil, []string{"198.51.100.0/24"})
+	assert.True(t, denyOnly.Allowed("203.0.113.5"))
+	assert.False(t, denyOnly.Allowed("198.51.100.1"))
+
+	_, err = middlewares.NewIPRules([]string{"10.0.0.0/33"}, nil)
+	assert.ErrorContains(t, err, "ip filter: allow:")
+}
+
+func TestIPFilter_AdminSubApp(t *testing.T) {
+	admin := takibi.New[any](nil)
+	admin.Use("*", middlewares.IPFilter(middlewares.IPFilterConfig[any]{
+		Allow: []string{"203.0.113.0/24"},
+	}))
+	admin.Get("/users", func(c interfaces.IContext[any]) error {
+		return c.Text("users")
+	})
+
+	app := takibi.NewWithOption[any](nil, interfaces.TakibiOption{TrustedProxies: []string{"10.0.0.0/8"}})
+	app.Get("/", func(c interfaces.IContext[any]) error {
+		return c.Text("home")
+	})
+	assert.NoError(t, app.Route("/admin", admin))
+
+	t.Run("office address", func(t *testing.T) {
+		w := serveFrom(app, "/admin/users", "203.0.113.7:5000")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "users", w.Body.String())
+	})
+
+	t.Run("other address", func(t *testing.T) {
+		w := serveFrom(app, "/admin/users", "198.51.100.1:5000")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("forwarded by a trusted proxy", func(t *testing.T) {
+		w := serveFrom(app, "/admin/users", "10.0.0.2:5000", "X-Forwarded-For", "203.0.113.7")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("spoofed header from an untrusted peer", func(t *testing.T) {
+		w := serveFrom(app, "/admin/users", "198.51.100.1:5000", "X-Forwarded-For", "203.0.113.7")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("forged CF-Connecting-IP through a non-Cloudflare proxy", func(t *testing.T) {
+		w := serveFrom(app, "/admin/users", "10.0.0.5:5000", "CF-Connecting-IP", "203.0.113.9")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("rest of the app is unrestricted", func(t *testing.T) {
+		w := serveFrom(app, "/", "198.51.100.1:5000")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestIPFilter_ReloadAndCustomRejection(t *testing.T) {
+	list, err := middlewares.NewIPFilterList([]string{"203.0.113.0/24"}, nil)
+	assert.NoError(t, err)
+
+	var reason error
+	app := takibi.New[any](nil)
+	app.Use("*", middlewares.IPFilter(middlewares.IPFilterConfig[any]{
+		List: list,
+		Forbidden: func(c interfaces.IContext[any], err error) error {
+			reason = err
+			return c.Status(http.StatusNotFound).Text("not here")
+		},
+	}))
+	app.Get("/", func(c interfaces.IContext[any]) error {
+		return c.Text("ok")
+	})
+
+	w := serveFrom(app, "/", "198.51.100.1:5000")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.True(t, errors.Is(reason, middlewares.ErrIPForbidden))
+	assert.ErrorContains(t, reason, `"198.51.100.1"`)
+
+	assert.NoError(t, list.Update([]string{"198.51.100.0/24"}, nil))
+	assert.Equal(t, http.StatusOK, serveFrom(app, "/", "198.51.100.1:5000").Code)
+
+	assert.Error(t, list.Update([]string{"bogus"}, nil))
+	assert.Equal(t, http.StatusOK, serveFrom(app, "/", "198.51.100.1:5000").Code, "failed update keeps the rules")
+}
+
+func TestIPFilter_Misconfigured(t *testing.T) {
+	assert.Panics(t, func() { middlewares.IPFilter(middlewares.IPFilterConfig[any]{}) })
+	assert.Panics(t, func() {
+		middlewares.IPFilter(middlewares.IPFilterConfig[any]{Deny: []string{"10.0.0.0/99"}})
+	})
+}
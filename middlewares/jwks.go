@@ -0,0 +1,289 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// JWKSFetcher loads a raw JWKS document (RFC 7517).
+type JWKSFetcher func(ctx context.Context) ([]byte, error)
+
+// JWKSFromURL fetches the document over HTTP; a nil client uses
+// http.DefaultClient.
+func JWKSFromURL(url string, client *http.Client) JWKSFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwks: GET %s: %s", url, resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}
+}
+
+// JWKSFromFile reads the document from a local file, e.g. in tests.
+func JWKSFromFile(path string) JWKSFetcher {
+	return func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+type JWKSOption func(*JWKS)
+
+// WithJWKSTTL sets how long a fetched key set is used before it is refreshed
+// (default 1 hour).
+func WithJWKSTTL(ttl time.Duration) JWKSOption {
+	return func(j *JWKS) { j.ttl = ttl }
+}
+
+// WithJWKSMinRefresh sets the minimum interval between fetch attempts
+// (default 1 minute), so forged kids or a failing endpoint can't make every
+// request hit the issuer.
+func WithJWKSMinRefresh(interval time.Duration) JWKSOption {
+	return func(j *JWKS) { j.minRefresh = interval }
+}
+
+// JWKS caches the keys of a JWKS document. The set is refreshed when its TTL
+// expires, and early when a token names a kid the cached set lacks, which
+// picks up key rotations without waiting for the TTL. One request fetches
+// at a time while the others keep using the previous keys, which also stay
+// in use when a refresh fails, until a later refresh succeeds.
+type JWKS struct {
+	fetch      JWKSFetcher
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	mu          sync.Mutex
+	keys        []JWTKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// loading is closed when the fetch in flight completes; nil when idle.
+	loading chan struct{}
+	loadErr error
+}
+
+// NewJWKS returns a key set loaded lazily from fetch on first use.
+func NewJWKS(fetch JWKSFetcher, opts ...JWKSOption) *JWKS {
+	j := &JWKS{
+		fetch:      fetch,
+		ttl:        time.Hour,
+		minRefresh: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Keys returns the cached keys, refreshing them when the TTL has expired.
+func (j *JWKS) Keys(ctx context.Context) ([]JWTKey, error) {
+	return j.keysFor(ctx, "")
+}
+
+// keysFor returns the cached keys, refreshing them when the TTL has expired
+// or kid is not among them.
+func (j *JWKS) keysFor(ctx context.Context, kid string) ([]JWTKey, error) {
+	j.mu.Lock()
+	now := time.Now()
+	stale := j.fetchedAt.IsZero() || now.Sub(j.fetchedAt) >= j.ttl
+	unknown := kid != "" && !slices.ContainsFunc(j.keys, func(k JWTKey) bool { return k.ID == kid })
+	throttled := !j.lastAttempt.IsZero() && now.Sub(j.lastAttempt) < j.minRefresh
+	if !(stale || unknown) || throttled {
+		keys := j.keys
+		j.mu.Unlock()
+		return keys, nil
+	}
+
+	if loading := j.loading; loading != nil {
+		keys := j.keys
+		j.mu.Unlock()
+		if keys != nil {
+			return keys, nil
+		}
+		// nothing to serve yet: wait for the first fetch
+		select {
+		case <-loading:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if j.keys == nil {
+			return nil, j.loadErr
+		}
+		return j.keys, nil
+	}
+
+	loading := make(chan struct{})
+	j.loading, j.lastAttempt = loading, now
+	j.mu.Unlock()
+
+	keys, err := j.load(ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err == nil {
+		j.keys, j.fetchedAt = keys, now
+	}
+	j.loading, j.loadErr = nil, err
+	close(loading)
+	if j.keys == nil {
+		return nil, err
+	}
+	return j.keys, nil
+}
+
+func (j *JWKS) load(ctx context.Context) ([]JWTKey, error) {
+	raw, err := j.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(raw)
+}
+
+// jwk is one entry of a JWKS document.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS converts a JWKS document into verification keys. Keys marked for
+// encryption, key types JWT can't use and malformed keys are skipped; it
+// fails only when no usable key remains.
+func ParseJWKS(raw []byte) ([]JWTKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make([]JWTKey, 0, len(doc.Keys))
+	var keyErr error
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// one bad key must not take down the rest of the set
+			if keyErr == nil {
+				keyErr = fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+			}
+			continue
+		}
+		if key == nil {
+			continue
+		}
+		keys = append(keys, JWTKey{ID: k.Kid, Algorithm: k.Alg, Key: key})
+	}
+	if len(keys) == 0 {
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		return nil, errors.New("jwks: no usable keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key material, or returns nil for unsupported types.
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 point")
+		}
+		// uncompressed SEC 1 point, validated to lie on the curve
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, err
+		}
+		return pub, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		return secret, nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
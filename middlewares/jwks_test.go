@@ -0,0 +1,229 @@
+package middlewares_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]any) {
+	t.Helper()
+	raw, _ := json.Marshal(map[string]any{"keys": keys})
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func octJWK(kid string, secret []byte) map[string]any {
+	return map[string]any{"kty": "oct", "kid": kid, "alg": "HS256", "k": b64(secret)}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	ecPoint, _ := ecKey.PublicKey.Bytes()
+
+	raw, _ := json.Marshal(map[string]any{"keys": []map[string]any{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:])},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)},
+		octJWK("oct", []byte("secret")),
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+	}})
+
+	keys, err := middlewares.ParseJWKS(raw)
+
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 4) {
+		assert.Equal(t, "rsa", keys[0].ID)
+		assert.Equal(t, "RS256", keys[0].Algorithm)
+		assert.True(t, rsaKey.PublicKey.Equal(keys[0].Key))
+		assert.True(t, ecKey.PublicKey.Equal(keys[1].Key))
+		assert.Equal(t, edPub, keys[2].Key)
+		assert.Equal(t, []byte("secret"), keys[3].Key)
+	}
+
+	t.Run("invalid EC point", func(t *testing.T) {
+		raw, _ := json.Marshal(map[string]any{"keys": []map[string]any{
+			{"kty": "EC", "kid": "bad", "crv": "P-256", "x": b64(make([]byte, 32)), "y": b64(make([]byte, 32))},
+		}})
+
+		_, err := middlewares.ParseJWKS(raw)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("malformed key is skipped", func(t *testing.T) {
+		raw, _ := json.Marshal(map[string]any{"keys": []map[string]any{
+			{"kty": "EC", "kid": "bad", "crv": "P-256", "x": b64(make([]byte, 32)), "y": b64(make([]byte, 32))},
+			{"kty": "RSA", "kid": "bad-n", "n": "!", "e": "AQAB"},
+			octJWK("oct", []byte("secret")),
+		}})
+
+		keys, err := middlewares.ParseJWKS(raw)
+
+		assert.NoError(t, err)
+		if assert.Len(t, keys, 1) {
+			assert.Equal(t, "oct", keys[0].ID)
+		}
+	})
+
+	t.Run("no usable keys", func(t *testing.T) {
+		raw, _ := json.Marshal(map[string]any{"keys": []map[string]any{
+			{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+		}})
+
+		_, err := middlewares.ParseJWKS(raw)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestJWKS_RotationFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, octJWK("k1", []byte("one")))
+	claims := map[string]any{"exp": time.Now().Add(time.Minute).Unix()}
+
+	t.Run("unknown kid triggers a refresh", func(t *testing.T) {
+		writeJWKS(t, path, octJWK("k1", []byte("one")))
+		app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(nil), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+			JWKS: middlewares.NewJWKS(middlewares.JWKSFromFile(path), middlewares.WithJWKSMinRefresh(0)),
+		}))
+
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/", bearer(signJWT(t, "HS256", "k1", []byte("one"), claims))).StatusCode())
+
+		writeJWKS(t, path, octJWK("k2", []byte("two")))
+
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/", bearer(signJWT(t, "HS256", "k2", []byte("two"), claims))).StatusCode())
+	})
+
+	t.Run("refreshes are throttled", func(t *testing.T) {
+		writeJWKS(t, path, octJWK("k1", []byte("one")))
+		app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(nil), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+			JWKS: middlewares.NewJWKS(middlewares.JWKSFromFile(path), middlewares.WithJWKSMinRefresh(time.Hour)),
+		}))
+		app.Camp(http.MethodGet, "/", bearer(signJWT(t, "HS256", "k1", []byte("one"), claims)))
+
+		writeJWKS(t, path, octJWK("k2", []byte("two")))
+
+		assert.Equal(t, http.StatusUnauthorized, app.Camp(http.MethodGet, "/", bearer(signJWT(t, "HS256", "k2", []byte("two"), claims))).StatusCode())
+	})
+}
+
+func TestJWKS_Caching(t *testing.T) {
+	calls := 0
+	fail := false
+	jwks := middlewares.NewJWKS(func(context.Context) ([]byte, error) {
+		calls++
+		if fail {
+			return nil, errors.New("unreachable")
+		}
+		return json.Marshal(map[string]any{"keys": []map[string]any{octJWK("k1", []byte("one"))}})
+	}, middlewares.WithJWKSTTL(0), middlewares.WithJWKSMinRefresh(0))
+
+	keys, err := jwks.Keys(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	fail = true
+	keys, err = jwks.Keys(context.Background())
+
+	assert.NoError(t, err, "previous keys stay in use when a refresh fails")
+	assert.Len(t, keys, 1)
+	assert.Equal(t, 2, calls)
+
+	t.Run("cached within TTL", func(t *testing.T) {
+		calls := 0
+		jwks := middlewares.NewJWKS(func(context.Context) ([]byte, error) {
+			calls++
+			return []byte(`{"keys":[]}`), nil
+		})
+
+		_, _ = jwks.Keys(context.Background())
+		_, _ = jwks.Keys(context.Background())
+
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stale keys are served while a refresh is in flight", func(t *testing.T) {
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+		first := true
+		jwks := middlewares.NewJWKS(func(context.Context) ([]byte, error) {
+			if !first {
+				started <- struct{}{}
+				<-release
+			}
+			first = false
+			return json.Marshal(map[string]any{"keys": []map[string]any{octJWK("k1", []byte("one"))}})
+		}, middlewares.WithJWKSTTL(0), middlewares.WithJWKSMinRefresh(0))
+		_, err := jwks.Keys(context.Background())
+		assert.NoError(t, err)
+
+		refreshed := make(chan error, 1)
+		go func() {
+			_, err := jwks.Keys(context.Background())
+			refreshed <- err
+		}()
+		<-started
+
+		keys, err := jwks.Keys(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+
+		close(release)
+		assert.NoError(t, <-refreshed)
+	})
+
+	t.Run("first fetch failure is returned", func(t *testing.T) {
+		jwks := middlewares.NewJWKS(func(context.Context) ([]byte, error) {
+			return nil, errors.New("unreachable")
+		})
+
+		_, err := jwks.Keys(context.Background())
+
+		assert.EqualError(t, err, "unreachable")
+	})
+}
+
+func TestJWKSFromURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jwks.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]any{octJWK("k1", []byte("one"))}})
+	}))
+	defer srv.Close()
+
+	raw, err := middlewares.JWKSFromURL(srv.URL+"/jwks.json", nil)(context.Background())
+	assert.NoError(t, err)
+	keys, err := middlewares.ParseJWKS(raw)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	_, err = middlewares.JWKSFromURL(srv.URL+"/missing", srv.Client())(context.Background())
+	assert.Error(t, err)
+}
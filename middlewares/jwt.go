@@ -0,0 +1,415 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// Signing algorithms supported by JWT.
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+	JWTAlgES256 = "ES256"
+	JWTAlgEdDSA = "EdDSA"
+)
+
+// Reasons a token is rejected. They are passed to JWTConfig.Unauthorized.
+var (
+	ErrJWTMissing     = errors.New("jwt: token missing")
+	ErrJWTMalformed   = errors.New("jwt: token malformed")
+	ErrJWTAlgorithm   = errors.New("jwt: algorithm not allowed")
+	ErrJWTKeyNotFound = errors.New("jwt: no key for token")
+	ErrJWTSignature   = errors.New("jwt: signature invalid")
+	ErrJWTExpired     = errors.New("jwt: token expired")
+	ErrJWTNotYetValid = errors.New("jwt: token not valid yet")
+	ErrJWTIssuer      = errors.New("jwt: issuer mismatch")
+	ErrJWTAudience    = errors.New("jwt: audience mismatch")
+)
+
+// JWTKey is a verification key. Key is a []byte secret (HS256), an
+// *rsa.PublicKey (RS256), a P-256 *ecdsa.PublicKey (ES256) or an
+// ed25519.PublicKey (EdDSA); it is only used for tokens of the matching
+// algorithm. ID and Algorithm, when set, must match the token's kid and alg.
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	Key       any
+}
+
+// JWTClaims are the claims of a verified token. The registered claims are
+// decoded into fields; Decode unmarshals the full payload into a custom type.
+type JWTClaims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time // zero when absent
+	NotBefore time.Time // zero when absent
+	IssuedAt  time.Time // zero when absent
+	ID        string
+	// Header holds the token's JOSE header (alg, kid, typ, ...).
+	Header map[string]any
+
+	payload []byte
+}
+
+// Decode unmarshals the token payload into dest.
+func (c *JWTClaims) Decode(dest any) error {
+	return json.Unmarshal(c.payload, dest)
+}
+
+type JWTConfig[Bindings any] struct {
+	// Keys are static verification keys.
+	Keys []JWTKey
+	// KeyFunc supplies keys per request, e.g. a secret from c.Env().
+	KeyFunc func(c interfaces.IContext[Bindings]) ([]JWTKey, error)
+	// JWKS supplies keys from a cached JWKS document.
+	JWKS *JWKS
+	// Algorithms restricts the accepted algorithms; nil accepts every
+	// supported algorithm whose key type matches.
+	Algorithms []string
+	// Issuer, when set, must equal the iss claim.
+	Issuer string
+	// Audience, when set, must share at least one value with the aud claim.
+	Audience []string
+	// ClockSkew is the leeway applied to exp and nbf.
+	ClockSkew time.Duration
+	// TokenLookup lists where the token is read from as comma-separated
+	// "source:name" pairs (header, query or cookie) tried in order; a
+	// "Bearer " prefix is stripped. "" uses "header:Authorization".
+	TokenLookup string
+	// Unauthorized writes the response for a rejected token, receiving one of
+	// the ErrJWT* reasons; nil answers a plain 401 with a Bearer challenge.
+	Unauthorized func(c interfaces.IContext[Bindings], err error) error
+	// Now returns the current time; nil uses time.Now.
+	Now func() time.Time
+}
+
+// JWT verifies a JSON Web Token (RFC 7519) signed with HS256, RS256, ES256 or
+// EdDSA, using keys from Keys, KeyFunc and JWKS together. It checks exp and
+// nbf with ClockSkew, and iss and aud when configured, then stores the
+// *JWTClaims for JWTClaimsFrom. A key
+// lookup failure (e.g. the JWKS endpoint is down) is returned to the error
+// handler instead of rejecting the token.
+//
+//	jwks := middlewares.NewJWKS(middlewares.JWKSFromURL("https://issuer/.well-known/jwks.json", nil))
+//	app.Use("/api/*", middlewares.JWT(middlewares.JWTConfig[Bindings]{
+//		JWKS:     jwks,
+//		Issuer:   "https://issuer/",
+//		Audience: []string{"my-api"},
+//	}))
+func JWT[Bindings any](config JWTConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := config
+	if cfg.Keys == nil && cfg.KeyFunc == nil && cfg.JWKS == nil {
+		panic("middlewares: JWT requires Keys, a KeyFunc or a JWKS")
+	}
+	if cfg.TokenLookup == "" {
+		cfg.TokenLookup = "header:Authorization"
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	extractors, err := parseKeyLookup(cfg.TokenLookup)
+	if err != nil {
+		panic("middlewares: JWT: " + err.Error())
+	}
+
+	reject := func(c interfaces.IContext[Bindings], err error) error {
+		if cfg.Unauthorized != nil {
+			return cfg.Unauthorized(c, err)
+		}
+		challenge := "Bearer"
+		if !errors.Is(err, ErrJWTMissing) {
+			challenge += ` error="invalid_token"`
+		}
+		c.Response().Header().Set("WWW-Authenticate", challenge)
+		return c.Status(http.StatusUnauthorized).Text(http.StatusText(http.StatusUnauthorized))
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		raw := ""
+		for _, extract := range extractors {
+			if raw = extract(c.Req()); raw != "" {
+				break
+			}
+		}
+		if token, ok := authCredentials(raw, "Bearer"); ok {
+			raw = token
+		}
+		if raw == "" {
+			return reject(c, ErrJWTMissing)
+		}
+
+		tok, err := parseJWT(raw)
+		if err != nil {
+			return reject(c, err)
+		}
+		if cfg.Algorithms != nil && !slices.Contains(cfg.Algorithms, tok.alg) {
+			return reject(c, ErrJWTAlgorithm)
+		}
+
+		keys, err := jwtKeys(c, cfg, tok.kid)
+		if err != nil {
+			return err
+		}
+		if err := tok.verify(keys); err != nil {
+			return reject(c, err)
+		}
+
+		claims, err := tok.claims()
+		if err != nil {
+			return reject(c, err)
+		}
+		if err := checkClaims(claims, cfg.Issuer, cfg.Audience, cfg.Now(), cfg.ClockSkew); err != nil {
+			return reject(c, err)
+		}
+
+		c.Set(jwtClaimsKey, claims)
+		return next(c)
+	}
+}
+
+// jwtClaimsKey is unexported so only JWT can store claims; handlers read
+// them with JWTClaimsFrom.
+const jwtClaimsKey = "takibi.middlewares.jwtClaims"
+
+// JWTClaimsFrom returns the claims stored by JWT, or false when JWT is not
+// installed on the route.
+func JWTClaimsFrom(c interface {
+	Get(string) (any, bool)
+}) (*JWTClaims, bool) {
+	if v, ok := c.Get(jwtClaimsKey); ok {
+		claims, ok := v.(*JWTClaims)
+		return claims, ok
+	}
+	return nil, false
+}
+
+// jwtKeys gathers the candidate keys of every configured source.
+func jwtKeys[Bindings any](c interfaces.IContext[Bindings], cfg JWTConfig[Bindings], kid string) ([]JWTKey, error) {
+	keys := slices.Clone(cfg.Keys)
+	if cfg.KeyFunc != nil {
+		dynamic, err := cfg.KeyFunc(c)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, dynamic...)
+	}
+	if cfg.JWKS != nil {
+		remote, err := cfg.JWKS.keysFor(c.Req().Raw().Context(), kid)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, remote...)
+	}
+	return keys, nil
+}
+
+// jwtToken is a token split into its parts, its signature not yet verified.
+type jwtToken struct {
+	alg, kid     string
+	header       map[string]any
+	signingInput string
+	payload      []byte
+	signature    []byte
+}
+
+func parseJWT(raw string) (*jwtToken, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMalformed
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	var header map[string]any
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrJWTMalformed
+	}
+	alg, _ := header["alg"].(string)
+	kid, _ := header["kid"].(string)
+	if alg == "" {
+		return nil, ErrJWTMalformed
+	}
+	return &jwtToken{
+		alg:          alg,
+		kid:          kid,
+		header:       header,
+		signingInput: parts[0] + "." + parts[1],
+		payload:      payload,
+		signature:    signature,
+	}, nil
+}
+
+// verify checks the signature against every key usable for the token.
+func (t *jwtToken) verify(keys []JWTKey) error {
+	if !slices.Contains([]string{JWTAlgHS256, JWTAlgRS256, JWTAlgES256, JWTAlgEdDSA}, t.alg) {
+		return ErrJWTAlgorithm
+	}
+	found := false
+	for _, k := range keys {
+		if (k.ID != "" && t.kid != "" && k.ID != t.kid) || (k.Algorithm != "" && k.Algorithm != t.alg) {
+			continue
+		}
+		ok, usable := verifySignature(t.alg, k.Key, []byte(t.signingInput), t.signature)
+		if !usable {
+			continue
+		}
+		found = true
+		if ok {
+			return nil
+		}
+	}
+	if !found {
+		return ErrJWTKeyNotFound
+	}
+	return ErrJWTSignature
+}
+
+// verifySignature reports whether sig is valid for input under key, and
+// whether key's type fits alg at all; a mismatching type is never used, so
+// an RSA public key can't be abused as an HMAC secret.
+func verifySignature(alg string, key any, input, sig []byte) (valid, usable bool) {
+	digest := sha256.Sum256(input)
+	switch alg {
+	case JWTAlgHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return false, false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), sig), true
+	case JWTAlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false, false
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil, true
+	case JWTAlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			return false, false
+		}
+		if len(sig) != 64 {
+			return false, true
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(pub, digest[:], r, s), true
+	case JWTAlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return false, false
+		}
+		return ed25519.Verify(pub, input, sig), true
+	}
+	return false, false
+}
+
+// claims decodes the registered claims of the payload.
+func (t *jwtToken) claims() (*JWTClaims, error) {
+	var registered struct {
+		Issuer    string          `json:"iss"`
+		Subject   string          `json:"sub"`
+		Audience  json.RawMessage `json:"aud"`
+		ExpiresAt *json.Number    `json:"exp"`
+		NotBefore *json.Number    `json:"nbf"`
+		IssuedAt  *json.Number    `json:"iat"`
+		ID        string          `json:"jti"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(t.payload))
+	dec.UseNumber()
+	if err := dec.Decode(&registered); err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	claims := &JWTClaims{
+		Issuer:  registered.Issuer,
+		Subject: registered.Subject,
+		ID:      registered.ID,
+		Header:  t.header,
+		payload: t.payload,
+	}
+	var err error
+	if claims.Audience, err = audience(registered.Audience); err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		n    *json.Number
+		dest *time.Time
+	}{
+		{registered.ExpiresAt, &claims.ExpiresAt},
+		{registered.NotBefore, &claims.NotBefore},
+		{registered.IssuedAt, &claims.IssuedAt},
+	} {
+		if f.n == nil {
+			continue
+		}
+		secs, err := f.n.Float64()
+		if err != nil {
+			return nil, ErrJWTMalformed
+		}
+		*f.dest = time.UnixMilli(int64(secs * 1000))
+	}
+	return claims, nil
+}
+
+// audience decodes an aud claim, which is a string or an array of strings.
+func audience(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, ErrJWTMalformed
+	}
+	return many, nil
+}
+
+func checkClaims(claims *JWTClaims, issuer string, aud []string, now time.Time, skew time.Duration) error {
+	if !claims.ExpiresAt.IsZero() && !now.Before(claims.ExpiresAt.Add(skew)) {
+		return ErrJWTExpired
+	}
+	if !claims.NotBefore.IsZero() && now.Add(skew).Before(claims.NotBefore) {
+		return ErrJWTNotYetValid
+	}
+	if issuer != "" && claims.Issuer != issuer {
+		return ErrJWTIssuer
+	}
+	if len(aud) > 0 && !slices.ContainsFunc(claims.Audience, func(a string) bool {
+		return slices.Contains(aud, a)
+	}) {
+		return ErrJWTAudience
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package middlewares_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+// signJWT builds a compact token signed with key for alg.
+func signJWT(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	p, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		s, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = s
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func bearer(token string) interfaces.CampOption {
+	return interfaces.Header("Authorization", "Bearer "+token)
+}
+
+// jwtRoutes keeps the claims the handler sees in got, unless it is nil.
+func jwtRoutes(got **middlewares.JWTClaims) func(app interfaces.ITakibi[authBindings]) {
+	return func(app interfaces.ITakibi[authBindings]) {
+		app.Get("/", func(c interfaces.IContext[authBindings]) error {
+			if got != nil {
+				*got, _ = middlewares.JWTClaimsFrom(c)
+			}
+			return c.Text("ok")
+		})
+	}
+}
+
+func TestJWT_Algorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("top-secret")
+
+	got := new(*middlewares.JWTClaims)
+	app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(got), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+		Keys: []middlewares.JWTKey{
+			{Key: secret},
+			{Key: &rsaKey.PublicKey},
+			{Key: &ecKey.PublicKey},
+			{Key: edPub},
+		},
+	}))
+	claims := map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Minute).Unix()}
+
+	tests := []struct {
+		alg string
+		key any
+	}{
+		{middlewares.JWTAlgHS256, secret},
+		{middlewares.JWTAlgRS256, rsaKey},
+		{middlewares.JWTAlgES256, ecKey},
+		{middlewares.JWTAlgEdDSA, edPriv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			*got = nil
+
+			resp := app.Camp(http.MethodGet, "/", bearer(signJWT(t, tt.alg, "", tt.key, claims)))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode())
+			if assert.NotNil(t, *got) {
+				assert.Equal(t, "user-1", (*got).Subject)
+				assert.Equal(t, tt.alg, (*got).Header["alg"])
+			}
+		})
+	}
+
+	t.Run("RSA public key is not accepted as an HMAC secret", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(nil), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+			Keys: []middlewares.JWTKey{{Key: &rsaKey.PublicKey}},
+		}))
+		token := signJWT(t, middlewares.JWTAlgHS256, "", []byte("guess"), claims)
+
+		resp := app.Camp(http.MethodGet, "/", bearer(token))
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	})
+}
+
+func TestJWT_Rejections(t *testing.T) {
+	secret := []byte("top-secret")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var reason error
+	app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(nil), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+		Keys:       []middlewares.JWTKey{{ID: "k1", Key: secret}},
+		Algorithms: []string{middlewares.JWTAlgHS256},
+		Issuer:     "https://issuer.example",
+		Audience:   []string{"api"},
+		ClockSkew:  30 * time.Second,
+		Now:        func() time.Time { return now },
+		Unauthorized: func(c interfaces.IContext[authBindings], err error) error {
+			reason = err
+			return c.Status(http.StatusUnauthorized).Text(err.Error())
+		},
+	}))
+	valid := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{
+			"iss": "https://issuer.example",
+			"aud": []string{"web", "api"},
+			"exp": now.Add(time.Minute).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(claims, k)
+				continue
+			}
+			claims[k] = v
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", signJWT(t, "HS256", "k1", secret, valid(nil)), nil},
+		{"string audience", signJWT(t, "HS256", "k1", secret, valid(map[string]any{"aud": "api"})), nil},
+		{"expired within skew", signJWT(t, "HS256", "k1", secret, valid(map[string]any{"exp": now.Add(-20 * time.Second).Unix()})), nil},
+		{"expired beyond skew", signJWT(t, "HS256", "k1", secret, valid(map[string]any{"exp": now.Add(-time.Minute).Unix()})), middlewares.ErrJWTExpired},
+		{"nbf within skew", signJWT(t, "HS256", "k1", secret, valid(map[string]any{"nbf": now.Add(20 * time.Second).Unix()})), nil},
+		{"nbf beyond skew", signJWT(t, "HS256", "k1", secret, valid(map[string]any{"nbf": now.Add(time.Minute).Unix()})), middlewares.ErrJWTNotYetValid},
+		{"wrong issuer", signJWT(t, "HS256", "k1", secret, valid(map[string]any{"iss": "https://evil.example"})), middlewares.ErrJWTIssuer},
+		{"wrong audience", signJWT(t, "HS256", "k1", secret, valid(map[string]any{"aud": "other"})), middlewares.ErrJWTAudience},
+		{"missing audience", signJWT(t, "HS256", "k1", secret, valid(map[string]any{"aud": nil})), middlewares.ErrJWTAudience},
+		{"bad signature", signJWT(t, "HS256", "k1", []byte("other"), valid(nil)), middlewares.ErrJWTSignature},
+		{"unknown kid", signJWT(t, "HS256", "k2", secret, valid(nil)), middlewares.ErrJWTKeyNotFound},
+		{"disallowed alg", signJWT(t, "EdDSA", "k1", secret, valid(nil)), middlewares.ErrJWTAlgorithm},
+		{"alg none", "eyJhbGciOiJub25lIn0.e30.", middlewares.ErrJWTAlgorithm},
+		{"malformed", "not-a-jwt", middlewares.ErrJWTMalformed},
+		{"missing", "", middlewares.ErrJWTMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason = nil
+			var opts []interfaces.CampOption
+			if tt.token != "" {
+				opts = append(opts, bearer(tt.token))
+			}
+
+			resp := app.Camp(http.MethodGet, "/", opts...)
+
+			if tt.want == nil {
+				assert.Equal(t, http.StatusOK, resp.StatusCode())
+				assert.NoError(t, reason)
+				return
+			}
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+			assert.ErrorIs(t, reason, tt.want)
+		})
+	}
+}
+
+func TestJWT_Options(t *testing.T) {
+	claims := map[string]any{"sub": "user-1", "role": "admin", "exp": time.Now().Add(time.Minute).Unix()}
+
+	t.Run("KeyFunc reads the secret from Env", func(t *testing.T) {
+		got := new(*middlewares.JWTClaims)
+		app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(got), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+			KeyFunc: func(c interfaces.IContext[authBindings]) ([]middlewares.JWTKey, error) {
+				return []middlewares.JWTKey{{Key: []byte(c.Env().Secret)}}, nil
+			},
+		}))
+
+		resp := app.Camp(http.MethodGet, "/", bearer(signJWT(t, "HS256", "", []byte("env-secret"), claims)))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		var custom struct {
+			Role string `json:"role"`
+		}
+		if assert.NotNil(t, *got) {
+			assert.NoError(t, (*got).Decode(&custom))
+			assert.Equal(t, "admin", custom.Role)
+		}
+	})
+
+	t.Run("KeyFunc error goes to the error handler", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(nil), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+			KeyFunc: func(c interfaces.IContext[authBindings]) ([]middlewares.JWTKey, error) {
+				return nil, errors.New("secret store down")
+			},
+		}))
+
+		resp := app.Camp(http.MethodGet, "/", bearer(signJWT(t, "HS256", "", []byte("x"), claims)))
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
+	})
+
+	t.Run("default rejection sends a Bearer challenge", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(nil), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+			Keys: []middlewares.JWTKey{{Key: []byte("s")}},
+		}))
+
+		missing := app.Camp(http.MethodGet, "/")
+		invalid := app.Camp(http.MethodGet, "/", bearer("a.b.c"))
+
+		assert.Equal(t, http.StatusUnauthorized, missing.StatusCode())
+		assert.Equal(t, "Bearer", missing.Raw().Header.Get("WWW-Authenticate"))
+		assert.Equal(t, http.StatusUnauthorized, invalid.StatusCode())
+		assert.Equal(t, `Bearer error="invalid_token"`, invalid.Raw().Header.Get("WWW-Authenticate"))
+	})
+
+	t.Run("token from a cookie", func(t *testing.T) {
+		app := newTestApp(&authBindings{Secret: "env-secret"}, jwtRoutes(nil), middlewares.JWT(middlewares.JWTConfig[authBindings]{
+			Keys:        []middlewares.JWTKey{{Key: []byte("s")}},
+			TokenLookup: "header:Authorization,cookie:session",
+		}))
+		token := signJWT(t, "HS256", "", []byte("s"), claims)
+
+		resp := app.Camp(http.MethodGet, "/", interfaces.Header("Cookie", "session="+token))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+	})
+
+	t.Run("requires a key source", func(t *testing.T) {
+		assert.Panics(t, func() {
+			middlewares.JWT(middlewares.JWTConfig[authBindings]{})
+		})
+	})
+}
+
+func TestJWTClaimsFrom_WithoutMiddleware(t *testing.T) {
+	var ok = true
+	app := takibi.New(&authBindings{})
+	app.Get("/", func(c interfaces.IContext[authBindings]) error {
+		_, ok = middlewares.JWTClaimsFrom(c)
+		return nil
+	})
+
+	app.Camp(http.MethodGet, "/")
+
+	assert.False(t, ok)
+}
@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+type KeyAuthConfig[Bindings any] struct {
+	// KeyLookup lists where the key is read from as comma-separated
+	// "source:name" pairs tried in order, source being header, query or
+	// cookie; "" uses "header:X-API-Key".
+	KeyLookup string
+	// Keys lists the accepted keys, compared in constant time.
+	Keys []string
+	// Validator checks the key when Keys is not enough, e.g. against secrets
+	// in c.Env(). A returned error goes to the error handler.
+	Validator func(c interfaces.IContext[Bindings], key string) (bool, error)
+	// Unauthorized writes the response for a missing or rejected key; nil
+	// answers a plain 401.
+	Unauthorized interfaces.HandlerFunc[Bindings]
+}
+
+// keyExtractor reads a key from one place in the request.
+type keyExtractor func(r interfaces.IRequest) string
+
+// KeyAuth guards the route with an API key read from a header, query
+// parameter or cookie. The key passes when it is one of Keys or
+// the Validator accepts it; otherwise a 401 is sent.
+//
+//	app.Use("/api/*", middlewares.KeyAuth(middlewares.KeyAuthConfig[Bindings]{
+//		KeyLookup: "header:X-API-Key,query:api_key",
+//		Validator: func(c interfaces.IContext[Bindings], key string) (bool, error) {
+//			return middlewares.SecureCompare(key, c.Env().APIKey), nil
+//		},
+//	}))
+func KeyAuth[Bindings any](config KeyAuthConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := config
+	if cfg.KeyLookup == "" {
+		cfg.KeyLookup = "header:X-API-Key"
+	}
+	if cfg.Keys == nil && cfg.Validator == nil {
+		panic("middlewares: KeyAuth requires Keys or a Validator")
+	}
+	extractors, err := parseKeyLookup(cfg.KeyLookup)
+	if err != nil {
+		panic("middlewares: KeyAuth: " + err.Error())
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		key := ""
+		for _, extract := range extractors {
+			if key = extract(c.Req()); key != "" {
+				break
+			}
+		}
+		if key == "" {
+			return unauthorized(c, cfg.Unauthorized)
+		}
+
+		valid := secureContains(cfg.Keys, key)
+		if !valid && cfg.Validator != nil {
+			var err error
+			if valid, err = cfg.Validator(c, key); err != nil {
+				return err
+			}
+		}
+		if !valid {
+			return unauthorized(c, cfg.Unauthorized)
+		}
+		return next(c)
+	}
+}
+
+// parseKeyLookup turns a KeyLookup value into extractors.
+func parseKeyLookup(lookup string) ([]keyExtractor, error) {
+	var extractors []keyExtractor
+	for part := range strings.SplitSeq(lookup, ",") {
+		source, name, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid key lookup %q", part)
+		}
+		switch source {
+		case "header":
+			extractors = append(extractors, func(r interfaces.IRequest) string {
+				return r.HeaderBy(name)
+			})
+		case "query":
+			extractors = append(extractors, func(r interfaces.IRequest) string {
+				return r.QueryBy(name)
+			})
+		case "cookie":
+			extractors = append(extractors, func(r interfaces.IRequest) string {
+				cookie, err := r.Raw().Cookie(name)
+				if err != nil {
+					return ""
+				}
+				return cookie.Value
+			})
+		default:
+			return nil, fmt.Errorf("unsupported key source %q", source)
+		}
+	}
+	return extractors, nil
+}
@@ -0,0 +1,191 @@
+package middlewares
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// Attribute keys emitted by Logger. LoggerConfig.Fields selects among them.
+const (
+	LogFieldMethod    = "method"
+	LogFieldRoute     = "route"
+	LogFieldPath      = "path"
+	LogFieldStatus    = "status"
+	LogFieldBytes     = "bytes"
+	LogFieldLatency   = "latency"
+	LogFieldRemoteIP  = "remote_ip"
+	LogFieldRequestID = "request_id"
+	LogFieldUserAgent = "user_agent"
+	LogFieldError     = "error"
+)
+
+type LoggerConfig[Bindings any] struct {
+	// Logger receives the access records; nil uses slog.Default().
+	Logger *slog.Logger
+	// Message is the record message; "" uses "request".
+	Message string
+	// Fields lists the LogField* attributes to emit; nil emits every field
+	// except LogFieldPath and LogFieldUserAgent. LogFieldError is always
+	// emitted when the handler returned an error.
+	Fields []string
+	// SampleRate is the fraction (0 < rate < 1) of successful requests that
+	// are logged. Requests answered with a 5xx or an error are always
+	// logged. 0 (or >= 1) logs every request.
+	SampleRate float64
+	// SkipPaths lists raw request paths (e.g. "/healthz") that are never logged.
+	SkipPaths []string
+	// Skip reports whether the request must not be logged.
+	Skip func(c interfaces.IContext[Bindings]) bool
+}
+
+var defaultLogFields = []string{
+	LogFieldMethod,
+	LogFieldRoute,
+	LogFieldStatus,
+	LogFieldBytes,
+	LogFieldLatency,
+	LogFieldRemoteIP,
+	LogFieldRequestID,
+}
+
+func DefaultLoggerConfig[Bindings any]() LoggerConfig[Bindings] {
+	return LoggerConfig[Bindings]{
+		Message: "request",
+		Fields:  defaultLogFields,
+	}
+}
+
+// Logger emits one slog record per request once the handler chain returns.
+// The record level follows the status: Info below 400, Warn for 4xx and Error
+// for 5xx. The route attribute is the registered pattern (ctx.RoutePath()),
+// not the raw path, so records group by endpoint.
+//
+// Before calling next, Logger stores a request-scoped *slog.Logger carrying
+// the method, route and request ID; handlers retrieve it with LoggerFrom so
+// their own records share those attributes.
+//
+// Register it before the handlers it should time:
+//
+//	app.Use("*", middlewares.Logger[Bindings]())
+func Logger[Bindings any](config ...LoggerConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultLoggerConfig[Bindings]()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Message == "" {
+		cfg.Message = "request"
+	}
+	if cfg.Fields == nil {
+		cfg.Fields = defaultLogFields
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		req := c.Req().Raw()
+		if slices.Contains(cfg.SkipPaths, req.URL.Path) || (cfg.Skip != nil && cfg.Skip(c)) {
+			return next(c)
+		}
+
+		base := cfg.Logger
+		if base == nil {
+			base = slog.Default()
+		}
+		requestID := requestIDOf(c)
+		c.Set(constants.ContextKeyLogger, base.With(
+			slog.String(LogFieldMethod, req.Method),
+			slog.String(LogFieldRoute, c.RoutePath()),
+			slog.String(LogFieldRequestID, requestID),
+		))
+
+		rec := newResponseRecorder(c.Response())
+		c.SetResponse(rec)
+		start := time.Now()
+
+		err := next(c)
+
+		latency := time.Since(start)
+		status := rec.status
+		if !rec.written() {
+			if err != nil {
+				// the error handler writes the response after the chain
+				// returns; report the status it will answer with
+				status = interfaces.StatusCodeOf(err)
+			} else {
+				status = http.StatusOK
+			}
+		}
+		if cfg.SampleRate > 0 && cfg.SampleRate < 1 && status < 500 && err == nil &&
+			rand.Float64() >= cfg.SampleRate {
+			return err
+		}
+
+		attrs := make([]slog.Attr, 0, len(cfg.Fields)+1)
+		for _, field := range cfg.Fields {
+			switch field {
+			case LogFieldMethod:
+				attrs = append(attrs, slog.String(field, req.Method))
+			case LogFieldRoute:
+				attrs = append(attrs, slog.String(field, c.RoutePath()))
+			case LogFieldPath:
+				attrs = append(attrs, slog.String(field, req.URL.Path))
+			case LogFieldStatus:
+				attrs = append(attrs, slog.Int(field, status))
+			case LogFieldBytes:
+				attrs = append(attrs, slog.Int64(field, rec.size))
+			case LogFieldLatency:
+				attrs = append(attrs, slog.Duration(field, latency))
+			case LogFieldRemoteIP:
+				attrs = append(attrs, slog.String(field, c.Req().RealIP()))
+			case LogFieldRequestID:
+				attrs = append(attrs, slog.String(field, requestID))
+			case LogFieldUserAgent:
+				attrs = append(attrs, slog.String(field, req.UserAgent()))
+			}
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String(LogFieldError, err.Error()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		base.LogAttrs(req.Context(), level, cfg.Message, attrs...)
+		return err
+	}
+}
+
+// LoggerFrom returns the request-scoped logger stored by Logger, or
+// slog.Default() when Logger is not installed on the route.
+//
+//	middlewares.LoggerFrom(ctx).Info("user created", "id", id)
+func LoggerFrom(c interface {
+	Get(string) (any, bool)
+}) *slog.Logger {
+	if v, ok := c.Get(constants.ContextKeyLogger); ok {
+		if l, ok := v.(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+// requestIDOf returns the request ID stored in the context, falling back to
+// the incoming X-Request-Id header when it passes RequestID's validation.
+func requestIDOf[Bindings any](c interfaces.IContext[Bindings]) string {
+	if id := RequestIDFrom(c); id != "" {
+		return id
+	}
+	if id := c.Req().HeaderBy(constants.HeaderRequestID); validRequestID(id) {
+		return id
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+type goneError struct{}
+
+func (goneError) Error() string   { return "gone" }
+func (goneError) StatusCode() int { return http.StatusGone }
+
+// decodeRecords parses the JSON lines written by a slog.JSONHandler.
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	newApp := func(cfg middlewares.LoggerConfig[any]) (interfaces.ITakibi[any], *bytes.Buffer) {
+		buf := &bytes.Buffer{}
+		cfg.Logger = slog.New(slog.NewJSONHandler(buf, nil))
+		app := takibi.New[any](nil)
+		app.Use("*", middlewares.Logger(cfg))
+		app.Get("/users/:id", func(c interfaces.IContext[any]) error {
+			middlewares.LoggerFrom(c).Info("loading user")
+			return c.Text("user")
+		})
+		app.Get("/healthz", func(c interfaces.IContext[any]) error {
+			return c.Text("ok")
+		})
+		app.Get("/fail", func(c interfaces.IContext[any]) error {
+			return errors.New("boom")
+		})
+		app.Get("/gone", func(c interfaces.IContext[any]) error {
+			return fmt.Errorf("lookup: %w", goneError{})
+		})
+		return app, buf
+	}
+
+	t.Run("emits one record with route pattern, status and bytes", func(t *testing.T) {
+		app, buf := newApp(middlewares.LoggerConfig[any]{})
+		req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+		req.Header.Set("X-Request-Id", "req-1")
+		app.ServeHTTP(httptest.NewRecorder(), req)
+
+		records := decodeRecords(t, buf)
+		assert.Len(t, records, 2)
+
+		// handler record carries the request-scoped attributes
+		assert.Equal(t, "loading user", records[0]["msg"])
+		assert.Equal(t, "/users/:id", records[0]["route"])
+		assert.Equal(t, "req-1", records[0]["request_id"])
+
+		access := records[1]
+		assert.Equal(t, "request", access["msg"])
+		assert.Equal(t, "INFO", access["level"])
+		assert.Equal(t, "GET", access["method"])
+		assert.Equal(t, "/users/:id", access["route"])
+		assert.Equal(t, float64(http.StatusOK), access["status"])
+		assert.Equal(t, float64(len("user")), access["bytes"])
+		assert.Equal(t, "192.0.2.1", access["remote_ip"])
+		assert.Contains(t, access, "latency")
+		assert.NotContains(t, access, "path")
+	})
+
+	t.Run("handler error is logged as error level", func(t *testing.T) {
+		app, buf := newApp(middlewares.LoggerConfig[any]{})
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+		records := decodeRecords(t, buf)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "ERROR", records[0]["level"])
+			assert.Equal(t, float64(http.StatusInternalServerError), records[0]["status"])
+			assert.Equal(t, "boom", records[0]["error"])
+		}
+	})
+
+	t.Run("status error is logged with its status", func(t *testing.T) {
+		app, buf := newApp(middlewares.LoggerConfig[any]{})
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/gone", nil))
+
+		records := decodeRecords(t, buf)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "WARN", records[0]["level"])
+			assert.Equal(t, float64(http.StatusGone), records[0]["status"])
+		}
+	})
+
+	t.Run("invalid incoming request id is not logged", func(t *testing.T) {
+		app, buf := newApp(middlewares.LoggerConfig[any]{})
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("X-Request-Id", "forged id\" level=error")
+		app.ServeHTTP(httptest.NewRecorder(), req)
+
+		records := decodeRecords(t, buf)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "", records[0]["request_id"])
+		}
+	})
+
+	t.Run("configured fields only", func(t *testing.T) {
+		app, buf := newApp(middlewares.LoggerConfig[any]{
+			Fields: []string{middlewares.LogFieldPath, middlewares.LogFieldStatus},
+		})
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/7", nil))
+
+		records := decodeRecords(t, buf)
+		access := records[len(records)-1]
+		assert.Equal(t, "/users/7", access["path"])
+		assert.Contains(t, access, "status")
+		assert.NotContains(t, access, "route")
+		assert.NotContains(t, access, "latency")
+	})
+
+	t.Run("skip paths and skip func", func(t *testing.T) {
+		app, buf := newApp(middlewares.LoggerConfig[any]{
+			SkipPaths: []string{"/healthz"},
+			Skip: func(c interfaces.IContext[any]) bool {
+				return c.Req().HeaderBy("X-Synthetic") != ""
+			},
+		})
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.Header.Set("X-Synthetic", "1")
+		app.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Empty(t, decodeRecords(t, buf))
+	})
+
+	t.Run("sampling drops successes but keeps errors", func(t *testing.T) {
+		app, buf := newApp(middlewares.LoggerConfig[any]{SampleRate: 1e-12})
+		for i := 0; i < 20; i++ {
+			app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		}
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+		records := decodeRecords(t, buf)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "boom", records[0]["error"])
+		}
+	})
+
+	t.Run("LoggerFrom falls back to the default logger", func(t *testing.T) {
+		ctx := takibi.NewContext[any](httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+		assert.Equal(t, slog.Default(), middlewares.LoggerFrom(ctx))
+	})
+}
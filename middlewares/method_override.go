@@ -0,0 +1,120 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// methodOverridePeek bounds how much of a form body MethodOverride reads
+// looking for the FormField. It runs before routing, so before any
+// route-level BodyLimit, auth or CSRF check.
+const methodOverridePeek = 4 << 10
+
+type MethodOverrideConfig struct {
+	// FormField names the form field carrying the method; "" uses "_method".
+	FormField string
+	// Header names the header carrying the method; "" uses
+	// X-HTTP-Method-Override.
+	Header string
+	// Methods lists the methods a POST may be turned into; nil allows PUT,
+	// PATCH and DELETE.
+	Methods []string
+}
+
+func DefaultMethodOverrideConfig() MethodOverrideConfig {
+	return MethodOverrideConfig{
+		FormField: "_method",
+		Header:    "X-HTTP-Method-Override",
+		Methods:   []string{http.MethodPut, http.MethodPatch, http.MethodDelete},
+	}
+}
+
+// MethodOverride lets HTML forms, which can only GET or POST, reach PUT,
+// PATCH and DELETE routes. A POST carrying the method in the Header, or in
+// the FormField of an urlencoded body, is routed as that method. Only POST
+// is rewritten, so a link or image cannot trigger a DELETE, and methods
+// outside Methods are ignored.
+//
+// The method must change before the route is matched, so register it with
+// Pre rather than Use. Pre runs ahead of route-level body limits, so the
+// body is never parsed: only its first 4KB are searched for the FormField,
+// and the handlers still read the whole body through their own limits. Put
+// the hidden field first in the form; multipart forms must use the Header.
+//
+//	app.Pre(middlewares.MethodOverride[Bindings]())
+//
+//	<form method="post" action="/posts/1">
+//		<input type="hidden" name="_method" value="DELETE"/>
+//	</form>
+func MethodOverride[Bindings any](config ...MethodOverrideConfig) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultMethodOverrideConfig()
+	if len(config) > 0 {
+		c := config[0]
+		if c.FormField != "" {
+			cfg.FormField = c.FormField
+		}
+		if c.Header != "" {
+			cfg.Header = c.Header
+		}
+		if c.Methods != nil {
+			cfg.Methods = c.Methods
+		}
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		req := c.Req()
+		if req.Raw().Method != http.MethodPost {
+			return next(c)
+		}
+
+		method := req.HeaderBy(cfg.Header)
+		if method == "" && req.MediaType() == "application/x-www-form-urlencoded" {
+			method = peekFormField(req.Raw(), cfg.FormField)
+		}
+		method = strings.ToUpper(method)
+		if method == "" || !slices.Contains(cfg.Methods, method) {
+			return next(c)
+		}
+
+		r := req.Raw().WithContext(req.Raw().Context())
+		r.Method = method
+		c.SetRequest(r)
+		return next(c)
+	}
+}
+
+// peekFormField returns field from the first methodOverridePeek bytes of an
+// urlencoded body, putting the bytes read back in front of the body.
+func peekFormField(r *http.Request, field string) string {
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, methodOverridePeek))
+	r.Body = peekedBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+	if err != nil {
+		return ""
+	}
+
+	data := string(buf)
+	if len(buf) == methodOverridePeek {
+		// the last pair may be cut short
+		end := strings.LastIndexByte(data, '&')
+		if end < 0 {
+			return ""
+		}
+		data = data[:end]
+	}
+	values, _ := url.ParseQuery(data)
+	return values.Get(field)
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,171 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethodOverride(t *testing.T) {
+	type post struct {
+		Title string `form:"title"`
+	}
+
+	app := takibi.New[any](nil)
+	app.Pre(middlewares.MethodOverride[any]())
+	app.Post("/posts/:id", func(c interfaces.IContext[any]) error {
+		return c.Text("post")
+	})
+	app.Put("/posts/:id", func(c interfaces.IContext[any]) error {
+		var in post
+		if err := c.Req().UnmarshallForm(&in); err != nil {
+			return err
+		}
+		return c.Text("put " + c.ParamBy("id") + " " + in.Title)
+	})
+	app.Delete("/posts/:id", func(c interfaces.IContext[any]) error {
+		return c.Text("delete " + c.ParamBy("id"))
+	})
+	app.Get("/posts/:id", func(c interfaces.IContext[any]) error {
+		return c.Text("get")
+	})
+
+	form := func(values url.Values) []interfaces.CampOption {
+		return []interfaces.CampOption{
+			interfaces.Header("Content-Type", "application/x-www-form-urlencoded"),
+			interfaces.Body(strings.NewReader(values.Encode())),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		opts   []interfaces.CampOption
+		want   string
+	}{
+		{"form field", http.MethodPost, form(url.Values{"_method": {"DELETE"}}), "delete 1"},
+		{"lowercase form field", http.MethodPost, form(url.Values{"_method": {"delete"}}), "delete 1"},
+		{"form stays readable", http.MethodPost, form(url.Values{"_method": {"PUT"}, "title": {"hello"}}), "put 1 hello"},
+		{"header", http.MethodPost, []interfaces.CampOption{interfaces.Header("X-HTTP-Method-Override", "DELETE")}, "delete 1"},
+		{"method not allowed as override", http.MethodPost, form(url.Values{"_method": {"GET"}}), "post"},
+		{"plain post", http.MethodPost, form(url.Values{"title": {"hello"}}), "post"},
+		{"only POST is rewritten", http.MethodGet, []interfaces.CampOption{interfaces.Header("X-HTTP-Method-Override", "DELETE")}, "get"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := app.Camp(tt.method, "/posts/1", tt.opts...)
+
+			assert.Equal(t, http.StatusOK, res.StatusCode())
+			body, _ := readBody(res)
+			assert.Equal(t, tt.want, body)
+		})
+	}
+
+	t.Run("multipart form needs the header", func(t *testing.T) {
+		newBody := func() (*bytes.Buffer, string) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			_ = mw.WriteField("_method", "PUT")
+			_ = mw.WriteField("title", "upload")
+			_ = mw.Close()
+			return &body, mw.FormDataContentType()
+		}
+
+		body, contentType := newBody()
+		got, _ := readBody(app.Camp(http.MethodPost, "/posts/2",
+			interfaces.Header("Content-Type", contentType),
+			interfaces.Body(body),
+		))
+		assert.Equal(t, "post", got)
+
+		body, contentType = newBody()
+		got, _ = readBody(app.Camp(http.MethodPost, "/posts/2",
+			interfaces.Header("Content-Type", contentType),
+			interfaces.Header("X-HTTP-Method-Override", "PUT"),
+			interfaces.Body(body),
+		))
+		assert.Equal(t, "put 2 upload", got)
+	})
+
+	t.Run("field past the peeked prefix is ignored", func(t *testing.T) {
+		body := "title=" + strings.Repeat("a", 8<<10) + "&_method=DELETE"
+		got, _ := readBody(app.Camp(http.MethodPost, "/posts/1",
+			interfaces.Header("Content-Type", "application/x-www-form-urlencoded"),
+			interfaces.Body(strings.NewReader(body)),
+		))
+		assert.Equal(t, "post", got)
+	})
+}
+
+func TestMethodOverride_BodyLimit(t *testing.T) {
+	app := takibi.New[any](nil)
+	app.Pre(middlewares.MethodOverride[any]())
+	app.Use("/upload", middlewares.BodyLimit[any](1024))
+	app.Post("/upload", func(c interfaces.IContext[any]) error {
+		var in struct {
+			Data string `form:"data"`
+		}
+		if err := c.Req().UnmarshallForm(&in); err != nil {
+			return err
+		}
+		return c.Text(strconv.Itoa(len(in.Data)))
+	})
+	app.Put("/upload", func(c interfaces.IContext[any]) error {
+		b, err := io.ReadAll(c.Req().Raw().Body)
+		if err != nil {
+			return err
+		}
+		return c.Text(string(b))
+	})
+
+	t.Run("oversized chunked form is still limited", func(t *testing.T) {
+		body := "data=" + strings.Repeat("a", 200<<10)
+		res := app.Camp(http.MethodPost, "/upload",
+			interfaces.Header("Content-Type", "application/x-www-form-urlencoded"),
+			interfaces.Body(strings.NewReader(body)),
+		)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode())
+	})
+
+	t.Run("overridden request keeps the whole body", func(t *testing.T) {
+		body := "_method=PUT&title=hello"
+		res := app.Camp(http.MethodPost, "/upload",
+			interfaces.Header("Content-Type", "application/x-www-form-urlencoded"),
+			interfaces.Body(strings.NewReader(body)),
+		)
+
+		got, _ := readBody(res)
+		assert.Equal(t, body, got)
+	})
+}
+
+func TestMethodOverride_Config(t *testing.T) {
+	app := takibi.New[any](nil)
+	app.Pre(middlewares.MethodOverride[any](middlewares.MethodOverrideConfig{
+		Header:  "X-Method",
+		Methods: []string{http.MethodPatch},
+	}))
+	app.Patch("/", func(c interfaces.IContext[any]) error {
+		return c.Text("patch")
+	})
+	app.Post("/", func(c interfaces.IContext[any]) error {
+		return c.Text("post")
+	})
+
+	body, _ := readBody(app.Camp(http.MethodPost, "/", interfaces.Header("X-Method", "PATCH")))
+	assert.Equal(t, "patch", body)
+
+	body, _ = readBody(app.Camp(http.MethodPost, "/", interfaces.Header("X-Method", "DELETE")))
+	assert.Equal(t, "post", body)
+}
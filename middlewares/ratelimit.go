@@ -0,0 +1,144 @@
+package middlewares
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// ErrRateLimited is matched (errors.Is) by the *RateLimitError RateLimit
+// returns when a request exceeds its limit.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitError is returned by RateLimit for a rejected request. It answers
+// 429 through the default error handler; a custom OnError can match it with
+// errors.As to shape the body. The RateLimit-* and Retry-After headers are
+// already set on the response.
+type RateLimitError struct {
+	Result RateLimitResult
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrRateLimited.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+func (e *RateLimitError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+type RateLimitConfig[Bindings any] struct {
+	// Algorithm is RateLimitTokenBucket or RateLimitSlidingWindow; "" uses
+	// RateLimitTokenBucket.
+	Algorithm string
+	// Limit is the number of requests allowed per Window (required).
+	Limit int
+	// Window is the period Limit applies to (required).
+	Window time.Duration
+	// Store keeps the counters; nil uses a new MemoryRateLimitStore.
+	Store RateLimitStore
+	// KeyFunc returns the bucket a request counts against; nil uses
+	// RateLimitByIP. A returned error goes to the error handler.
+	KeyFunc func(c interfaces.IContext[Bindings]) (string, error)
+	// Skip reports whether the request is not counted.
+	Skip func(c interfaces.IContext[Bindings]) bool
+}
+
+// RateLimit limits how often each key (by default the client IP) may hit the
+// routes it guards. Every counted response carries the RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers; a
+// rejected request also gets Retry-After and returns a *RateLimitError, which
+// the error handler turns into the 429 response.
+//
+//	app.Use("/api/*", middlewares.RateLimit(middlewares.RateLimitConfig[Bindings]{
+//		Limit:   100,
+//		Window:  time.Minute,
+//		KeyFunc: middlewares.RateLimitByHeader[Bindings]("X-API-Key"),
+//	}))
+func RateLimit[Bindings any](config RateLimitConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := config
+	if cfg.Limit <= 0 || cfg.Window <= 0 {
+		panic("middlewares: RateLimit requires a positive Limit and Window")
+	}
+	if cfg.Window < time.Duration(cfg.Limit) {
+		// a token would refill in under a nanosecond
+		panic("middlewares: RateLimit Window is shorter than Limit nanoseconds")
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = RateLimitTokenBucket
+	}
+	if cfg.Algorithm != RateLimitTokenBucket && cfg.Algorithm != RateLimitSlidingWindow {
+		panic("middlewares: RateLimit: unknown algorithm " + cfg.Algorithm)
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = RateLimitByIP[Bindings]()
+	}
+	policy := RateLimitPolicy{Algorithm: cfg.Algorithm, Limit: cfg.Limit, Window: cfg.Window}
+	policyHeader := strconv.Itoa(cfg.Limit) + ";w=" + strconv.Itoa(ceilSeconds(cfg.Window))
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return next(c)
+		}
+		key, err := cfg.KeyFunc(c)
+		if err != nil {
+			return err
+		}
+		result, err := cfg.Store.Take(c.Req().Raw().Context(), key, policy, time.Now())
+		if err != nil {
+			return err
+		}
+
+		h := c.Response().Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		h.Set("RateLimit-Policy", policyHeader)
+		if !result.Allowed {
+			h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+			return &RateLimitError{Result: result}
+		}
+		return next(c)
+	}
+}
+
+// RateLimitByIP keys requests by client IP, as resolved by Req().RealIP()
+// through TakibiOption.TrustedProxies.
+func RateLimitByIP[Bindings any]() func(c interfaces.IContext[Bindings]) (string, error) {
+	return func(c interfaces.IContext[Bindings]) (string, error) {
+		return "ip:" + c.Req().RealIP(), nil
+	}
+}
+
+// RateLimitByHeader keys requests by a header value, e.g. an API key. Requests
+// without the header share one bucket.
+func RateLimitByHeader[Bindings any](name string) func(c interfaces.IContext[Bindings]) (string, error) {
+	return func(c interfaces.IContext[Bindings]) (string, error) {
+		return "header:" + name + ":" + c.Req().HeaderBy(name), nil
+	}
+}
+
+// RateLimitByRoute keys requests by method and registered route pattern, so
+// the limit is shared by every client of an endpoint.
+func RateLimitByRoute[Bindings any]() func(c interfaces.IContext[Bindings]) (string, error) {
+	return func(c interfaces.IContext[Bindings]) (string, error) {
+		return "route:" + c.Req().Raw().Method + " " + c.RoutePath(), nil
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds for the delta-seconds headers.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+var _ interfaces.IStatusError = (*RateLimitError)(nil)
@@ -0,0 +1,172 @@
+package middlewares
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rate limiting algorithms.
+const (
+	// RateLimitTokenBucket refills Limit tokens evenly over Window, allowing
+	// bursts of up to Limit requests.
+	RateLimitTokenBucket = "token_bucket"
+	// RateLimitSlidingWindow allows Limit requests in any Window, weighting
+	// the previous fixed window by its overlap with the sliding one.
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// RateLimitPolicy describes one limit: Limit requests per Window under
+// Algorithm.
+type RateLimitPolicy struct {
+	Algorithm string
+	Limit     int
+	Window    time.Duration
+}
+
+// RateLimitState is the per-key counter state. It is plain data so a store can
+// persist it as JSON, e.g. in Workers KV or a Durable Object.
+type RateLimitState struct {
+	// token bucket
+	Tokens float64   `json:"tokens,omitempty"`
+	Last   time.Time `json:"last,omitzero"`
+	// sliding window
+	WindowStart time.Time `json:"windowStart,omitzero"`
+	Previous    int       `json:"previous,omitempty"`
+	Current     int       `json:"current,omitempty"`
+}
+
+// RateLimitResult is the outcome of one request against a policy.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the quota is fully restored.
+	Reset time.Duration
+	// RetryAfter is how long until the next request can pass; zero when
+	// Allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore applies a policy to the state kept for key. Implementations
+// must make the read-modify-write atomic per key; Apply holds the algorithm,
+// so a store only loads, applies and saves:
+//
+//	state := load(key) // zero RateLimitState when absent
+//	next, result := policy.Apply(state, now)
+//	save(key, next, policy.Window)
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error)
+}
+
+// Apply consumes one request from state at now and returns the new state.
+func (p RateLimitPolicy) Apply(state RateLimitState, now time.Time) (RateLimitState, RateLimitResult) {
+	if p.Algorithm == RateLimitSlidingWindow {
+		return p.slidingWindow(state, now)
+	}
+	return p.tokenBucket(state, now)
+}
+
+func (p RateLimitPolicy) tokenBucket(state RateLimitState, now time.Time) (RateLimitState, RateLimitResult) {
+	capacity := float64(p.Limit)
+	perToken := max(p.Window/time.Duration(p.Limit), 1)
+
+	tokens := capacity
+	if !state.Last.IsZero() {
+		elapsed := now.Sub(state.Last)
+		tokens = math.Min(capacity, state.Tokens+float64(elapsed)/float64(perToken))
+	}
+
+	result := RateLimitResult{Limit: p.Limit}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((capacity - tokens) * float64(perToken))
+	return RateLimitState{Tokens: tokens, Last: now}, result
+}
+
+func (p RateLimitPolicy) slidingWindow(state RateLimitState, now time.Time) (RateLimitState, RateLimitResult) {
+	start := now.Truncate(p.Window)
+	switch {
+	case state.WindowStart.Equal(start):
+	case state.WindowStart.Add(p.Window).Equal(start):
+		state = RateLimitState{WindowStart: start, Previous: state.Current}
+	default:
+		state = RateLimitState{WindowStart: start}
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(p.Window)
+	estimated := float64(state.Previous)*weight + float64(state.Current)
+
+	result := RateLimitResult{Limit: p.Limit, Reset: p.Window - elapsed}
+	if estimated+1 <= float64(p.Limit) {
+		state.Current++
+		estimated++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = p.slidingRetryAfter(state, elapsed)
+	}
+	result.Remaining = max(0, p.Limit-int(math.Ceil(estimated)))
+	return state, result
+}
+
+// slidingRetryAfter returns how long until the weighted count leaves room for
+// one more request.
+func (p RateLimitPolicy) slidingRetryAfter(state RateLimitState, elapsed time.Duration) time.Duration {
+	free := float64(p.Limit - state.Current - 1)
+	if free >= 0 && state.Previous > 0 {
+		// previous*(1 - t/window) + current + 1 <= limit
+		t := time.Duration(float64(p.Window) * (1 - free/float64(state.Previous)))
+		return t - elapsed
+	}
+	// the current window alone is full: wait for it to become the previous
+	// one, then for enough of it to slide out
+	wait := p.Window - elapsed
+	if state.Current > 0 {
+		wait += time.Duration(float64(p.Window) * (1 - float64(p.Limit-1)/float64(state.Current)))
+	}
+	return wait
+}
+
+// MemoryRateLimitStore keeps the state in process memory. It suits a single
+// native server; on Workers each isolate would count separately, so use a
+// store backed by a Durable Object there.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryRateLimitEntry
+	nextSweep time.Time
+}
+
+type memoryRateLimitEntry struct {
+	state   RateLimitState
+	expires time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: map[string]memoryRateLimitEntry{}}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.After(s.nextSweep) {
+		// drop keys idle long enough that their state is back to full quota
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(time.Minute)
+	}
+
+	state, result := policy.Apply(s.entries[key].state, now)
+	s.entries[key] = memoryRateLimitEntry{state: state, expires: now.Add(2 * policy.Window)}
+	return result, nil
+}
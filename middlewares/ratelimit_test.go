@@ -0,0 +1,206 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitPolicy_TokenBucket(t *testing.T) {
+	p := middlewares.RateLimitPolicy{Algorithm: middlewares.RateLimitTokenBucket, Limit: 2, Window: 10 * time.Second}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	state, r := p.Apply(middlewares.RateLimitState{}, now)
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 1, r.Remaining)
+	assert.Equal(t, 5*time.Second, r.Reset)
+
+	state, r = p.Apply(state, now)
+	assert.True(t, r.Allowed, "burst up to Limit")
+	assert.Equal(t, 0, r.Remaining)
+
+	state, r = p.Apply(state, now.Add(time.Second))
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 4*time.Second, r.RetryAfter)
+
+	_, r = p.Apply(state, now.Add(5*time.Second))
+	assert.True(t, r.Allowed, "one token refilled after Window/Limit")
+
+	t.Run("Window shorter than Limit nanoseconds", func(t *testing.T) {
+		p := middlewares.RateLimitPolicy{Limit: 10, Window: 5}
+
+		state, r := p.Apply(middlewares.RateLimitState{}, now)
+		assert.True(t, r.Allowed)
+		_, r = p.Apply(state, now.Add(time.Nanosecond))
+		assert.True(t, r.Allowed)
+		assert.Equal(t, 9, r.Remaining)
+	})
+}
+
+func TestRateLimitPolicy_SlidingWindow(t *testing.T) {
+	p := middlewares.RateLimitPolicy{Algorithm: middlewares.RateLimitSlidingWindow, Limit: 4, Window: 10 * time.Second}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var (
+		state middlewares.RateLimitState
+		r     middlewares.RateLimitResult
+	)
+	for i := range 4 {
+		state, r = p.Apply(state, start.Add(time.Second))
+		assert.True(t, r.Allowed, i)
+	}
+	assert.Equal(t, 0, r.Remaining)
+	assert.Equal(t, 9*time.Second, r.Reset)
+
+	state, r = p.Apply(state, start.Add(2*time.Second))
+	assert.False(t, r.Allowed)
+	// next window: 4*(1-t/10)+1 <= 4 once t >= 2.5s
+	assert.Equal(t, 10500*time.Millisecond, r.RetryAfter)
+
+	// 1s into the next window the previous 4 still weigh 3.6
+	_, r = p.Apply(state, start.Add(11*time.Second))
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 1500*time.Millisecond, r.RetryAfter)
+
+	_, r = p.Apply(state, start.Add(13*time.Second))
+	assert.True(t, r.Allowed)
+
+	_, r = p.Apply(state, start.Add(25*time.Second))
+	assert.True(t, r.Allowed, "windows older than the previous one are forgotten")
+	assert.Equal(t, 3, r.Remaining)
+}
+
+func rateLimitRoutes(app interfaces.ITakibi[any]) {
+	app.Get("/a", func(c interfaces.IContext[any]) error { return c.Text("a") })
+	app.Get("/b", func(c interfaces.IContext[any]) error { return c.Text("b") })
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("rejects with 429 and headers through the default error handler", func(t *testing.T) {
+		app := newTestApp(nil, rateLimitRoutes, middlewares.RateLimit(middlewares.RateLimitConfig[any]{Limit: 2, Window: time.Minute}))
+
+		first := app.Camp(http.MethodGet, "/a")
+		app.Camp(http.MethodGet, "/a")
+		rejected := app.Camp(http.MethodGet, "/a")
+
+		assert.Equal(t, http.StatusOK, first.StatusCode())
+		assert.Equal(t, "2", first.Raw().Header.Get("RateLimit-Limit"))
+		assert.Equal(t, "1", first.Raw().Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", first.Raw().Header.Get("RateLimit-Reset"))
+		assert.Equal(t, "2;w=60", first.Raw().Header.Get("RateLimit-Policy"))
+		assert.Empty(t, first.Raw().Header.Get("Retry-After"))
+
+		assert.Equal(t, http.StatusTooManyRequests, rejected.StatusCode())
+		assert.Equal(t, "0", rejected.Raw().Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", rejected.Raw().Header.Get("Retry-After"))
+		body, _ := readBody(rejected)
+		assert.Equal(t, "Too Many Requests", body)
+	})
+
+	t.Run("custom error handler sees the result", func(t *testing.T) {
+		app := newTestApp(nil, rateLimitRoutes, middlewares.RateLimit(middlewares.RateLimitConfig[any]{
+			Algorithm: middlewares.RateLimitSlidingWindow,
+			Limit:     1,
+			Window:    time.Minute,
+		}))
+		app.OnError(func(c interfaces.IContext[any], err error) error {
+			var rle *middlewares.RateLimitError
+			if errors.As(err, &rle) {
+				return c.Status(rle.StatusCode()).Json(map[string]any{"limit": rle.Result.Limit})
+			}
+			return err
+		})
+
+		app.Camp(http.MethodGet, "/a")
+		resp := app.Camp(http.MethodGet, "/a")
+
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode())
+		data, _ := resp.Json()
+		assert.Equal(t, float64(1), data["limit"])
+	})
+
+	t.Run("keys by header", func(t *testing.T) {
+		app := newTestApp(nil, rateLimitRoutes, middlewares.RateLimit(middlewares.RateLimitConfig[any]{
+			Limit:   1,
+			Window:  time.Minute,
+			KeyFunc: middlewares.RateLimitByHeader[any]("X-API-Key"),
+		}))
+
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/a", interfaces.Header("X-API-Key", "k1")).StatusCode())
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/a", interfaces.Header("X-API-Key", "k2")).StatusCode())
+		assert.Equal(t, http.StatusTooManyRequests, app.Camp(http.MethodGet, "/a", interfaces.Header("X-API-Key", "k1")).StatusCode())
+	})
+
+	t.Run("keys by route", func(t *testing.T) {
+		app := newTestApp(nil, rateLimitRoutes, middlewares.RateLimit(middlewares.RateLimitConfig[any]{
+			Limit:   1,
+			Window:  time.Minute,
+			KeyFunc: middlewares.RateLimitByRoute[any](),
+		}))
+
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/a").StatusCode())
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/b").StatusCode())
+		assert.Equal(t, http.StatusTooManyRequests, app.Camp(http.MethodGet, "/b").StatusCode())
+	})
+
+	t.Run("Skip bypasses counting", func(t *testing.T) {
+		app := newTestApp(nil, rateLimitRoutes, middlewares.RateLimit(middlewares.RateLimitConfig[any]{
+			Limit:  1,
+			Window: time.Minute,
+			Skip: func(c interfaces.IContext[any]) bool {
+				return c.Req().Raw().URL.Path == "/b"
+			},
+		}))
+
+		for range 3 {
+			assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/b").StatusCode())
+		}
+		assert.Equal(t, http.StatusOK, app.Camp(http.MethodGet, "/a").StatusCode())
+	})
+
+	t.Run("store error goes to the error handler", func(t *testing.T) {
+		app := newTestApp(nil, rateLimitRoutes, middlewares.RateLimit(middlewares.RateLimitConfig[any]{
+			Limit:  1,
+			Window: time.Minute,
+			Store:  failingStore{},
+		}))
+
+		assert.Equal(t, http.StatusInternalServerError, app.Camp(http.MethodGet, "/a").StatusCode())
+	})
+
+	t.Run("misconfiguration panics", func(t *testing.T) {
+		assert.Panics(t, func() { middlewares.RateLimit(middlewares.RateLimitConfig[any]{Window: time.Minute}) })
+		assert.Panics(t, func() { middlewares.RateLimit(middlewares.RateLimitConfig[any]{Limit: 1}) })
+		assert.Panics(t, func() { middlewares.RateLimit(middlewares.RateLimitConfig[any]{Limit: 10, Window: 5}) })
+		assert.Panics(t, func() {
+			middlewares.RateLimit(middlewares.RateLimitConfig[any]{Algorithm: "leaky", Limit: 1, Window: time.Second})
+		})
+	})
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, middlewares.RateLimitPolicy, time.Time) (middlewares.RateLimitResult, error) {
+	return middlewares.RateLimitResult{}, errors.New("kv unavailable")
+}
+
+func TestMemoryRateLimitStore_SweepsIdleKeys(t *testing.T) {
+	store := middlewares.NewMemoryRateLimitStore()
+	p := middlewares.RateLimitPolicy{Limit: 1, Window: time.Second}
+	now := time.Now()
+
+	r, _ := store.Take(context.Background(), "k", p, now)
+	assert.True(t, r.Allowed)
+	r, _ = store.Take(context.Background(), "k", p, now)
+	assert.False(t, r.Allowed)
+
+	r, _ = store.Take(context.Background(), "k", p, now.Add(time.Hour))
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 0, r.Remaining)
+}
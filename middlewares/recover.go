@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// PanicError is the error Recover returns in place of a panic. Value is the
+// value passed to panic; Stack holds the goroutine stack at the panic site
+// when RecoverConfig.StackTrace is enabled.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error, so errors.Is/As see
+// through the recovered panic.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+type RecoverConfig[Bindings any] struct {
+	// StackTrace captures the stack into PanicError.Stack.
+	StackTrace bool
+	// OnPanic is called with the recovered panic before it is returned,
+	// e.g. to log it or report it to an error tracker.
+	OnPanic func(c interfaces.IContext[Bindings], err *PanicError)
+}
+
+func DefaultRecoverConfig[Bindings any]() RecoverConfig[Bindings] {
+	return RecoverConfig[Bindings]{
+		StackTrace: true,
+	}
+}
+
+// Recover converts a panic raised further down the chain into a *PanicError
+// returned like any other handler error, so it reaches app.OnError and the
+// client gets a normal error response instead of a dropped connection (or, on
+// wasm, a failed Worker invocation). http.ErrAbortHandler is re-panicked so
+// net/http can abort the response as intended.
+//
+// Register it first so it wraps every other middleware:
+//
+//	app.Use("*", middlewares.Recover[Bindings]())
+func Recover[Bindings any](config ...RecoverConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultRecoverConfig[Bindings]()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) (err error) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if rErr, ok := r.(error); ok && errors.Is(rErr, http.ErrAbortHandler) {
+				panic(r)
+			}
+			pe := &PanicError{Value: r}
+			if cfg.StackTrace {
+				pe.Stack = debug.Stack()
+			}
+			if cfg.OnPanic != nil {
+				cfg.OnPanic(c, pe)
+			}
+			err = pe
+		}()
+		return next(c)
+	}
+}
@@ -0,0 +1,94 @@
+package middlewares_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	t.Run("panic becomes a PanicError with stack", func(t *testing.T) {
+		mw := middlewares.Recover[any]()
+		ctx := takibi.NewContext[any](httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+
+		err := mw(ctx, func(c interfaces.IContext[any]) error {
+			panic("boom")
+		})
+
+		var pe *middlewares.PanicError
+		if assert.True(t, errors.As(err, &pe)) {
+			assert.Equal(t, "boom", pe.Value)
+			assert.NotEmpty(t, pe.Stack)
+			assert.Equal(t, "panic: boom", pe.Error())
+		}
+	})
+
+	t.Run("panic with an error value unwraps to it", func(t *testing.T) {
+		mw := middlewares.Recover[any](middlewares.RecoverConfig[any]{})
+		ctx := takibi.NewContext[any](httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+
+		err := mw(ctx, func(c interfaces.IContext[any]) error {
+			panic(io.ErrUnexpectedEOF)
+		})
+
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		var pe *middlewares.PanicError
+		assert.True(t, errors.As(err, &pe))
+		assert.Empty(t, pe.Stack)
+	})
+
+	t.Run("no panic passes the result through", func(t *testing.T) {
+		mw := middlewares.Recover[any]()
+		ctx := takibi.NewContext[any](httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+		want := errors.New("plain")
+
+		err := mw(ctx, func(c interfaces.IContext[any]) error {
+			return want
+		})
+
+		assert.Equal(t, want, err)
+	})
+
+	t.Run("ErrAbortHandler is re-panicked", func(t *testing.T) {
+		mw := middlewares.Recover[any]()
+		ctx := takibi.NewContext[any](httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			_ = mw(ctx, func(c interfaces.IContext[any]) error {
+				panic(http.ErrAbortHandler)
+			})
+		})
+	})
+
+	t.Run("panic reaches OnError and the callback", func(t *testing.T) {
+		var reported *middlewares.PanicError
+		app := takibi.New[any](nil)
+		app.OnError(func(c interfaces.IContext[any], err error) error {
+			return c.Status(http.StatusServiceUnavailable).Text("recovered")
+		})
+		app.Use("*", middlewares.Recover(middlewares.RecoverConfig[any]{
+			OnPanic: func(c interfaces.IContext[any], err *middlewares.PanicError) {
+				reported = err
+			},
+		}))
+		app.Get("/panic", func(c interfaces.IContext[any]) error {
+			panic("boom")
+		})
+
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "recovered", rec.Body.String())
+		if assert.NotNil(t, reported) {
+			assert.Equal(t, "boom", reported.Value)
+		}
+	})
+}
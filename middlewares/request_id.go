@@ -0,0 +1,126 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+type RequestIDConfig struct {
+	// Header carries the ID in both directions; "" uses X-Request-Id.
+	Header string
+	// Generator creates an ID when the request carries no usable one; nil
+	// generates a UUIDv7.
+	Generator func() string
+	// Validator reports whether an incoming ID may be reused; nil accepts 1-128
+	// characters of [A-Za-z0-9] and -_.:+/=.
+	Validator func(id string) bool
+}
+
+func DefaultRequestIDConfig() RequestIDConfig {
+	return RequestIDConfig{
+		Header:    constants.HeaderRequestID,
+		Generator: NewUUIDv7,
+		Validator: validRequestID,
+	}
+}
+
+// RequestID assigns every request an ID, taken from the incoming header when
+// it passes the Validator so IDs propagate across services, otherwise created
+// by the Generator. On wasm Cloudflare's cf-ray header is preferred over
+// both, since it is set by the edge and matches the Workers dashboard and
+// logs. The ID is echoed on the response header and stored in the context,
+// where RequestIDFrom, Logger and OnError handlers read it.
+//
+// Register it before Logger so access records carry the ID:
+//
+//	app.Use("*", middlewares.RequestID[Bindings]())
+//	app.Use("*", middlewares.Logger[Bindings]())
+func RequestID[Bindings any](config ...RequestIDConfig) interfaces.MiddlewareFunc[Bindings] {
+	cfg := DefaultRequestIDConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Header == "" {
+		cfg.Header = constants.HeaderRequestID
+	}
+	if cfg.Generator == nil {
+		cfg.Generator = NewUUIDv7
+	}
+	if cfg.Validator == nil {
+		cfg.Validator = validRequestID
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		req := c.Req().Raw()
+		id := platformRequestID(req)
+		if !cfg.Validator(id) {
+			id = req.Header.Get(cfg.Header)
+			if !cfg.Validator(id) {
+				id = cfg.Generator()
+			}
+		}
+
+		c.Set(constants.ContextKeyRequestID, id)
+		c.Response().Header().Set(cfg.Header, id)
+		return next(c)
+	}
+}
+
+// RequestIDFrom returns the ID stored by RequestID, or "" when RequestID is
+// not installed on the route.
+func RequestIDFrom(c interface {
+	Get(string) (any, bool)
+}) string {
+	if v, ok := c.Get(constants.ContextKeyRequestID); ok {
+		if id, ok := v.(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// NewUUIDv7 returns a random, time-ordered RFC 9562 version 7 UUID.
+func NewUUIDv7() string {
+	var u [16]byte
+	_, _ = rand.Read(u[6:])
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(u[:6], ms[2:])
+	u[6] = u[6]&0x0f | 0x70 // version 7
+	u[8] = u[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// validRequestID keeps client-supplied IDs short and free of characters that
+// could break log lines or header values.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		b := id[i]
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		case b == '-', b == '_', b == '.', b == ':', b == '+', b == '/', b == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,11 @@
+//go:build !wasm
+
+package middlewares
+
+import "net/http"
+
+// platformRequestID returns the ID assigned by the hosting platform; native
+// servers have none.
+func platformRequestID(*http.Request) string {
+	return ""
+}
@@ -0,0 +1,114 @@
+package middlewares_test
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+var uuidV7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// requestIDRoutes keeps the ID the handler sees in seen.
+func requestIDRoutes(seen *string) func(app interfaces.ITakibi[any]) {
+	return func(app interfaces.ITakibi[any]) {
+		app.Get("/", func(c interfaces.IContext[any]) error {
+			*seen = middlewares.RequestIDFrom(c)
+			return c.Text("ok")
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	t.Run("generates a UUIDv7 when none is sent", func(t *testing.T) {
+		seen := new(string)
+		app := newTestApp(nil, requestIDRoutes(seen), middlewares.RequestID[any]())
+
+		resp := app.Camp(http.MethodGet, "/")
+
+		id := resp.Raw().Header.Get("X-Request-Id")
+		assert.Regexp(t, uuidV7Pattern, id)
+		assert.Equal(t, id, *seen)
+	})
+
+	t.Run("propagates a valid incoming ID", func(t *testing.T) {
+		seen := new(string)
+		app := newTestApp(nil, requestIDRoutes(seen), middlewares.RequestID[any]())
+
+		resp := app.Camp(http.MethodGet, "/", interfaces.Header("X-Request-Id", "upstream-42"))
+
+		assert.Equal(t, "upstream-42", resp.Raw().Header.Get("X-Request-Id"))
+		assert.Equal(t, "upstream-42", *seen)
+	})
+
+	t.Run("replaces an invalid incoming ID", func(t *testing.T) {
+		app := newTestApp(nil, requestIDRoutes(new(string)), middlewares.RequestID[any](middlewares.RequestIDConfig{
+			Generator: func() string { return "generated" },
+		}))
+
+		resp := app.Camp(http.MethodGet, "/", interfaces.Header("X-Request-Id", "bad id\twith spaces"))
+
+		assert.Equal(t, "generated", resp.Raw().Header.Get("X-Request-Id"))
+	})
+
+	t.Run("custom header and validator", func(t *testing.T) {
+		seen := new(string)
+		app := newTestApp(nil, requestIDRoutes(seen), middlewares.RequestID[any](middlewares.RequestIDConfig{
+			Header:    "X-Trace",
+			Generator: func() string { return "generated" },
+			Validator: func(id string) bool { return len(id) == 4 },
+		}))
+
+		resp := app.Camp(http.MethodGet, "/", interfaces.Header("X-Trace", "abcd"))
+		assert.Equal(t, "abcd", resp.Raw().Header.Get("X-Trace"))
+		assert.Equal(t, "abcd", *seen)
+
+		resp = app.Camp(http.MethodGet, "/", interfaces.Header("X-Trace", "abcde"))
+		assert.Equal(t, "generated", resp.Raw().Header.Get("X-Trace"))
+		assert.Empty(t, resp.Raw().Header.Get("X-Request-Id"))
+	})
+
+	t.Run("OnError sees the ID", func(t *testing.T) {
+		var seen string
+		app := takibi.New[any](nil)
+		app.OnError(func(c interfaces.IContext[any], err error) error {
+			seen = middlewares.RequestIDFrom(c)
+			return c.Status(http.StatusInternalServerError).Text("error " + seen)
+		})
+		app.Use("*", middlewares.RequestID[any]())
+		app.Get("/fail", func(c interfaces.IContext[any]) error {
+			return errors.New("boom")
+		})
+
+		resp := app.Camp(http.MethodGet, "/fail", interfaces.Header("X-Request-Id", "req-7"))
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
+		assert.Equal(t, "req-7", seen)
+		assert.Equal(t, "req-7", resp.Raw().Header.Get("X-Request-Id"))
+	})
+
+	t.Run("RequestIDFrom without the middleware", func(t *testing.T) {
+		var seen = "unset"
+		app := takibi.New[any](nil)
+		app.Get("/", func(c interfaces.IContext[any]) error {
+			seen = middlewares.RequestIDFrom(c)
+			return nil
+		})
+
+		app.Camp(http.MethodGet, "/")
+
+		assert.Equal(t, "", seen)
+	})
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	a, b := middlewares.NewUUIDv7(), middlewares.NewUUIDv7()
+
+	assert.Regexp(t, uuidV7Pattern, a)
+	assert.NotEqual(t, a, b)
+}
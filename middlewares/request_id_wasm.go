@@ -0,0 +1,11 @@
+//go:build wasm
+
+package middlewares
+
+import "net/http"
+
+// platformRequestID returns Cloudflare's cf-ray, which identifies the request
+// in the Workers dashboard and logs.
+func platformRequestID(r *http.Request) string {
+	return r.Header.Get("Cf-Ray")
+}
@@ -1,10 +1,14 @@
 package middlewares_test
 
 import (
+	"context"
+	"crypto/tls"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/a-h/templ"
 	"github.com/poteto0/takibi"
 	"github.com/poteto0/takibi/interfaces"
 	"github.com/poteto0/takibi/middlewares"
@@ -49,4 +53,84 @@ func TestSecureHeaders(t *testing.T) {
 		_, nextCalled := run(middlewares.SecureHeaders[any]())
 		assert.True(t, nextCalled)
 	})
+
+	t.Run("HSTS and COOP are opt-in", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.TLS = &tls.ConnectionState{}
+		rec := httptest.NewRecorder()
+		_ = middlewares.SecureHeaders[any]()(takibi.NewContext[any](rec, req, nil, nil), func(interfaces.IContext[any]) error { return nil })
+		assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+		assert.Empty(t, rec.Header().Get("Cross-Origin-Opener-Policy"))
+	})
+
+	t.Run("HSTS only over HTTPS", func(t *testing.T) {
+		cfg := middlewares.DefaultSecureHeadersConfig()
+		cfg.StrictTransportSecurity = "max-age=15552000; includeSubDomains"
+		rec, _ := run(middlewares.SecureHeaders[any](cfg))
+		assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.TLS = &tls.ConnectionState{}
+		rec = httptest.NewRecorder()
+		_ = middlewares.SecureHeaders[any](cfg)(takibi.NewContext[any](rec, req, nil, nil), func(interfaces.IContext[any]) error { return nil })
+		assert.Equal(t, "max-age=15552000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("isolation and report-only headers", func(t *testing.T) {
+		rec, _ := run(middlewares.SecureHeaders[any](middlewares.SecureHeadersConfig{
+			PermissionsPolicy:                 "camera=(), geolocation=()",
+			CrossOriginOpenerPolicyReportOnly: "same-origin; report-to=\"coop\"",
+			CrossOriginEmbedderPolicy:         "require-corp",
+			CrossOriginResourcePolicy:         "same-site",
+			ReportingEndpoints:                `coop="https://example.com/reports"`,
+		}))
+		assert.Equal(t, "camera=(), geolocation=()", rec.Header().Get("Permissions-Policy"))
+		assert.Equal(t, `same-origin; report-to="coop"`, rec.Header().Get("Cross-Origin-Opener-Policy-Report-Only"))
+		assert.Empty(t, rec.Header().Get("Cross-Origin-Opener-Policy"))
+		assert.Equal(t, "require-corp", rec.Header().Get("Cross-Origin-Embedder-Policy"))
+		assert.Equal(t, "same-site", rec.Header().Get("Cross-Origin-Resource-Policy"))
+		assert.Equal(t, `coop="https://example.com/reports"`, rec.Header().Get("Reporting-Endpoints"))
+		assert.Empty(t, rec.Header().Get("X-Frame-Options"))
+	})
+}
+
+func TestSecureHeaders_CSPNonce(t *testing.T) {
+	script := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, `<script nonce="`+templ.GetNonce(ctx)+`"></script>`)
+		return err
+	})
+	var fromHandler string
+	app := takibi.New[any](nil)
+	app.Use("*", middlewares.SecureHeaders[any](middlewares.SecureHeadersConfig{
+		ContentSecurityPolicy:           "script-src 'nonce-{nonce}'",
+		ContentSecurityPolicyReportOnly: "script-src 'strict-dynamic' 'nonce-{nonce}'",
+	}))
+	app.Get("/page/:id", func(c interfaces.IContext[any]) error {
+		fromHandler = middlewares.CSPNonce(c)
+		assert.Equal(t, "7", c.ParamBy("id"), "params survive the request swap")
+		return c.Render(&interfaces.RenderConfig{Component: script})
+	})
+
+	first := app.Camp(http.MethodGet, "/page/7")
+	second := app.Camp(http.MethodGet, "/page/7")
+
+	nonce := fromHandler
+	assert.Regexp(t, `^[A-Za-z0-9+/]{22}==$`, nonce)
+	assert.Equal(t, "script-src 'nonce-"+nonce+"'", second.Raw().Header.Get("Content-Security-Policy"))
+	assert.Equal(t, "script-src 'strict-dynamic' 'nonce-"+nonce+"'", second.Raw().Header.Get("Content-Security-Policy-Report-Only"))
+	body, _ := readBody(second)
+	assert.Equal(t, `<script nonce="`+nonce+`"></script>`, body)
+	assert.NotEqual(t, first.Raw().Header.Get("Content-Security-Policy"), second.Raw().Header.Get("Content-Security-Policy"))
+
+	t.Run("no nonce without the placeholder", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		ctx := takibi.NewContext[any](rec, httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+		_ = middlewares.SecureHeaders[any](middlewares.SecureHeadersConfig{
+			ContentSecurityPolicy: "default-src 'self'",
+		})(ctx, func(c interfaces.IContext[any]) error {
+			assert.Empty(t, middlewares.CSPNonce(c))
+			return nil
+		})
+		assert.Equal(t, "default-src 'self'", rec.Header().Get("Content-Security-Policy"))
+	})
 }
@@ -1,40 +1,192 @@
 package middlewares
 
 import (
-	"fmt"
+	"context"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/poteto0/takibi/constants"
 	"github.com/poteto0/takibi/interfaces"
 )
 
-func Timeout[Bindings any](limit time.Duration) interfaces.MiddlewareFunc[Bindings] {
-	return func(ctx interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
-		var result error
+// statusClientClosedRequest is the non-standard status (from nginx) Timeout
+// reports when the client goes away before the handlers finish.
+const statusClientClosedRequest = 499
 
-		done := make(chan struct{})
+type TimeoutConfig[Bindings any] struct {
+	// OnTimeout writes the response once the deadline passes. nil returns
+	// constants.ErrRequestTimeout, which the default error handler answers
+	// with 503.
+	OnTimeout interfaces.HandlerFunc[Bindings]
+}
+
+// Timeout bounds the rest of the chain to limit. The handlers run on a
+// goroutine with a Clone of the context whose request context carries the
+// deadline, so database calls and outgoing requests made with
+// c.Req().Raw().Context() are cancelled when it passes.
+//
+// Their output is buffered and sent only if they finish in time. Otherwise
+// Timeout answers on its own context (OnTimeout, or ErrRequestTimeout to the
+// error handler) and the late handler's writes fail with
+// http.ErrHandlerTimeout, as do its reads of the request body; a read in
+// progress at the deadline is waited for before Timeout answers. The clone
+// is never returned to the context pool, so a handler that ignores the
+// deadline cannot touch another request. Streamed responses are buffered
+// too, so keep them outside Timeout. When the client disconnects first, the
+// error carries status 499 and unwraps to context.Canceled.
+//
+// Values handlers Set on the clone are not visible to middlewares outside
+// Timeout. A panic in the handlers is returned as a *PanicError.
+//
+//	app.Use("/api/*", middlewares.Timeout[Bindings](5*time.Second))
+func Timeout[Bindings any](limit time.Duration, config ...TimeoutConfig[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	var cfg TimeoutConfig[Bindings]
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		req := c.Req().Raw()
+		res := c.Response()
+		ctx, cancel := context.WithTimeout(req.Context(), limit)
+		defer cancel()
+
+		tw := &timeoutWriter{header: res.Header().Clone()}
+		handlerReq := req.WithContext(ctx)
+		var body *timeoutBody
+		if req.Body != nil && req.Body != http.NoBody {
+			// the server reclaims the body once Timeout has answered
+			body = &timeoutBody{body: req.Body}
+			handlerReq.Body = body
+		}
+		handlerCtx := c.Clone()
+		handlerCtx.SetRequest(handlerReq)
+		handlerCtx.SetResponse(tw)
+
+		done := make(chan error, 1)
 		go func() {
 			defer func() {
-				// in case of panic
 				if r := recover(); r != nil {
-					result = fmt.Errorf("%v", r)
+					done <- &PanicError{Value: r, Stack: debug.Stack()}
 				}
-
-				close(done)
 			}()
-
-			// do
-			result = next(ctx)
+			done <- next(handlerCtx)
 		}()
 
-		timer := time.NewTimer(limit)
-		defer timer.Stop()
-
 		select {
-		case <-done:
-			return result
-		case <-timer.C:
-			return constants.ErrRequestTimeout
+		case err := <-done:
+			tw.commit(res)
+			return err
+		case <-ctx.Done():
+			tw.timeout()
+			if body != nil {
+				body.cut()
+			}
+			if ctx.Err() != context.DeadlineExceeded {
+				// the client went away; there is no one to answer
+				return &statusError{code: statusClientClosedRequest, err: ctx.Err()}
+			}
+			if cfg.OnTimeout != nil {
+				return cfg.OnTimeout(c)
+			}
+			return &statusError{code: http.StatusServiceUnavailable, err: constants.ErrRequestTimeout}
 		}
 	}
 }
+
+// timeoutWriter buffers a response until Timeout commits it or, once the
+// deadline has passed, rejects it.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	buf      []byte
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut && w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.buf = append(w.buf, b...)
+	return len(b), nil
+}
+
+// commit sends the buffered response on the handler's behalf.
+func (w *timeoutWriter) commit(res http.ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	h := res.Header()
+	clear(h)
+	for name, values := range w.header {
+		h[name] = values
+	}
+	if w.status != 0 {
+		res.WriteHeader(w.status)
+	}
+	if len(w.buf) > 0 {
+		_, _ = res.Write(w.buf)
+	}
+}
+
+// timeout discards the buffered response and rejects later writes.
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+	w.buf = nil
+}
+
+// timeoutBody is the request body seen by the handlers; it fails once
+// Timeout has answered without them. The lock is held across each Read and
+// Close, so cut waits for one in progress and none starts after it.
+type timeoutBody struct {
+	mu   sync.Mutex
+	body io.ReadCloser
+	done bool
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return 0, http.ErrHandlerTimeout
+	}
+	return b.body.Read(p)
+}
+
+func (b *timeoutBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return nil
+	}
+	return b.body.Close()
+}
+
+// cut hands the body back to the server.
+func (b *timeoutBody) cut() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done = true
+}
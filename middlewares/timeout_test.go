@@ -1,8 +1,11 @@
 package middlewares_test
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -79,4 +82,120 @@ func TestTimeout(t *testing.T) {
 			assert.Nil(t, err)
 		}
 	})
+
+	t.Run("default timeout response is 503", func(t *testing.T) {
+		app := takibi.New[any](nil)
+		app.Use("*", middlewares.Timeout[any](10*time.Millisecond))
+		app.Get("/slow", func(c interfaces.IContext[any]) error {
+			<-c.Req().Raw().Context().Done()
+			return c.Text("late")
+		})
+
+		res := app.Camp(http.MethodGet, "/slow")
+		body, _ := readBody(res)
+
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode())
+		assert.Equal(t, "Service Unavailable", body)
+	})
+}
+
+func TestTimeout_BuffersHandlerOutput(t *testing.T) {
+	app := takibi.New[any](nil)
+	app.Use("*", func(c interfaces.IContext[any], next interfaces.HandlerFunc[any]) error {
+		c.Response().Header().Set("X-Outer", "1")
+		return next(c)
+	})
+	app.Use("*", middlewares.Timeout[any](time.Second))
+	app.Get("/items/:id", func(c interfaces.IContext[any]) error {
+		_, hasDeadline := c.Req().Raw().Context().Deadline()
+		assert.True(t, hasDeadline)
+		c.Response().Header().Set("X-Item", c.ParamBy("id"))
+		return c.Status(http.StatusCreated).Text("item " + c.ParamBy("id"))
+	})
+
+	res := app.Camp(http.MethodGet, "/items/7")
+	body, _ := readBody(res)
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode())
+	assert.Equal(t, "item 7", body)
+	assert.Equal(t, "7", res.Raw().Header.Get("X-Item"))
+	assert.Equal(t, "1", res.Raw().Header.Get("X-Outer"))
+}
+
+func TestTimeout_LateHandlerCannotTouchNextRequest(t *testing.T) {
+	release := make(chan struct{})
+	lateWrite := make(chan error, 1)
+
+	app := takibi.New[any](nil)
+	app.Use("/slow", middlewares.Timeout[any](10*time.Millisecond, middlewares.TimeoutConfig[any]{
+		OnTimeout: func(c interfaces.IContext[any]) error {
+			return c.Status(http.StatusGatewayTimeout).Text("too slow")
+		},
+	}))
+	app.Get("/slow", func(c interfaces.IContext[any]) error {
+		<-release
+		c.Set("late", true)
+		c.SetParam(map[string]string{"id": "late"})
+		lateWrite <- c.Text("late body")
+		return nil
+	})
+	app.Get("/fast/:id", func(c interfaces.IContext[any]) error {
+		_, late := c.Get("late")
+		assert.False(t, late)
+		return c.Text("fast " + c.ParamBy("id"))
+	})
+
+	slow := app.Camp(http.MethodGet, "/slow")
+	body, _ := readBody(slow)
+	assert.Equal(t, http.StatusGatewayTimeout, slow.StatusCode())
+	assert.Equal(t, "too slow", body)
+
+	// the pooled context is reused while the late handler is still running
+	close(release)
+	for range 20 {
+		body, _ = readBody(app.Camp(http.MethodGet, "/fast/1"))
+		assert.Equal(t, "fast 1", body)
+	}
+	assert.ErrorIs(t, <-lateWrite, http.ErrHandlerTimeout)
+}
+
+func TestTimeout_ClientDisconnect(t *testing.T) {
+	mw := middlewares.Timeout[any](time.Second)
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(reqCtx)
+	ctx := takibi.NewContext[any](httptest.NewRecorder(), req, nil, nil)
+	release := make(chan struct{})
+	defer close(release)
+
+	err := mw(ctx, func(c interfaces.IContext[any]) error {
+		cancel()
+		<-release
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	var se interfaces.IStatusError
+	if assert.ErrorAs(t, err, &se) {
+		assert.Equal(t, 499, se.StatusCode())
+	}
+}
+
+func TestTimeout_LateHandlerCannotReadBody(t *testing.T) {
+	release := make(chan struct{})
+	lateRead := make(chan error, 1)
+
+	app := takibi.New[any](nil)
+	app.Use("*", middlewares.Timeout[any](10*time.Millisecond))
+	app.Post("/upload", func(c interfaces.IContext[any]) error {
+		<-release
+		_, err := io.ReadAll(c.Req().Raw().Body)
+		lateRead <- err
+		return nil
+	})
+
+	res := app.Camp(http.MethodPost, "/upload", interfaces.Body(strings.NewReader("payload")))
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode())
+
+	close(release)
+	assert.ErrorIs(t, <-lateRead, http.ErrHandlerTimeout)
 }
@@ -11,6 +11,7 @@ type node[Bindings any] struct {
 	children        map[string]interfaces.INode[Bindings]
 	childParamKey   string
 	handler         interfaces.HandlerFunc[Bindings]
+	path            string
 	composedHandler interfaces.HandlerFunc[Bindings]
 	middlewares     []interfaces.MiddlewareFunc[Bindings]
 }
@@ -27,6 +28,12 @@ func (
 	return n.handler
 }
 
+func (
+	n *node[Bindings],
+) Path() string {
+	return n.path
+}
+
 func (
 	n *node[Bindings],
 ) ComposedHandler() interfaces.HandlerFunc[Bindings] {
@@ -137,6 +144,7 @@ func (
 			return constants.ErrHandlerAlreadyExists
 		}
 		currentNode.handler = handler
+		currentNode.path = path
 		currentNode.walkAndCompose(nil)
 		return nil
 	}
@@ -168,6 +176,7 @@ func (
 	}
 
 	currentNode.handler = handler
+	currentNode.path = path
 	currentNode.walkAndCompose(accumulated)
 	return nil
 }
@@ -56,6 +56,22 @@ func TestNode_Add(t *testing.T) {
 	})
 }
 
+func TestNode_Path(t *testing.T) {
+	n := NewNode[any]()
+	handler := func(ctx interfaces.IContext[any]) error { return nil }
+	assert.Nil(t, n.Add("/", handler))
+	assert.Nil(t, n.Add("/users/:id/posts", handler))
+
+	root, _, _ := n.Find("/")
+	assert.Equal(t, "/", root.Path())
+
+	found, _, _ := n.Find("/users/7/posts")
+	assert.Equal(t, "/users/:id/posts", found.Path())
+
+	intermediate, _, _ := n.Find("/users/7")
+	assert.Equal(t, "", intermediate.Path())
+}
+
 func TestNode_AddAndFind(t *testing.T) {
 	// Arrange
 	n := NewNode[any]()
@@ -0,0 +1,77 @@
+package takibi
+
+import (
+	"net/http"
+
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/router"
+	"github.com/poteto0/takibi/thttp"
+)
+
+// dispatch runs the Pre middlewares and the matched handler chain on ctx,
+// handing any returned error to the error handler.
+func (
+	t *takibi[Bindings],
+) dispatch(
+	ctx interfaces.IContext[Bindings],
+) {
+	handler := t.entry
+	if handler == nil {
+		handler = t.route
+	}
+
+	if err := handler(ctx); err != nil {
+		if err := t.errorHandler(ctx, err); err != nil {
+			// fallback
+			ctx.Response().WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+}
+
+// route finds the handler chain for the request, as possibly rewritten by
+// the Pre middlewares, and runs it.
+func (
+	t *takibi[Bindings],
+) route(
+	ctx interfaces.IContext[Bindings],
+) error {
+	r := ctx.Req().Raw()
+	n, middlewares, params := t.router.Find(r.Method, r.URL.Path)
+	if len(params) > 0 {
+		ctx.SetParam(params)
+	}
+
+	var handler interfaces.HandlerFunc[Bindings]
+	if n != nil {
+		handler = n.ComposedHandler()
+		ctx.SetRoutePath(n.Path())
+	}
+
+	if handler == nil {
+		notFound := func(c interfaces.IContext[Bindings]) error {
+			c.Response().WriteHeader(http.StatusNotFound)
+			return nil
+		}
+		handler = router.Compose(notFound, middlewares)
+	}
+
+	return handler(ctx)
+}
+
+func (
+	t *takibi[Bindings],
+) Pre(
+	middleware ...interfaces.MiddlewareFunc[Bindings],
+) {
+	t.pre = append(t.pre, middleware...)
+	t.entry = router.Compose(t.route, t.pre)
+}
+
+// mustValidOption panics on a TakibiOption that cannot be applied, so the
+// misconfiguration surfaces at startup rather than on the first request.
+func mustValidOption(opt interfaces.TakibiOption) {
+	if _, err := thttp.ParseTrustedProxies(opt.TrustedProxies); err != nil {
+		panic("takibi: " + err.Error())
+	}
+}
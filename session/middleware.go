@@ -0,0 +1,312 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/poteto0/takibi/constants"
+	"github.com/poteto0/takibi/cookie"
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// touchInterval is how stale a session's last-seen time may get before an
+// unmodified session is saved again to extend its idle expiry.
+const touchInterval = time.Minute
+
+type Config[Bindings any] struct {
+	// Store keeps the sessions; nil uses CookieStore().
+	Store Store
+	// Secret signs the session cookie and must be at least
+	// constants.MinSignedCookieSecretLen bytes. Secret or SecretFunc is
+	// required.
+	Secret string
+	// SecretFunc supplies the secret per request, e.g. from c.Env().
+	SecretFunc func(c interfaces.IContext[Bindings]) string
+	// EncryptionKey, when set, also encrypts the cookie (AES with a 16, 24
+	// or 32 byte key), so clients can't read a CookieStore session.
+	EncryptionKey string
+	// CookieName names the session cookie; "" uses "session".
+	CookieName string
+	// CookieOptions configures the session cookie; nil uses Path "/",
+	// HttpOnly, Secure and SameSite=Lax. MaxAge is managed by the
+	// middleware.
+	CookieOptions *cookie.CookieOptions
+	// IdleTimeout ends a session unused for this long; 0 uses 30 minutes.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout ends a session this long after it was created (or
+	// regenerated), however active; 0 uses 24 hours.
+	AbsoluteTimeout time.Duration
+}
+
+func DefaultConfig[Bindings any]() Config[Bindings] {
+	return Config[Bindings]{
+		CookieName:      "session",
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+	}
+}
+
+var defaultCookieOptions = &cookie.CookieOptions{
+	Path:     "/",
+	HttpOnly: true,
+	Secure:   true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// Middleware loads the request's session from its cookie and saves it
+// before the response is written. Handlers reach it with From, Get and
+// Flashes. New sessions are only saved once they hold data, so anonymous
+// visitors get no cookie. It panics when neither Secret nor SecretFunc is
+// set, or a static Secret or EncryptionKey is invalid.
+//
+//	app.Use("*", session.Middleware(session.Config[Bindings]{
+//		Store:      session.NewMemoryStore(),
+//		SecretFunc: func(c interfaces.IContext[Bindings]) string { return c.Env().SessionSecret },
+//	}))
+//
+//	app.Post("/login", func(c MyContext) error {
+//		s := session.From(c)
+//		s.Regenerate()
+//		s.Set("user", user)
+//		s.AddFlash("notice", "Welcome back!")
+//		return c.Redirect("/")
+//	})
+func Middleware[Bindings any](config Config[Bindings]) interfaces.MiddlewareFunc[Bindings] {
+	cfg := config
+	defaults := DefaultConfig[Bindings]()
+	if cfg.Store == nil {
+		cfg.Store = CookieStore()
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = defaults.CookieName
+	}
+	if cfg.CookieOptions == nil {
+		cfg.CookieOptions = defaultCookieOptions
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaults.IdleTimeout
+	}
+	if cfg.AbsoluteTimeout <= 0 {
+		cfg.AbsoluteTimeout = defaults.AbsoluteTimeout
+	}
+	if cfg.Secret == "" && cfg.SecretFunc == nil {
+		panic("session: Middleware requires Secret or SecretFunc")
+	}
+	if cfg.Secret != "" && len(cfg.Secret) < constants.MinSignedCookieSecretLen {
+		panic("session: Secret shorter than 32 bytes")
+	}
+	switch len(cfg.EncryptionKey) {
+	case 0, 16, 24, 32:
+	default:
+		panic("session: EncryptionKey must be 16, 24 or 32 bytes")
+	}
+	_, inCookie := cfg.Store.(cookieStore)
+	if inCookie && !cfg.CookieOptions.Chunked {
+		// a session kept in the cookie may outgrow a single one
+		opts := *cfg.CookieOptions
+		opts.Chunked = true
+		cfg.CookieOptions = &opts
+	}
+
+	return func(c interfaces.IContext[Bindings], next interfaces.HandlerFunc[Bindings]) error {
+		secret := cfg.Secret
+		if cfg.SecretFunc != nil {
+			secret = cfg.SecretFunc(c)
+		}
+		if len(secret) < constants.MinSignedCookieSecretLen {
+			return errors.New("session: secret shorter than 32 bytes")
+		}
+		m := &manager[Bindings]{cfg: &cfg, c: c, secret: secret, inCookie: inCookie}
+
+		s, err := m.load()
+		if err != nil {
+			return err
+		}
+		c.Set(constants.ContextKeySession, s)
+
+		res := c.Response()
+		c.SetResponse(&sessionWriter{ResponseWriter: res, commit: func() { m.commit(s, res) }})
+		err = next(c)
+		c.SetResponse(res)
+
+		m.commit(s, res)
+		if err != nil {
+			return err
+		}
+		return s.commitError
+	}
+}
+
+// manager loads and saves the session of one request.
+type manager[Bindings any] struct {
+	cfg      *Config[Bindings]
+	c        interfaces.IContext[Bindings]
+	secret   string
+	inCookie bool
+}
+
+func (m *manager[Bindings]) load() (*Session, error) {
+	now := time.Now()
+	value, ok := m.readCookie()
+	if !ok {
+		return newSession(now), nil
+	}
+
+	fresh := newSession(now)
+	fresh.hadCookie = true
+
+	var data []byte
+	id := ""
+	if m.inCookie {
+		data = []byte(value)
+	} else {
+		id = value
+		stored, found, err := m.cfg.Store.Get(m.c.Req().Raw().Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return fresh, nil
+		}
+		data = stored
+	}
+
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return fresh, nil
+	}
+	if m.inCookie {
+		id = rec.ID
+	}
+	if now.Sub(time.Unix(rec.Touched, 0)) > m.cfg.IdleTimeout ||
+		now.Sub(time.Unix(rec.Created, 0)) > m.cfg.AbsoluteTimeout {
+		if id != "" {
+			fresh.staleIDs = []string{id}
+		}
+		return fresh, nil
+	}
+	return &Session{id: id, rec: rec, hadCookie: true}, nil
+}
+
+// commit saves the session, or clears its cookie, once per request. w is
+// the writer the cookie header goes to.
+func (m *manager[Bindings]) commit(s *Session, w http.ResponseWriter) {
+	if s.committed {
+		return
+	}
+	s.committed = true
+	s.commitError = m.save(s, w)
+}
+
+func (m *manager[Bindings]) save(s *Session, w http.ResponseWriter) error {
+	ctx := m.c.Req().Raw().Context()
+	for _, id := range s.staleIDs {
+		if err := m.cfg.Store.Delete(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	touch := !s.isNew && !s.destroyed && now.Sub(time.Unix(s.rec.Touched, 0)) >= touchInterval
+	if !s.dirty && !touch {
+		if s.hadCookie && (s.isNew || s.destroyed) {
+			m.writeCookie(w, "", -1)
+		}
+		return nil
+	}
+
+	ttl := min(m.cfg.IdleTimeout, time.Unix(s.rec.Created, 0).Add(m.cfg.AbsoluteTimeout).Sub(now))
+	if ttl < time.Second {
+		m.writeCookie(w, "", -1)
+		return nil
+	}
+	if s.id == "" {
+		s.id = newID()
+	}
+	s.rec.Touched = now.Unix()
+	if m.inCookie {
+		s.rec.ID = s.id
+	}
+	data, err := json.Marshal(s.rec)
+	if err != nil {
+		return err
+	}
+
+	value := s.id
+	if m.inCookie {
+		value = string(data)
+	} else if err := m.cfg.Store.Set(ctx, s.id, data, ttl); err != nil {
+		return err
+	}
+	if !m.writeCookie(w, value, int(ttl/time.Second)) {
+		return errors.New("session: cookie could not be encoded")
+	}
+	return nil
+}
+
+func (m *manager[Bindings]) readCookie() (string, bool) {
+	var ck *http.Cookie
+	var ok bool
+	if m.cfg.EncryptionKey == "" {
+		ck, ok = cookie.GetSignedCookie(m.c, m.cfg.CookieName, m.secret, m.cfg.CookieOptions)
+	} else {
+		ck, ok = cookie.GetEncryptedCookie(m.c, m.cfg.CookieName, m.secret, m.cfg.EncryptionKey, m.cfg.CookieOptions)
+	}
+	if !ok {
+		return "", false
+	}
+	return ck.Value, true
+}
+
+// writeCookie sets the session cookie on w; maxAge < 0 deletes it.
+func (m *manager[Bindings]) writeCookie(w http.ResponseWriter, value string, maxAge int) bool {
+	opts := *m.cfg.CookieOptions
+	opts.MaxAge = maxAge
+
+	// the cookie helpers write to c.Response(), which may be a wrapper
+	// installed further down the chain; point it at w meanwhile
+	prev := m.c.Response()
+	m.c.SetResponse(w)
+	defer m.c.SetResponse(prev)
+
+	if maxAge < 0 {
+		cookie.DeleteCookie(m.c, m.cfg.CookieName, &opts)
+		return true
+	}
+	if m.cfg.EncryptionKey == "" {
+		return cookie.SetSignedCookie(m.c, m.cfg.CookieName, value, m.secret, &opts)
+	}
+	return cookie.SetEncryptedCookie(m.c, m.cfg.CookieName, value, m.secret, m.cfg.EncryptionKey, &opts)
+}
+
+// sessionWriter saves the session just before the response headers are
+// sent, while Set-Cookie can still be added.
+type sessionWriter struct {
+	http.ResponseWriter
+	commit func()
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	w.commit()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush forwards to the wrapped writer so ctx.Stream keeps streaming.
+func (w *sessionWriter) Flush() {
+	w.commit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,192 @@
+// Package session provides cookie-identified user sessions for takibi apps:
+// typed values, flash messages, ID regeneration and idle/absolute expiry,
+// kept in a signed or encrypted cookie or in a server-side Store.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/poteto0/takibi/constants"
+)
+
+// record is the persisted form of a session.
+type record struct {
+	// ID is only set for sessions kept in the cookie itself.
+	ID      string                       `json:"i,omitempty"`
+	Values  map[string]json.RawMessage   `json:"v,omitempty"`
+	Flashes map[string][]json.RawMessage `json:"f,omitempty"`
+	Created int64                        `json:"c"`
+	Touched int64                        `json:"t"`
+}
+
+// Session is the session of the current request, retrieved with From. It is
+// saved when the response is written, and only when it holds data or was
+// loaded from an existing session.
+type Session struct {
+	id          string
+	rec         record
+	isNew       bool
+	dirty       bool
+	destroyed   bool
+	staleIDs    []string
+	hadCookie   bool
+	committed   bool
+	commitError error
+}
+
+func newSession(now time.Time) *Session {
+	return &Session{
+		isNew: true,
+		rec:   record{Created: now.Unix(), Touched: now.Unix()},
+	}
+}
+
+// From returns the request's session, or nil when Middleware is not
+// installed on the route.
+func From(c interface {
+	Get(string) (any, bool)
+}) *Session {
+	if v, ok := c.Get(constants.ContextKeySession); ok {
+		if s, ok := v.(*Session); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// Get decodes the value stored under key into a T. It reports false when
+// there is no session, no such key, or the value does not decode into T.
+//
+//	user, ok := session.Get[User](c, "user")
+func Get[T any](c interface {
+	Get(string) (any, bool)
+}, key string) (T, bool) {
+	var v T
+	s := From(c)
+	if s == nil {
+		return v, false
+	}
+	raw, ok := s.rec.Values[key]
+	if !ok || json.Unmarshal(raw, &v) != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// Flashes returns and removes the flash messages added under key, typically
+// by the request before a redirect. Messages that do not decode into T are
+// dropped.
+//
+//	for _, msg := range session.Flashes[string](c, "notice") { ... }
+func Flashes[T any](c interface {
+	Get(string) (any, bool)
+}, key string) []T {
+	s := From(c)
+	if s == nil {
+		return nil
+	}
+	raws, ok := s.rec.Flashes[key]
+	if !ok {
+		return nil
+	}
+	delete(s.rec.Flashes, key)
+	s.dirty = true
+
+	out := make([]T, 0, len(raws))
+	for _, raw := range raws {
+		var v T
+		if json.Unmarshal(raw, &v) == nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ID returns the session ID, or "" for a new session not saved yet.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew reports whether the session was created by this request.
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// Set stores v, which must be JSON-serializable, under key.
+func (s *Session) Set(key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.rec.Values == nil {
+		s.rec.Values = map[string]json.RawMessage{}
+	}
+	s.rec.Values[key] = raw
+	s.dirty = true
+	return nil
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	if _, ok := s.rec.Values[key]; ok {
+		delete(s.rec.Values, key)
+		s.dirty = true
+	}
+}
+
+// Clear removes every value and flash message.
+func (s *Session) Clear() {
+	s.rec.Values = nil
+	s.rec.Flashes = nil
+	s.dirty = true
+}
+
+// AddFlash queues v, which must be JSON-serializable, under key until it is
+// read with Flashes.
+func (s *Session) AddFlash(key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.rec.Flashes == nil {
+		s.rec.Flashes = map[string][]json.RawMessage{}
+	}
+	s.rec.Flashes[key] = append(s.rec.Flashes[key], raw)
+	s.dirty = true
+	return nil
+}
+
+// Regenerate moves the session to a new ID, returned by ID from now on, and
+// restarts its absolute lifetime, keeping its data. Call it when the user
+// logs in (or changes privileges) so an ID planted before login is worthless
+// afterwards.
+func (s *Session) Regenerate() {
+	if s.id != "" {
+		s.staleIDs = append(s.staleIDs, s.id)
+	}
+	s.id = newID()
+	s.rec.Created = time.Now().Unix()
+	s.dirty = true
+}
+
+// Destroy deletes the session and its cookie, e.g. on logout. A value set
+// afterwards starts a new session.
+func (s *Session) Destroy() {
+	if s.id != "" {
+		s.staleIDs = append(s.staleIDs, s.id)
+	}
+	s.id = ""
+	s.rec = record{Created: time.Now().Unix()}
+	s.destroyed = true
+	s.dirty = false
+}
+
+// newID returns 256 random bits, base64url-encoded.
+func newID() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,232 @@
+package session_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/session"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "session-secret-must-be-32-bytes!!"
+
+type user struct {
+	Name string `json:"name"`
+}
+
+func newApp(config session.Config[any]) interfaces.ITakibi[any] {
+	app := takibi.New[any](nil)
+	app.Use("*", session.Middleware(config))
+	app.Post("/login", func(c interfaces.IContext[any]) error {
+		s := session.From(c)
+		s.Regenerate()
+		if err := s.Set("user", user{Name: "alice"}); err != nil {
+			return err
+		}
+		if err := s.AddFlash("notice", "welcome"); err != nil {
+			return err
+		}
+		return c.Text(s.ID())
+	})
+	app.Get("/me", func(c interfaces.IContext[any]) error {
+		u, ok := session.Get[user](c, "user")
+		if !ok {
+			return c.Text("anonymous")
+		}
+		return c.Text(u.Name)
+	})
+	app.Get("/flash", func(c interfaces.IContext[any]) error {
+		return c.Text(strings.Join(session.Flashes[string](c, "notice"), ","))
+	})
+	app.Post("/logout", func(c interfaces.IContext[any]) error {
+		session.From(c).Destroy()
+		return c.Text("bye")
+	})
+	return app
+}
+
+// sessionCookie returns the Cookie header echoing the response's session
+// cookie, or "" when none was set.
+func sessionCookie(res interfaces.ICampResponse) string {
+	for _, ck := range res.Raw().Cookies() {
+		if ck.Name == "session" && ck.MaxAge > 0 {
+			return ck.Name + "=" + ck.Value
+		}
+	}
+	return ""
+}
+
+func text(res interfaces.ICampResponse) string {
+	b, _ := io.ReadAll(res.Raw().Body)
+	return string(b)
+}
+
+func TestSession_CookieStore(t *testing.T) {
+	tests := map[string]session.Config[any]{
+		"signed":    {Secret: testSecret},
+		"encrypted": {Secret: testSecret, EncryptionKey: "0123456789abcdef0123456789abcdef"},
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := newApp(config)
+
+			login := app.Camp(http.MethodPost, "/login")
+			ck := sessionCookie(login)
+			assert.NotEmpty(t, ck)
+
+			me := app.Camp(http.MethodGet, "/me", interfaces.Header("Cookie", ck))
+			assert.Equal(t, "alice", text(me))
+
+			flash := app.Camp(http.MethodGet, "/flash", interfaces.Header("Cookie", ck))
+			assert.Equal(t, "welcome", text(flash))
+
+			// the flash was consumed
+			ck = sessionCookie(flash)
+			flash = app.Camp(http.MethodGet, "/flash", interfaces.Header("Cookie", ck))
+			assert.Equal(t, "", text(flash))
+
+			tampered := app.Camp(http.MethodGet, "/me", interfaces.Header("Cookie", ck+"x"))
+			assert.Equal(t, "anonymous", text(tampered))
+		})
+	}
+
+	t.Run("encrypted cookie hides the session data", func(t *testing.T) {
+		app := newApp(session.Config[any]{Secret: testSecret, EncryptionKey: "0123456789abcdef"})
+		ck := sessionCookie(app.Camp(http.MethodPost, "/login"))
+
+		signedOnly := newApp(session.Config[any]{Secret: testSecret})
+		res := signedOnly.Camp(http.MethodGet, "/me", interfaces.Header("Cookie", ck))
+		assert.Equal(t, "anonymous", text(res))
+	})
+}
+
+func TestSession_MemoryStore(t *testing.T) {
+	store := session.NewMemoryStore()
+	app := newApp(session.Config[any]{Store: store, Secret: testSecret})
+
+	t.Run("new session without data is not saved", func(t *testing.T) {
+		res := app.Camp(http.MethodGet, "/me")
+
+		assert.Equal(t, "anonymous", text(res))
+		assert.Empty(t, res.Raw().Cookies())
+		assert.Equal(t, 0, store.Len())
+	})
+
+	t.Run("regenerate replaces the stored session", func(t *testing.T) {
+		first := app.Camp(http.MethodPost, "/login")
+		ck := sessionCookie(first)
+		assert.Equal(t, 1, store.Len())
+
+		second := app.Camp(http.MethodPost, "/login", interfaces.Header("Cookie", ck))
+		firstID := text(first)
+		assert.NotEqual(t, firstID, text(second))
+		assert.Equal(t, 1, store.Len())
+
+		_, ok, _ := store.Get(context.Background(), firstID)
+		assert.False(t, ok)
+
+		// the old cookie no longer resolves
+		res := app.Camp(http.MethodGet, "/me", interfaces.Header("Cookie", ck))
+		assert.Equal(t, "anonymous", text(res))
+
+		res = app.Camp(http.MethodGet, "/me", interfaces.Header("Cookie", sessionCookie(second)))
+		assert.Equal(t, "alice", text(res))
+	})
+
+	t.Run("destroy deletes session and cookie", func(t *testing.T) {
+		login := app.Camp(http.MethodPost, "/login")
+		ck := sessionCookie(login)
+
+		logout := app.Camp(http.MethodPost, "/logout", interfaces.Header("Cookie", ck))
+		cookies := logout.Raw().Cookies()
+		assert.Len(t, cookies, 1)
+		assert.Equal(t, -1, cookies[0].MaxAge)
+
+		_, ok, _ := store.Get(context.Background(), text(login))
+		assert.False(t, ok)
+		res := app.Camp(http.MethodGet, "/me", interfaces.Header("Cookie", ck))
+		assert.Equal(t, "anonymous", text(res))
+	})
+}
+
+func TestSession_Expiry(t *testing.T) {
+	store := session.NewMemoryStore()
+	app := newApp(session.Config[any]{
+		Store:           store,
+		Secret:          testSecret,
+		IdleTimeout:     time.Hour,
+		AbsoluteTimeout: 4 * time.Hour,
+	})
+
+	// age rewrites the stored session as if it was created and last used
+	// the given durations ago.
+	age := func(t *testing.T, id string, created, touched time.Duration) {
+		data, ok, _ := store.Get(context.Background(), id)
+		assert.True(t, ok)
+		var rec map[string]any
+		assert.NoError(t, json.Unmarshal(data, &rec))
+		rec["c"] = time.Now().Add(-created).Unix()
+		rec["t"] = time.Now().Add(-touched).Unix()
+		data, _ = json.Marshal(rec)
+		assert.NoError(t, store.Set(context.Background(), id, data, time.Hour))
+	}
+
+	tests := []struct {
+		name             string
+		created, touched time.Duration
+		valid            bool
+	}{
+		{"active", time.Hour, time.Minute, true},
+		{"idle", 2 * time.Hour, 2 * time.Hour, false},
+		{"absolute", 5 * time.Hour, time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			login := app.Camp(http.MethodPost, "/login")
+			ck := sessionCookie(login)
+			id := text(login)
+			age(t, id, tt.created, tt.touched)
+
+			res := app.Camp(http.MethodGet, "/me", interfaces.Header("Cookie", ck))
+
+			if tt.valid {
+				assert.Equal(t, "alice", text(res))
+				// touched over a minute ago: saved again to extend it
+				assert.NotEmpty(t, sessionCookie(res))
+			} else {
+				assert.Equal(t, "anonymous", text(res))
+				_, ok, _ := store.Get(context.Background(), id)
+				assert.False(t, ok)
+				cookies := res.Raw().Cookies()
+				assert.Len(t, cookies, 1)
+				assert.Equal(t, -1, cookies[0].MaxAge)
+			}
+		})
+	}
+}
+
+func TestSession_Get(t *testing.T) {
+	app := takibi.New[any](nil)
+	app.Get("/", func(c interfaces.IContext[any]) error {
+		_, ok := session.Get[string](c, "user")
+		assert.False(t, ok)
+		assert.Nil(t, session.From(c))
+		return c.Text("ok")
+	})
+	app.Camp(http.MethodGet, "/")
+}
+
+func TestMiddleware_Panics(t *testing.T) {
+	assert.Panics(t, func() { session.Middleware(session.Config[any]{}) })
+	assert.Panics(t, func() { session.Middleware(session.Config[any]{Secret: "short"}) })
+	assert.Panics(t, func() {
+		session.Middleware(session.Config[any]{Secret: testSecret, EncryptionKey: "short"})
+	})
+}
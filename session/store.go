@@ -0,0 +1,108 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps encoded sessions server-side, keyed by session ID; the cookie
+// then carries only the ID. Stores move opaque bytes and expire them after
+// ttl, so any key-value backend with expiry (Workers KV, Redis) adapts
+// directly.
+type Store interface {
+	// Get returns the data stored under id; ok is false when there is none
+	// or it expired.
+	Get(ctx context.Context, id string) (data []byte, ok bool, err error)
+	// Set stores data under id for ttl, replacing any previous data.
+	Set(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	// Delete removes id; deleting a missing id is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// cookieStore is implemented by stores keeping the session in the cookie.
+type cookieStore interface {
+	inCookie()
+}
+
+type cookieOnlyStore struct{}
+
+func (cookieOnlyStore) inCookie() {}
+
+func (cookieOnlyStore) Get(context.Context, string) ([]byte, bool, error) { return nil, false, nil }
+
+func (cookieOnlyStore) Set(context.Context, string, []byte, time.Duration) error { return nil }
+
+func (cookieOnlyStore) Delete(context.Context, string) error { return nil }
+
+// CookieStore keeps the whole session in the cookie, signed with
+// Config.Secret and, with Config.EncryptionKey, encrypted. It needs no
+// server-side state, so it works unchanged on Workers, but the encoded
+// session must fit in constants.MaxCookieChunks cookie chunks, and a
+// destroyed session's cookie stays valid until it expires if the client
+// kept a copy.
+func CookieStore() Store {
+	return cookieOnlyStore{}
+}
+
+// MemoryStore is a Store in process memory, for single-instance servers and
+// tests. On Workers every isolate has its own copy; use a KV-backed Store
+// there.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	nextSweep time.Time
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]memoryEntry{}}
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false, nil
+	}
+	return e.data, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(time.Minute)
+	}
+	s.entries[id] = memoryEntry{data: data, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, id)
+	return nil
+}
+
+// Len returns the number of stored sessions, expired ones included until
+// they are swept.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
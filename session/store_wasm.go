@@ -0,0 +1,51 @@
+//go:build wasm
+
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/syumai/workers/cloudflare/kv"
+)
+
+// kvMinTTL is the shortest expiration Workers KV accepts.
+const kvMinTTL = 60 * time.Second
+
+// WorkersKVStore is a Store on a Workers KV namespace, shared by every
+// isolate. KV is eventually consistent: a session written in one location
+// can take up to a minute to be visible elsewhere.
+//
+//	ns, _ := kv.NewNamespace("SESSIONS")
+//	app.Use("*", session.Middleware(session.Config[Bindings]{
+//		Store:  &session.WorkersKVStore{Namespace: ns},
+//		Secret: secret,
+//	}))
+type WorkersKVStore struct {
+	Namespace *kv.Namespace
+	// Prefix is prepended to session IDs to form the KV keys.
+	Prefix string
+}
+
+func (s *WorkersKVStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	v, err := s.Namespace.GetString(s.Prefix+id, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	// a missing key comes back as JS null
+	if v == "<null>" || v == "" {
+		return nil, false, nil
+	}
+	return []byte(v), true, nil
+}
+
+func (s *WorkersKVStore) Set(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	ttl = max(ttl, kvMinTTL)
+	return s.Namespace.PutString(s.Prefix+id, string(data), &kv.PutOptions{
+		ExpirationTTL: int(ttl / time.Second),
+	})
+}
+
+func (s *WorkersKVStore) Delete(_ context.Context, id string) error {
+	return s.Namespace.Delete(s.Prefix + id)
+}
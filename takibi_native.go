@@ -22,6 +22,8 @@ type takibi[Bindings any] struct {
 	env              *Bindings
 	cache            sync.Pool
 	router           interfaces.IRouter[Bindings]
+	pre              []interfaces.MiddlewareFunc[Bindings]
+	entry            interfaces.HandlerFunc[Bindings]
 	errorHandler     interfaces.ErrorHandlerFunc[Bindings]
 	blowErrorHandler interfaces.BlowErrorHandlerFunc[Bindings]
 	tasks            []interfaces.BlowTask[Bindings]
@@ -40,6 +42,7 @@ func New[Bindings any](bindings *Bindings) interfaces.ITakibi[Bindings] {
 }
 
 func NewWithOption[Bindings any](bindings *Bindings, opt interfaces.TakibiOption) interfaces.ITakibi[Bindings] {
+	mustValidOption(opt)
 	if bindings == nil {
 		bindings = new(Bindings)
 	}
@@ -50,7 +53,8 @@ func NewWithOption[Bindings any](bindings *Bindings, opt interfaces.TakibiOption
 		env:    bindings,
 		router: router.New[Bindings](),
 		errorHandler: func(ctx interfaces.IContext[Bindings], err error) error {
-			return ctx.Status(http.StatusInternalServerError).Text("Internal Server Error")
+			code := interfaces.StatusCodeOf(err)
+			return ctx.Status(code).Text(http.StatusText(code))
 		},
 		blowErrorHandler: func(c interfaces.IContext[Bindings], err error) {
 			fmt.Println(err.Error())
@@ -200,33 +204,10 @@ func (
 ) {
 	// get from cache & reset context
 	ctx := t.initializeContext(w, r)
-	defer t.cache.Put(ctx)
-
-	n, middlewares, params := t.router.Find(r.Method, r.URL.Path)
-	if len(params) > 0 {
-		ctx.SetParam(params)
-	}
-
-	var handler interfaces.HandlerFunc[Bindings]
-	if n != nil {
-		handler = n.ComposedHandler()
-	}
-
-	if handler == nil {
-		notFound := func(c interfaces.IContext[Bindings]) error {
-			c.Response().WriteHeader(http.StatusNotFound)
-			return nil
-		}
-		handler = router.Compose(notFound, middlewares)
-	}
-
-	if err := handler(ctx); err != nil {
-		if err := t.errorHandler(ctx, err); err != nil {
-			// fallback
-			ctx.Response().WriteHeader(http.StatusInternalServerError)
-		}
-		return
-	}
+	t.dispatch(ctx)
+	// only reached when dispatch returns normally: a context abandoned by a
+	// panic is never handed to another request
+	t.cache.Put(ctx)
 }
 
 func (
@@ -55,6 +55,24 @@ func TestNewTakibi(t *testing.T) {
 		app := New[Bindings](nil)
 		assert.Equal(t, app.Env().Foo, "")
 	})
+
+	t.Run("invalid trusted proxy panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewWithOption[Bindings](nil, interfaces.TakibiOption{TrustedProxies: []string{"proxy.internal"}})
+		})
+	})
+
+	t.Run("pooled contexts keep trusted proxies", func(t *testing.T) {
+		app := NewWithOption[Bindings](nil, interfaces.TakibiOption{TrustedProxies: []string{"10.0.0.0/8"}}).(*takibi[Bindings])
+		for range 2 {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.2:4000"
+			req.Header.Set("X-Forwarded-For", "198.51.100.1")
+			ctx := app.initializeContext(httptest.NewRecorder(), req)
+			assert.Equal(t, "198.51.100.1", ctx.Req().RealIP())
+			app.cache.Put(ctx)
+		}
+	})
 }
 
 func TestTakibi_FireAndFinish(t *testing.T) {
@@ -536,6 +554,28 @@ func TestTakibi_ServeHTTP(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.Equal(t, "user 123", rec.Body.String())
 	})
+
+	t.Run("context abandoned by a panic is not pooled", func(t *testing.T) {
+		app := newNilApp()
+		var seen []interfaces.IContext[any]
+		app.Get("/panic", func(ctx interfaces.IContext[any]) error {
+			seen = append(seen, ctx)
+			panic("boom")
+		})
+		app.Get("/ok", func(ctx interfaces.IContext[any]) error {
+			seen = append(seen, ctx)
+			return nil
+		})
+
+		assert.Panics(t, func() {
+			app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+		})
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+		if assert.Len(t, seen, 2) {
+			assert.NotSame(t, seen[0], seen[1])
+		}
+	})
 }
 
 func TestTakibi_NoHandler(t *testing.T) {
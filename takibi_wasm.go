@@ -19,6 +19,8 @@ type takibi[Bindings any] struct {
 	env              *Bindings
 	cache            sync.Pool
 	router           interfaces.IRouter[Bindings]
+	pre              []interfaces.MiddlewareFunc[Bindings]
+	entry            interfaces.HandlerFunc[Bindings]
 	errorHandler     interfaces.ErrorHandlerFunc[Bindings]
 	blowErrorHandler interfaces.BlowErrorHandlerFunc[Bindings]
 	tasks            []interfaces.BlowTask[Bindings]
@@ -33,6 +35,7 @@ func New[Bindings any](bindings *Bindings) interfaces.ITakibi[Bindings] {
 }
 
 func NewWithOption[Bindings any](bindings *Bindings, opt interfaces.TakibiOption) interfaces.ITakibi[Bindings] {
+	mustValidOption(opt)
 	if bindings == nil {
 		bindings = new(Bindings)
 	}
@@ -43,7 +46,7 @@ func NewWithOption[Bindings any](bindings *Bindings, opt interfaces.TakibiOption
 		env:    bindings,
 		router: router.New[Bindings](),
 		errorHandler: func(ctx interfaces.IContext[Bindings], err error) error {
-			return ctx.Status(http.StatusInternalServerError).Text(err.Error())
+			return ctx.Status(interfaces.StatusCodeOf(err)).Text(err.Error())
 		},
 		blowErrorHandler: func(c interfaces.IContext[Bindings], err error) {
 			fmt.Println(err.Error())
@@ -118,33 +121,10 @@ func (
 ) {
 	// get from cache & reset context
 	ctx := t.initializeContext(w, r)
-	defer t.cache.Put(ctx)
-
-	n, middlewares, params := t.router.Find(r.Method, r.URL.Path)
-	if len(params) > 0 {
-		ctx.SetParam(params)
-	}
-
-	var handler interfaces.HandlerFunc[Bindings]
-	if n != nil {
-		handler = n.ComposedHandler()
-	}
-
-	if handler == nil {
-		notFound := func(c interfaces.IContext[Bindings]) error {
-			c.Response().WriteHeader(http.StatusNotFound)
-			return nil
-		}
-		handler = router.Compose(notFound, middlewares)
-	}
-
-	if err := handler(ctx); err != nil {
-		if err := t.errorHandler(ctx, err); err != nil {
-			// fallback
-			ctx.Response().WriteHeader(http.StatusInternalServerError)
-		}
-		return
-	}
+	t.dispatch(ctx)
+	// only reached when dispatch returns normally: a context abandoned by a
+	// panic is never handed to another request
+	t.cache.Put(ctx)
 }
 
 func (
@@ -0,0 +1,574 @@
+package thttp
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binding sources read by BindAll and reported by BindError.Source. Each
+// source except json is also the struct tag that selects it.
+const (
+	BindSourceParam  = "param"
+	BindSourceQuery  = "query"
+	BindSourceHeader = "header"
+	BindSourceCookie = "cookie"
+	BindSourceForm   = "form"
+	BindSourceJson   = "json"
+)
+
+// BindError reports a value that could not be bound into dest. Field is the
+// struct field name (empty when the body itself could not be decoded) and
+// Err is the underlying conversion or decode error.
+type BindError struct {
+	Source string
+	Field  string
+	Err    error
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("bind %s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("bind %s: field %s: %v", e.Source, e.Field, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// BindAll fills the struct pointed to by dest from every part of the request
+// in one pass. The body is decoded first (JSON via `json` tags, urlencoded and
+// multipart via `form` tags), then fields tagged `param`, `query`, `header`
+// or `cookie` are set from the path parameters, query string, request headers
+// and cookies. Requests without a body, or with a non JSON/form content type,
+// skip the body step. Missing or empty values leave the field untouched.
+//
+// Fields tagged for one of those sources, at any depth, are never set from
+// the body, even though form keys and JSON names otherwise match untagged
+// field names, so a client cannot pass a header or path parameter through the
+// body instead.
+func (r *Request) BindAll(dest any) error {
+	elem, err := structElem(dest)
+	if err != nil {
+		return err
+	}
+	restore := holdSourceFields(elem)
+	err = r.bindBody(dest)
+	restore()
+	if err != nil {
+		return err
+	}
+
+	for _, source := range []string{BindSourceParam, BindSourceQuery, BindSourceHeader, BindSourceCookie} {
+		b, _ := r.sourceBinder(source)
+		if _, err := b.bind(elem, "", ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// holdSourceFields keeps the body step of BindAll away from fields tagged
+// for a non-body source, at any depth. It zeroes them, remembering their
+// values, and returns a function that clears whatever the body put in any
+// such field (including in structs the body allocated) and puts the
+// remembered values back.
+func holdSourceFields(elem reflect.Value) (restore func()) {
+	type held struct {
+		path  []int
+		value reflect.Value
+	}
+	var fields []held
+	seen := map[uintptr]bool{}
+	var collect func(v reflect.Value, path []int)
+	collect = func(v reflect.Value, path []int) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := v.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+			fieldPath := append(slices.Clip(path), i)
+			if hasSourceTag(t.Field(i)) {
+				saved := reflect.New(field.Type()).Elem()
+				saved.Set(field)
+				field.SetZero()
+				fields = append(fields, held{path: fieldPath, value: saved})
+				continue
+			}
+			if field.Kind() == reflect.Pointer && !field.IsNil() {
+				if seen[field.Pointer()] {
+					continue
+				}
+				seen[field.Pointer()] = true
+				field = field.Elem()
+			}
+			if field.Kind() == reflect.Struct {
+				collect(field, fieldPath)
+			}
+		}
+	}
+	collect(elem, nil)
+
+	return func() {
+		clearSourceFields(elem, map[uintptr]bool{})
+		for _, h := range fields {
+			if field, ok := fieldAt(elem, h.path); ok {
+				field.Set(h.value)
+			}
+		}
+	}
+}
+
+// clearSourceFields zeroes every field tagged for a non-body source reachable
+// from v through structs, pointers, slices and arrays.
+func clearSourceFields(v reflect.Value, seen map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		clearSourceFields(v.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Pointer, reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				clearSourceFields(v.Index(i), seen)
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := v.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+			if hasSourceTag(t.Field(i)) {
+				field.SetZero()
+				continue
+			}
+			clearSourceFields(field, seen)
+		}
+	}
+}
+
+// fieldAt follows path, a list of field indexes, from v through any
+// pointers, reporting false when it crosses a nil one.
+func fieldAt(v reflect.Value, path []int) (reflect.Value, bool) {
+	for _, i := range path {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+func hasSourceTag(sf reflect.StructField) bool {
+	for _, source := range []string{BindSourceParam, BindSourceQuery, BindSourceHeader, BindSourceCookie} {
+		if key := sf.Tag.Get(source); key != "" && key != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// BindFrom fills the fields of dest tagged for a single non-body source —
+// BindSourceParam, BindSourceQuery, BindSourceHeader or BindSourceCookie —
+// leaving every other field untouched.
+func (r *Request) BindFrom(source string, dest any) error {
+	elem, err := structElem(dest)
+	if err != nil {
+		return err
+	}
+	b, ok := r.sourceBinder(source)
+	if !ok {
+		return fmt.Errorf("unsupported bind source: %s", source)
+	}
+	_, err = b.bind(elem, "", "")
+	return err
+}
+
+// sourceBinder returns the binder for a non-body source, reporting false
+// when the source is unknown. Only fields tagged for the source are bound.
+func (r *Request) sourceBinder(source string) (binder, bool) {
+	b := binder{tag: source}
+	switch source {
+	case BindSourceParam:
+		b.lookup = func(key string) []string {
+			if v, ok := r.params[key]; ok {
+				return []string{v}
+			}
+			return nil
+		}
+		keys := make([]string, 0, len(r.params))
+		for key := range r.params {
+			keys = append(keys, key)
+		}
+		b.nested = nestedPrefixes(keys, false)
+	case BindSourceQuery:
+		query := r.request.URL.Query()
+		b.lookup = func(key string) []string {
+			return query[key]
+		}
+		b.nested = nestedPrefixes(slices.Collect(maps.Keys(query)), false)
+	case BindSourceHeader:
+		b.lookup = func(key string) []string {
+			return r.request.Header.Values(key)
+		}
+		b.nested = nestedPrefixes(slices.Collect(maps.Keys(r.request.Header)), true)
+	case BindSourceCookie:
+		b.lookup = func(key string) []string {
+			c, err := r.request.Cookie(key)
+			if err != nil {
+				return nil
+			}
+			return []string{c.Value}
+		}
+		var keys []string
+		for _, c := range r.request.Cookies() {
+			keys = append(keys, c.Name)
+		}
+		b.nested = nestedPrefixes(keys, false)
+	default:
+		return binder{}, false
+	}
+	return b, true
+}
+
+// bindBody decodes the request body into dest according to its media type.
+func (r *Request) bindBody(dest any) error {
+	if r.request.Body == nil || r.request.Body == http.NoBody {
+		return nil
+	}
+	switch r.MediaType() {
+	case "application/json":
+		if err := r.Unmarshall(dest); err != nil {
+			bindErr := &BindError{Source: BindSourceJson, Err: err}
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				bindErr.Field = typeErr.Field
+			}
+			return bindErr
+		}
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		if err := r.parseForm(); err != nil {
+			return &BindError{Source: BindSourceForm, Err: err}
+		}
+		return r.bindForm(dest)
+	}
+	return nil
+}
+
+// bindForm maps the parsed form onto the exported fields of the struct
+// pointed to by dest. Field keys come from the `form` tag (falling back to the
+// field name); a tag of "-" skips the field. Nested struct fields are keyed as
+// "parent.child" or "parent[child]", repeated keys fill slices, and
+// *multipart.FileHeader / []*multipart.FileHeader fields receive the uploaded
+// file parts of a multipart body.
+func (r *Request) bindForm(dest any) error {
+	elem, err := structElem(dest)
+	if err != nil {
+		return err
+	}
+	values := normalizeFormKeys(r.request.Form)
+	b := binder{
+		tag:    BindSourceForm,
+		byName: true,
+		lookup: func(key string) []string {
+			return values[key]
+		},
+	}
+	keys := slices.Collect(maps.Keys(values))
+	if mf := r.request.MultipartForm; mf != nil {
+		files := make(map[string][]*multipart.FileHeader, len(mf.File))
+		for key, headers := range mf.File {
+			norm := normalizeFormKey(key)
+			files[norm] = append(files[norm], headers...)
+			keys = append(keys, norm)
+		}
+		b.files = func(key string) []*multipart.FileHeader {
+			return files[key]
+		}
+	}
+	b.nested = nestedPrefixes(keys, false)
+	_, err = b.bind(elem, "", "")
+	return err
+}
+
+// normalizeFormKeys rewrites bracket keys to dot notation so "a[b]" and
+// "a.b" address the same nested field. A trailing "[]" (as sent for
+// repeated inputs such as "tags[]") is dropped.
+func normalizeFormKeys(values url.Values) url.Values {
+	out := make(url.Values, len(values))
+	for key, vs := range values {
+		norm := normalizeFormKey(key)
+		out[norm] = append(out[norm], vs...)
+	}
+	return out
+}
+
+func normalizeFormKey(key string) string {
+	if !strings.Contains(key, "[") {
+		return key
+	}
+	key = strings.TrimSuffix(key, "[]")
+	key = strings.ReplaceAll(key, "][", ".")
+	key = strings.ReplaceAll(key, "[", ".")
+	return strings.TrimSuffix(key, "]")
+}
+
+// structElem returns the struct value dest points to.
+func structElem(dest any) (reflect.Value, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return reflect.Value{}, fmt.Errorf("dest must be a non-nil pointer to a struct")
+	}
+	elem := rv.Elem()
+	if elem.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("dest must be a pointer to a struct")
+	}
+	return elem, nil
+}
+
+// nestedPrefixes returns a check reporting whether any of keys lies under
+// the dotted prefix ("a." for "a.b"). fold matches case-insensitively, as
+// header names do.
+func nestedPrefixes(keys []string, fold bool) func(prefix string) bool {
+	set := map[string]struct{}{}
+	for _, key := range keys {
+		if fold {
+			key = strings.ToLower(key)
+		}
+		for i := 0; i < len(key); i++ {
+			if key[i] == '.' {
+				set[key[:i+1]] = struct{}{}
+			}
+		}
+	}
+	return func(prefix string) bool {
+		if fold {
+			prefix = strings.ToLower(prefix)
+		}
+		_, ok := set[prefix]
+		return ok
+	}
+}
+
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	fileHeaderType      = reflect.TypeFor[*multipart.FileHeader]()
+	fileHeaderSliceType = reflect.TypeFor[[]*multipart.FileHeader]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// binder walks a struct for one binding source. Fields carrying the tag are
+// keyed by it; when byName is set, untagged fields fall back to their field
+// name. A tag of "-" always skips the field.
+type binder struct {
+	tag    string
+	byName bool
+	lookup func(key string) []string
+	// nested reports whether any key lies under a dotted prefix, so nested
+	// structs are only entered when the source has keys for them. This
+	// bounds the walk of self-referential types.
+	nested func(prefix string) bool
+	// files resolves multipart file parts; nil outside multipart bodies.
+	files func(key string) []*multipart.FileHeader
+}
+
+// bind sets the fields of elem whose keys (prefixed by keyPrefix) are present
+// and reports whether any field was set. fieldPrefix is the dotted Go field
+// path used in BindError.Field.
+func (b binder) bind(elem reflect.Value, keyPrefix, fieldPrefix string) (bool, error) {
+	bound := false
+	elemType := elem.Type()
+	for i := 0; i < elemType.NumField(); i++ {
+		field := elem.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		sf := elemType.Field(i)
+		key := sf.Tag.Get(b.tag)
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			if !b.byName {
+				continue
+			}
+			key = sf.Name
+		}
+		key = keyPrefix + key
+		name := fieldPrefix + sf.Name
+
+		if sf.Type == fileHeaderType || sf.Type == fileHeaderSliceType {
+			if b.files == nil {
+				continue
+			}
+			headers := b.files(key)
+			if len(headers) == 0 {
+				continue
+			}
+			if sf.Type == fileHeaderType {
+				field.Set(reflect.ValueOf(headers[0]))
+			} else {
+				field.Set(reflect.ValueOf(headers))
+			}
+			bound = true
+			continue
+		}
+
+		if isNestedStruct(sf.Type) {
+			ok, err := b.bindNested(field, key+".", name+".")
+			if err != nil {
+				return bound, err
+			}
+			bound = bound || ok
+			continue
+		}
+
+		values := nonEmpty(b.lookup(key))
+		if len(values) == 0 {
+			continue
+		}
+		if err := setField(field, values, sf.Tag.Get("layout")); err != nil {
+			return bound, &BindError{Source: b.tag, Field: name, Err: err}
+		}
+		bound = true
+	}
+	return bound, nil
+}
+
+// bindNested binds a struct or pointer-to-struct field. A nil pointer is only
+// allocated when at least one of its fields is present.
+func (b binder) bindNested(field reflect.Value, keyPrefix, fieldPrefix string) (bool, error) {
+	if !b.nested(keyPrefix) {
+		return false, nil
+	}
+	if field.Kind() != reflect.Pointer {
+		return b.bind(field, keyPrefix, fieldPrefix)
+	}
+	target := reflect.New(field.Type().Elem())
+	if !field.IsNil() {
+		target.Elem().Set(field.Elem())
+	}
+	ok, err := b.bind(target.Elem(), keyPrefix, fieldPrefix)
+	if ok {
+		field.Set(target)
+	}
+	return ok, err
+}
+
+// isNestedStruct reports whether t (or *t) is a struct bound field by field,
+// as opposed to a struct decoded from a single value such as time.Time.
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// nonEmpty drops empty strings so a blank input leaves its field untouched.
+func nonEmpty(values []string) []string {
+	out := values[:0:0]
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// setField assigns values to field. Slices take every value, pointers are
+// allocated, and everything else takes the first value. layout is the
+// time.Parse layout for time.Time fields (RFC 3339 when empty).
+func setField(field reflect.Value, values []string, layout string) error {
+	switch {
+	case field.Type() == timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, values[0])
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case field.Addr().Type().Implements(textUnmarshalerType):
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0]))
+	}
+
+	switch field.Kind() {
+	case reflect.Pointer:
+		target := reflect.New(field.Type().Elem())
+		if err := setField(target.Elem(), values, layout); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setField(slice.Index(i), []string{v}, layout); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setFormField(field, values[0])
+}
+
+// setFormField converts raw and assigns it to a scalar struct field.
+func setFormField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type")
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package thttp_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/poteto0/takibi/thttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Request_BindAll(t *testing.T) {
+	type Input struct {
+		ID      int     `param:"id"`
+		Page    int     `query:"page"`
+		Tenant  string  `header:"X-Tenant"`
+		Session string  `cookie:"sid"`
+		Name    string  `json:"name" form:"name"`
+		Score   float64 `json:"score" form:"score"`
+	}
+
+	t.Run("binds every source from a json request", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest("POST", "http://example.com/users/7?page=2", strings.NewReader(`{"name":"alice","score":9.5}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant", "acme")
+		req.AddCookie(&http.Cookie{Name: "sid", Value: "s3cr3t"})
+		r := thttp.NewRequest(req, nil)
+		r.SetParams(map[string]string{"id": "7"})
+
+		// Act
+		var in Input
+		err := r.BindAll(&in)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, Input{ID: 7, Page: 2, Tenant: "acme", Session: "s3cr3t", Name: "alice", Score: 9.5}, in)
+	})
+
+	t.Run("binds the form body by form tag", func(t *testing.T) {
+		// Arrange
+		form := url.Values{"name": {"bob"}, "score": {"1.5"}}
+		req := httptest.NewRequest("POST", "http://example.com/?page=3", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r := thttp.NewRequest(req, nil)
+
+		// Act
+		var in Input
+		err := r.BindAll(&in)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "bob", in.Name)
+		assert.Equal(t, 1.5, in.Score)
+		assert.Equal(t, 3, in.Page)
+	})
+
+	t.Run("body cannot set fields of other sources", func(t *testing.T) {
+		type Admin struct {
+			Name    string `json:"name" form:"name"`
+			Role    string `header:"X-Role"`
+			Account int    `param:"account"`
+		}
+		bodies := map[string]struct{ contentType, body string }{
+			"json": {"application/json", `{"name":"eve","role":"admin","Account":1}`},
+			"form": {"application/x-www-form-urlencoded", "name=eve&Role=admin&Account=1"},
+		}
+		for name, tt := range bodies {
+			t.Run(name, func(t *testing.T) {
+				// Arrange
+				req := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", tt.contentType)
+				r := thttp.NewRequest(req, nil)
+
+				// Act
+				in := Admin{Account: 7}
+				err := r.BindAll(&in)
+
+				// Assert
+				assert.NoError(t, err)
+				assert.Equal(t, Admin{Name: "eve", Account: 7}, in)
+			})
+		}
+	})
+
+	t.Run("body cannot set nested fields of other sources", func(t *testing.T) {
+		type Inner struct {
+			Tenant string `header:"X-Tenant"`
+			Note   string `json:"note" form:"note"`
+		}
+		type Outer struct {
+			Inner Inner
+			Ptr   *Inner
+			List  []Inner
+		}
+		bodies := map[string]struct{ contentType, body string }{
+			"json": {"application/json", `{"Inner":{"Tenant":"evil","note":"a"},"Ptr":{"Tenant":"evil"},"List":[{"Tenant":"evil"}]}`},
+			"form": {"application/x-www-form-urlencoded", "Inner.Tenant=evil&Inner.note=a&Ptr.Tenant=evil"},
+		}
+		for name, tt := range bodies {
+			t.Run(name, func(t *testing.T) {
+				// Arrange
+				req := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", tt.contentType)
+				r := thttp.NewRequest(req, nil)
+
+				// Act
+				var in Outer
+				err := r.BindAll(&in)
+
+				// Assert
+				assert.NoError(t, err)
+				assert.Equal(t, Inner{Note: "a"}, in.Inner)
+				if in.Ptr != nil {
+					assert.Empty(t, in.Ptr.Tenant)
+				}
+				for _, item := range in.List {
+					assert.Empty(t, item.Tenant)
+				}
+			})
+		}
+	})
+
+	t.Run("request without body skips the body step", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest("GET", "http://example.com/?page=1", nil)
+		req.Header.Set("Content-Type", "application/json")
+		r := thttp.NewRequest(req, nil)
+
+		// Act
+		var in Input
+		err := r.BindAll(&in)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, in.Page)
+		assert.Equal(t, "", in.Name)
+	})
+
+	t.Run("conversion failure carries source and field", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			setup  func(*http.Request, *thttp.Request)
+			source string
+			field  string
+		}{
+			{"param", func(_ *http.Request, r *thttp.Request) {
+				r.SetParams(map[string]string{"id": "abc"})
+			}, thttp.BindSourceParam, "ID"},
+			{"query", func(req *http.Request, _ *thttp.Request) {
+				req.URL.RawQuery = "page=abc"
+			}, thttp.BindSourceQuery, "Page"},
+		}
+		for _, tt := range cases {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest("GET", "http://example.com/", nil)
+				r := thttp.NewRequest(req, nil)
+				tt.setup(req, r)
+
+				err := r.BindAll(&Input{})
+
+				var bindErr *thttp.BindError
+				assert.True(t, errors.As(err, &bindErr))
+				assert.Equal(t, tt.source, bindErr.Source)
+				assert.Equal(t, tt.field, bindErr.Field)
+			})
+		}
+	})
+
+	t.Run("json type mismatch carries json source and field", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(`{"score":"high"}`))
+		req.Header.Set("Content-Type", "application/json")
+		r := thttp.NewRequest(req, nil)
+
+		// Act
+		err := r.BindAll(&Input{})
+
+		// Assert
+		var bindErr *thttp.BindError
+		assert.True(t, errors.As(err, &bindErr))
+		assert.Equal(t, thttp.BindSourceJson, bindErr.Source)
+		assert.Equal(t, "score", bindErr.Field)
+	})
+
+	t.Run("non-pointer dest is error", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		r := thttp.NewRequest(req, nil)
+
+		assert.Error(t, r.BindAll(Input{}))
+	})
+}
+
+func Test_Request_BindFrom(t *testing.T) {
+	type Input struct {
+		ID     int    `param:"id"`
+		Tenant string `header:"X-Tenant"`
+	}
+
+	t.Run("binds only the requested source", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("X-Tenant", "acme")
+		r := thttp.NewRequest(req, nil)
+		r.SetParams(map[string]string{"id": "7"})
+
+		// Act
+		var in Input
+		err := r.BindFrom(thttp.BindSourceHeader, &in)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, Input{Tenant: "acme"}, in)
+	})
+
+	t.Run("unknown source is error", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		r := thttp.NewRequest(req, nil)
+
+		assert.Error(t, r.BindFrom(thttp.BindSourceJson, &Input{}))
+	})
+}
+
+type level string
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low", "high":
+		*l = level(text)
+		return nil
+	}
+	return fmt.Errorf("unknown level %q", text)
+}
+
+func Test_Request_UnmarshallForm_Rich(t *testing.T) {
+	type Address struct {
+		City string `form:"city"`
+		Zip  uint16 `form:"zip"`
+	}
+	type Profile struct {
+		Tags     []string  `form:"tags"`
+		Scores   []int     `form:"scores"`
+		Home     Address   `form:"home"`
+		Work     *Address  `form:"work"`
+		Nickname *string   `form:"nickname"`
+		Born     time.Time `form:"born" layout:"2006-01-02"`
+		Seen     time.Time `form:"seen"`
+		Level    level     `form:"level"`
+		Count    uint      `form:"count"`
+	}
+
+	post := func(form url.Values) *thttp.Request {
+		req := httptest.NewRequest("POST", "http://example.com", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return thttp.NewRequest(req, nil)
+	}
+
+	t.Run("binds slices, nested structs, pointers, time and text unmarshalers", func(t *testing.T) {
+		// Arrange
+		r := post(url.Values{
+			"tags":       {"go", "web"},
+			"scores[]":   {"1", "2"},
+			"home.city":  {"Tokyo"},
+			"home[zip]":  {"100"},
+			"work[city]": {"Osaka"},
+			"nickname":   {"poteto"},
+			"born":       {"2000-01-02"},
+			"seen":       {"2024-05-06T07:08:09Z"},
+			"level":      {"high"},
+			"count":      {"42"},
+		})
+
+		// Act
+		var in Profile
+		err := r.UnmarshallForm(&in)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"go", "web"}, in.Tags)
+		assert.Equal(t, []int{1, 2}, in.Scores)
+		assert.Equal(t, Address{City: "Tokyo", Zip: 100}, in.Home)
+		if assert.NotNil(t, in.Work) {
+			assert.Equal(t, "Osaka", in.Work.City)
+		}
+		if assert.NotNil(t, in.Nickname) {
+			assert.Equal(t, "poteto", *in.Nickname)
+		}
+		assert.Equal(t, time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC), in.Born)
+		assert.Equal(t, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), in.Seen)
+		assert.Equal(t, level("high"), in.Level)
+		assert.Equal(t, uint(42), in.Count)
+	})
+
+	t.Run("absent optional fields stay nil", func(t *testing.T) {
+		// Arrange
+		r := post(url.Values{"tags": {"go"}})
+
+		// Act
+		var in Profile
+		err := r.UnmarshallForm(&in)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Nil(t, in.Work)
+		assert.Nil(t, in.Nickname)
+	})
+
+	t.Run("conversion failure reports the nested field path", func(t *testing.T) {
+		cases := map[string]struct {
+			form  url.Values
+			field string
+		}{
+			"nested uint overflow": {url.Values{"home.zip": {"70000"}}, "Home.Zip"},
+			"negative uint":        {url.Values{"count": {"-1"}}, "Count"},
+			"bad slice element":    {url.Values{"scores": {"1", "x"}}, "Scores"},
+			"bad time layout":      {url.Values{"born": {"02/01/2000"}}, "Born"},
+			"text unmarshaler":     {url.Values{"level": {"extreme"}}, "Level"},
+		}
+		for name, tt := range cases {
+			t.Run(name, func(t *testing.T) {
+				err := post(tt.form).UnmarshallForm(&Profile{})
+
+				var bindErr *thttp.BindError
+				assert.True(t, errors.As(err, &bindErr))
+				assert.Equal(t, thttp.BindSourceForm, bindErr.Source)
+				assert.Equal(t, tt.field, bindErr.Field)
+			})
+		}
+	})
+
+	t.Run("self-referential types only descend into present keys", func(t *testing.T) {
+		type Node struct {
+			Name  string `form:"name"`
+			Child *Node  `form:"child"`
+			Next  *Node  `form:"next"`
+		}
+
+		var empty Node
+		assert.NoError(t, post(url.Values{}).UnmarshallForm(&empty))
+		assert.Equal(t, Node{}, empty)
+
+		var in Node
+		err := post(url.Values{"name": {"root"}, "child[child][name]": {"leaf"}}).UnmarshallForm(&in)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "root", in.Name)
+		if assert.NotNil(t, in.Child) && assert.NotNil(t, in.Child.Child) {
+			assert.Equal(t, "leaf", in.Child.Child.Name)
+			assert.Nil(t, in.Child.Child.Child)
+		}
+		assert.Nil(t, in.Next)
+	})
+
+	t.Run("multipart file parts bind to file header fields", func(t *testing.T) {
+		type Upload struct {
+			Title       string                  `form:"title"`
+			Avatar      *multipart.FileHeader   `form:"avatar"`
+			Attachments []*multipart.FileHeader `form:"attachments"`
+		}
+
+		// Arrange
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		_ = mw.WriteField("title", "hello")
+		for _, f := range []struct{ field, name string }{
+			{"avatar", "me.png"},
+			{"attachments", "a.txt"},
+			{"attachments", "b.txt"},
+		} {
+			part, _ := mw.CreateFormFile(f.field, f.name)
+			_, _ = part.Write([]byte("data"))
+		}
+		_ = mw.Close()
+		req := httptest.NewRequest("POST", "http://example.com", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		r := thttp.NewRequest(req, nil)
+
+		// Act
+		var in Upload
+		err := r.UnmarshallForm(&in)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", in.Title)
+		if assert.NotNil(t, in.Avatar) {
+			assert.Equal(t, "me.png", in.Avatar.Filename)
+		}
+		if assert.Len(t, in.Attachments, 2) {
+			assert.Equal(t, "a.txt", in.Attachments[0].Filename)
+			assert.Equal(t, "b.txt", in.Attachments[1].Filename)
+		}
+	})
+}
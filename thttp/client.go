@@ -0,0 +1,249 @@
+package thttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies parses CIDRs ("10.0.0.0/8") and bare addresses
+// ("192.0.2.1") into the prefixes RequestOption.TrustedProxies expects.
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes, err := ParsePrefixes(entries)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxy %w", err)
+	}
+	return prefixes, nil
+}
+
+// ParsePrefixes parses CIDRs and bare addresses into prefixes, a bare address
+// becoming a single-address prefix. IPv4-mapped IPv6 addresses are unmapped.
+func ParsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		addr = addr.Unmap().WithZone("")
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// client is the resolved origin of a request.
+type client struct {
+	ip     string
+	scheme string
+	host   string
+}
+
+// RealIP returns the client's IP address. Forwarding headers are honored only
+// when the connection comes from a trusted proxy: the address is then the
+// rightmost Forwarded/X-Forwarded-For hop that is not itself a trusted proxy,
+// or CF-Connecting-IP when RequestOption.TrustCFConnectingIP is set. On
+// Workers it is the CF-Connecting-IP set by the Cloudflare edge.
+func (r *Request) RealIP() string {
+	return r.resolveClient().ip
+}
+
+// Scheme returns "https" or "http" as seen by the client, taken from the
+// Forwarded proto or X-Forwarded-Proto of a trusted proxy, else from the
+// connection.
+func (r *Request) Scheme() string {
+	return r.resolveClient().scheme
+}
+
+// Host returns the host the client addressed, taken from the Forwarded host or
+// X-Forwarded-Host of a trusted proxy, else from the Host header.
+func (r *Request) Host() string {
+	return r.resolveClient().host
+}
+
+func (r *Request) resolveClient() *client {
+	if r.client != nil {
+		return r.client
+	}
+	req := r.request
+	c := &client{ip: peerIP(req), scheme: "http", host: req.Host}
+	if req.TLS != nil || strings.EqualFold(req.URL.Scheme, "https") {
+		c.scheme = "https"
+	}
+	if c.host == "" {
+		c.host = req.URL.Host
+	}
+	r.client = c
+
+	if ip, ok := edgeClientIP(req); ok {
+		c.ip = ip
+		return c
+	}
+	peer, err := netip.ParseAddr(c.ip)
+	if err != nil || !r.trusted(peer) {
+		return c
+	}
+
+	if elements := forwardedElements(req.Header.Values("Forwarded")); len(elements) > 0 {
+		hops := make([]string, len(elements))
+		for i, el := range elements {
+			hops[i] = el["for"]
+		}
+		if i, ip, ok := r.clientHop(hops); ok {
+			c.ip = ip
+			c.setScheme(elements[i]["proto"])
+			c.setHost(elements[i]["host"])
+		}
+		r.setCFConnectingIP(c)
+		return c
+	}
+	if _, ip, ok := r.clientHop(headerList(req.Header.Values("X-Forwarded-For"))); ok {
+		c.ip = ip
+	}
+	r.setCFConnectingIP(c)
+
+	if protos := headerList(req.Header.Values("X-Forwarded-Proto")); len(protos) > 0 {
+		c.setScheme(protos[len(protos)-1])
+	}
+	if hosts := headerList(req.Header.Values("X-Forwarded-Host")); len(hosts) > 0 {
+		c.setHost(hosts[len(hosts)-1])
+	}
+	return c
+}
+
+// clientHop walks hops from the right, skipping trusted proxies, and returns
+// the index and address of the first hop that is not one. When every hop is
+// trusted it returns the leftmost; an unparsable hop ends the walk at the
+// last trusted one.
+func (r *Request) clientHop(hops []string) (int, string, bool) {
+	index, ip := -1, ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseNode(hops[i])
+		if !ok {
+			break
+		}
+		index, ip = i, addr.String()
+		if !r.trusted(addr) {
+			break
+		}
+	}
+	return index, ip, index >= 0
+}
+
+func (r *Request) trusted(addr netip.Addr) bool {
+	for _, prefix := range r.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// setCFConnectingIP prefers the CF-Connecting-IP of a trusted proxy, which
+// Cloudflare sets to the address of the connection it received. Other
+// proxies forward the header as the client sent it, so it is only honored
+// under TrustCFConnectingIP.
+func (r *Request) setCFConnectingIP(c *client) {
+	if !r.trustCFConnectingIP {
+		return
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.request.Header.Get("CF-Connecting-IP"))); err == nil {
+		c.ip = addr.Unmap().WithZone("").String()
+	}
+}
+
+func (c *client) setScheme(proto string) {
+	switch proto = strings.ToLower(strings.TrimSpace(proto)); proto {
+	case "http", "https":
+		c.scheme = proto
+	}
+}
+
+func (c *client) setHost(host string) {
+	host = strings.TrimSpace(host)
+	if host == "" || strings.ContainsAny(host, " /\\?#@\"") {
+		return
+	}
+	for _, ch := range host {
+		if ch < 0x21 || ch == 0x7f {
+			return
+		}
+	}
+	c.host = host
+}
+
+// peerIP returns the address of the connection's remote end.
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().WithZone("").String()
+	}
+	return host
+}
+
+// parseNode parses a Forwarded node or X-Forwarded-For entry: an IPv4
+// address, an IPv6 address in brackets, either optionally with a port, or a
+// bare IPv6 address.
+func parseNode(node string) (netip.Addr, bool) {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+	if strings.HasPrefix(node, "[") {
+		end := strings.IndexByte(node, ']')
+		if end < 0 {
+			return netip.Addr{}, false
+		}
+		node = node[1:end]
+	} else if strings.Count(node, ":") == 1 {
+		node, _, _ = strings.Cut(node, ":")
+	}
+	addr, err := netip.ParseAddr(node)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// forwardedElements parses RFC 7239 Forwarded header values into one map of
+// lowercased parameter names per element.
+func forwardedElements(values []string) []map[string]string {
+	var elements []map[string]string
+	for _, value := range values {
+		for element := range strings.SplitSeq(value, ",") {
+			params := map[string]string{}
+			for pair := range strings.SplitSeq(element, ";") {
+				name, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				params[strings.ToLower(name)] = strings.Trim(strings.TrimSpace(v), `"`)
+			}
+			elements = append(elements, params)
+		}
+	}
+	return elements
+}
+
+// headerList splits comma-separated header values into trimmed entries.
+func headerList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for entry := range strings.SplitSeq(value, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				list = append(list, entry)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,11 @@
+//go:build !wasm
+
+package thttp
+
+import "net/http"
+
+// edgeClientIP reports the client address set by the hosting platform's edge,
+// which native servers do not have.
+func edgeClientIP(*http.Request) (string, bool) {
+	return "", false
+}
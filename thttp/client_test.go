@@ -0,0 +1,117 @@
+package thttp_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/poteto0/takibi/thttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClientRequest(t *testing.T, remoteAddr string, headers map[string]string, trusted ...string) *thttp.Request {
+	t.Helper()
+	proxies, err := thttp.ParseTrustedProxies(trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	return thttp.NewRequest(r, &thttp.RequestOption{TrustedProxies: proxies})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := thttp.ParseTrustedProxies([]string{"10.1.2.3/8", "192.0.2.1", "2001:db8::/32", "::ffff:198.51.100.7"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", prefixes[0].String())
+	assert.Equal(t, "192.0.2.1/32", prefixes[1].String())
+	assert.Equal(t, "2001:db8::/32", prefixes[2].String())
+	assert.Equal(t, "198.51.100.7/32", prefixes[3].String())
+
+	_, err = thttp.ParseTrustedProxies([]string{"proxy.internal"})
+	assert.ErrorContains(t, err, `trusted proxy "proxy.internal"`)
+}
+
+func TestRequest_RealIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		trusted []string
+		want    string
+	}{
+		{"no proxies", "203.0.113.9:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, nil, "203.0.113.9"},
+		{"untrusted peer", "203.0.113.9:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, []string{"10.0.0.0/8"}, "203.0.113.9"},
+		{"trusted peer", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, []string{"10.0.0.0/8"}, "198.51.100.1"},
+		{"spoofed leftmost hop", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.0.0.3"}, []string{"10.0.0.0/8"}, "198.51.100.1"},
+		{"all hops trusted", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.3"}, []string{"10.0.0.0/8"}, "10.0.0.5"},
+		{"garbage hop", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "198.51.100.1, nonsense, 10.0.0.3"}, []string{"10.0.0.0/8"}, "10.0.0.3"},
+		{"forwarded", "10.0.0.2:5000", map[string]string{"Forwarded": `for=198.51.100.1;proto=https, for="[2001:db8::1]:4711"`}, []string{"10.0.0.0/8"}, "2001:db8::1"},
+		{"forwarded wins over x-forwarded-for", "10.0.0.2:5000", map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "198.51.100.2"}, []string{"10.0.0.0/8"}, "198.51.100.1"},
+		{"forged cf-connecting-ip through trusted proxy", "10.0.0.5:5000", map[string]string{"CF-Connecting-IP": "203.0.113.9", "X-Forwarded-For": "198.51.100.8"}, []string{"10.0.0.0/8"}, "198.51.100.8"},
+		{"cf-connecting-ip from untrusted peer", "203.0.113.9:5000", map[string]string{"CF-Connecting-IP": "198.51.100.7"}, []string{"10.0.0.0/8"}, "203.0.113.9"},
+		{"ipv4-mapped peer", "[::ffff:203.0.113.9]:5000", nil, nil, "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newClientRequest(t, tt.remote, tt.headers, tt.trusted...)
+			assert.Equal(t, tt.want, req.RealIP())
+		})
+	}
+}
+
+func TestRequest_RealIP_TrustCFConnectingIP(t *testing.T) {
+	newRequest := func(remoteAddr string) *thttp.Request {
+		proxies, _ := thttp.ParseTrustedProxies([]string{"173.245.48.0/20"})
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("CF-Connecting-IP", "198.51.100.7")
+		r.Header.Set("X-Forwarded-For", "198.51.100.8")
+		return thttp.NewRequest(r, &thttp.RequestOption{TrustedProxies: proxies, TrustCFConnectingIP: true})
+	}
+
+	assert.Equal(t, "198.51.100.7", newRequest("173.245.48.10:5000").RealIP())
+	assert.Equal(t, "203.0.113.9", newRequest("203.0.113.9:5000").RealIP())
+}
+
+func TestRequest_SchemeAndHost(t *testing.T) {
+	t.Run("from the connection", func(t *testing.T) {
+		req := newClientRequest(t, "203.0.113.9:5000", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example"})
+		assert.Equal(t, "http", req.Scheme())
+		assert.Equal(t, "example.com", req.Host())
+
+		req.Raw().TLS = &tls.ConnectionState{}
+		assert.Equal(t, "https", thttp.NewRequest(req.Raw(), nil).Scheme())
+	})
+
+	t.Run("from x-forwarded headers of a trusted proxy", func(t *testing.T) {
+		req := newClientRequest(t, "10.0.0.2:5000", map[string]string{
+			"X-Forwarded-Proto": "HTTPS",
+			"X-Forwarded-Host":  "app.example.com",
+		}, "10.0.0.0/8")
+		assert.Equal(t, "https", req.Scheme())
+		assert.Equal(t, "app.example.com", req.Host())
+	})
+
+	t.Run("from the client's forwarded element", func(t *testing.T) {
+		req := newClientRequest(t, "10.0.0.2:5000", map[string]string{
+			"Forwarded": `for=198.51.100.1;proto=https;host="app.example.com", for=10.0.0.3;proto=http;host=internal`,
+		}, "10.0.0.0/8")
+		assert.Equal(t, "https", req.Scheme())
+		assert.Equal(t, "app.example.com", req.Host())
+	})
+
+	t.Run("invalid values are ignored", func(t *testing.T) {
+		req := newClientRequest(t, "10.0.0.2:5000", map[string]string{
+			"X-Forwarded-Proto": "javascript",
+			"X-Forwarded-Host":  "evil.example/path",
+		}, "10.0.0.0/8")
+		assert.Equal(t, "http", req.Scheme())
+		assert.Equal(t, "example.com", req.Host())
+	})
+}
@@ -0,0 +1,18 @@
+//go:build wasm
+
+package thttp
+
+import (
+	"net/http"
+	"net/netip"
+)
+
+// edgeClientIP returns the CF-Connecting-IP the Cloudflare edge sets on every
+// request it forwards to a Worker, overwriting any value sent by the client.
+func edgeClientIP(r *http.Request) (string, bool) {
+	addr, err := netip.ParseAddr(r.Header.Get("CF-Connecting-IP"))
+	if err != nil {
+		return "", false
+	}
+	return addr.Unmap().WithZone("").String(), true
+}
@@ -7,26 +7,39 @@ import (
 	"io"
 	"mime"
 	"net/http"
-	"net/url"
-	"reflect"
-	"strconv"
+	"net/netip"
 
 	"github.com/poteto0/takibi/constants"
 )
 
 type RequestOption struct {
 	MaxBodyBytes int64
+	// TrustedProxies lists the proxies whose forwarding headers RealIP,
+	// Scheme and Host honor; see ParseTrustedProxies.
+	TrustedProxies []netip.Prefix
+	// TrustCFConnectingIP lets the CF-Connecting-IP of a trusted proxy
+	// override the forwarded client address; set it only when the trusted
+	// proxies are Cloudflare's.
+	TrustCFConnectingIP bool
 }
 
 type Request struct {
-	request      *http.Request
-	maxBodyBytes int64
+	request             *http.Request
+	maxBodyBytes        int64
+	params              map[string]string
+	trustedProxies      []netip.Prefix
+	trustCFConnectingIP bool
+	client              *client
 }
 
 func NewRequest(r *http.Request, opt *RequestOption) *Request {
 	req := &Request{request: r, maxBodyBytes: constants.DefaultMaxBodyBytes}
-	if opt != nil && opt.MaxBodyBytes > 0 {
-		req.maxBodyBytes = opt.MaxBodyBytes
+	if opt != nil {
+		if opt.MaxBodyBytes > 0 {
+			req.maxBodyBytes = opt.MaxBodyBytes
+		}
+		req.trustedProxies = opt.TrustedProxies
+		req.trustCFConnectingIP = opt.TrustCFConnectingIP
 	}
 	return req
 }
@@ -35,6 +48,16 @@ func (r *Request) Raw() *http.Request {
 	return r.request
 }
 
+func (r *Request) MaxBodyBytes() int64 {
+	return r.maxBodyBytes
+}
+
+// SetParams records the matched path parameters so BindAll can resolve
+// `param` tags. The context forwards its params here on every route match.
+func (r *Request) SetParams(params map[string]string) {
+	r.params = params
+}
+
 func (r *Request) Header() http.Header {
 	return r.request.Header
 }
@@ -81,92 +104,34 @@ func (r *Request) Unmarshall(dest any) error {
 }
 
 // UnmarshallForm binds form values into dest using the `form` struct tag,
-// converting string values to each field's scalar type. urlencoded and
-// multipart/form-data (Value fields) bodies are supported; file parts are
-// out of scope (use validator.FormFile). Missing or empty fields are left as
-// their zero value, so required-field checks belong in the caller.
+// converting string values to each field's type. Besides scalars (including
+// unsigned ints) it fills slices from repeated keys, nested structs keyed as
+// "a.b" or "a[b]", pointer fields, time.Time (parsed with the `layout` tag,
+// RFC 3339 by default) and encoding.TextUnmarshaler fields. For
+// multipart/form-data bodies, *multipart.FileHeader and
+// []*multipart.FileHeader fields receive the uploaded file parts. Missing or
+// empty fields are left as their zero value, so required-field checks belong
+// in the caller. A value that cannot be converted is reported as a
+// *BindError, whose message reads "bind form: field X: ..." where earlier
+// versions returned a plain "field X: ..." error.
 func (r *Request) UnmarshallForm(dest any) error {
+	if err := r.parseForm(); err != nil {
+		return err
+	}
+	return r.bindForm(dest)
+}
+
+// parseForm parses an urlencoded or multipart/form-data body into
+// r.request.Form, rejecting any other content type.
+func (r *Request) parseForm() error {
 	switch r.MediaType() {
 	case "application/x-www-form-urlencoded":
-		if err := r.request.ParseForm(); err != nil {
-			return err
-		}
+		return r.request.ParseForm()
 	case "multipart/form-data":
-		if err := r.request.ParseMultipartForm(r.maxBodyBytes); err != nil {
-			return err
-		}
+		return r.request.ParseMultipartForm(r.maxBodyBytes)
 	default:
 		return fmt.Errorf("unsupported content type: %s", r.ContentType())
 	}
-	return bindForm(r.request.Form, dest)
-}
-
-// bindForm maps form values onto the exported fields of the struct pointed to
-// by dest. Field keys come from the `form` tag (falling back to the field
-// name); a tag of "-" skips the field.
-func bindForm(values url.Values, dest any) error {
-	rv := reflect.ValueOf(dest)
-	if rv.Kind() != reflect.Pointer || rv.IsNil() {
-		return fmt.Errorf("dest must be a non-nil pointer to a struct")
-	}
-	elem := rv.Elem()
-	if elem.Kind() != reflect.Struct {
-		return fmt.Errorf("dest must be a pointer to a struct")
-	}
-
-	elemType := elem.Type()
-	for i := 0; i < elemType.NumField(); i++ {
-		field := elem.Field(i)
-		if !field.CanSet() {
-			continue
-		}
-
-		key := elemType.Field(i).Tag.Get("form")
-		if key == "-" {
-			continue
-		}
-		if key == "" {
-			key = elemType.Field(i).Name
-		}
-
-		raw := values.Get(key)
-		if raw == "" {
-			continue
-		}
-		if err := setFormField(field, raw); err != nil {
-			return fmt.Errorf("field %s: %w", elemType.Field(i).Name, err)
-		}
-	}
-	return nil
-}
-
-// setFormField converts raw and assigns it to a scalar struct field.
-func setFormField(field reflect.Value, raw string) error {
-	switch field.Kind() {
-	case reflect.String:
-		field.SetString(raw)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		i, err := strconv.ParseInt(raw, 10, 64)
-		if err != nil {
-			return err
-		}
-		field.SetInt(i)
-	case reflect.Float32, reflect.Float64:
-		f, err := strconv.ParseFloat(raw, 64)
-		if err != nil {
-			return err
-		}
-		field.SetFloat(f)
-	case reflect.Bool:
-		b, err := strconv.ParseBool(raw)
-		if err != nil {
-			return err
-		}
-		field.SetBool(b)
-	default:
-		return fmt.Errorf("unsupported type")
-	}
-	return nil
 }
 
 func (r *Request) Queries() map[string][]string {
@@ -381,9 +381,9 @@ func Test_Request_UnmarshallForm(t *testing.T) {
 
 	t.Run("unsupported field type with value is error", func(t *testing.T) {
 		type Bad struct {
-			Tags []string `form:"tags"`
+			Meta map[string]string `form:"meta"`
 		}
-		form := url.Values{"tags": {"a"}}
+		form := url.Values{"meta": {"a"}}
 		req := httptest.NewRequest("POST", "http://example.com", strings.NewReader(form.Encode()))
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		r := thttp.NewRequest(req, nil)
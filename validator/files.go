@@ -0,0 +1,130 @@
+package validator
+
+import (
+	"errors"
+	"mime/multipart"
+	"strings"
+	"unicode"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// FilesConstraint declares the validation rules applied to a multi-file field
+// (<input type="file" multiple>) by Files and FilesField. Every part of the
+// field is checked, not just the first.
+type FilesConstraint struct {
+	Field            string   // multipart field name (required)
+	MinCount         int      // reject fewer files than this; 1 makes the field required
+	MaxCount         int      // reject more files than this; 0 disables the check
+	MaxBytes         int64    // per-file size limit; 0 disables the check
+	MaxTotalBytes    int64    // limit on the summed size of all files; 0 disables the check
+	AllowedTypes     []string // permitted (sniffed) Content-Types; empty allows any
+	SanitizeFilename bool     // strip paths and unsafe characters from UploadedFile.Filename
+}
+
+// filesInput carries the validated files and the parsed form to the Files fn.
+type filesInput struct {
+	files []UploadedFile
+	form  *multipart.Form
+}
+
+// Files parses a multipart/form-data body, validates every file part of the
+// field named by c against its constraints, then passes the validated
+// []UploadedFile (in submission order) and the full *multipart.Form to fn. The
+// returned value is stored under TargetFormFile ("formFile").
+//
+// A constraint violation returns a *FileError whose Index points at the
+// offending file (nil for count and total-size violations). WithFileError
+// customizes the response exactly as for File.
+func Files[Bindings any, T any](
+	c FilesConstraint,
+	fn func([]UploadedFile, *multipart.Form, interfaces.IContext[Bindings]) (T, error),
+	opts ...FileOption[Bindings],
+) interfaces.HandlerFunc[Bindings] {
+	var o fileOptions[Bindings]
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return newValidator(TargetFormFile, func(ctx interfaces.IContext[Bindings]) (filesInput, error) {
+		raw := ctx.Req().Raw()
+		if err := raw.ParseMultipartForm(formFileMaxMemory); err != nil {
+			return filesInput{}, err
+		}
+		form := raw.MultipartForm
+		files, err := validateFiles(form, c)
+		if err != nil {
+			var fe *FileError
+			if o.onError != nil && errors.As(err, &fe) {
+				return filesInput{}, o.onError(fe, ctx)
+			}
+			return filesInput{}, err
+		}
+		return filesInput{files: files, form: form}, nil
+	}, func(in filesInput, ctx interfaces.IContext[Bindings]) (T, error) {
+		return fn(in.files, in.form, ctx)
+	})
+}
+
+// FilesField is the no-fn shortcut over Files: it validates the named field
+// and stores the resulting []UploadedFile under TargetFormFile ("formFile"),
+// ready to retrieve with Valid[[]UploadedFile].
+func FilesField[Bindings any](c FilesConstraint, opts ...FileOption[Bindings]) interfaces.HandlerFunc[Bindings] {
+	return Files(c, func(files []UploadedFile, _ *multipart.Form, _ interfaces.IContext[Bindings]) ([]UploadedFile, error) {
+		return files, nil
+	}, opts...)
+}
+
+// validateFiles checks the count and total size of the field's parts, then
+// each part for size and (sniffed) content type.
+func validateFiles(form *multipart.Form, c FilesConstraint) ([]UploadedFile, error) {
+	var headers []*multipart.FileHeader
+	if form != nil {
+		headers = form.File[c.Field]
+	}
+	if len(headers) < c.MinCount {
+		return nil, &FileError{Field: c.Field, Reason: FileErrTooFew}
+	}
+	if c.MaxCount > 0 && len(headers) > c.MaxCount {
+		return nil, &FileError{Field: c.Field, Reason: FileErrTooMany}
+	}
+
+	files := make([]UploadedFile, 0, len(headers))
+	var total int64
+	for i, fh := range headers {
+		file, err := checkFile(c.Field, &i, fh, c.MaxBytes, c.AllowedTypes)
+		if err != nil {
+			return nil, err
+		}
+		total += fh.Size
+		if c.MaxTotalBytes > 0 && total > c.MaxTotalBytes {
+			return nil, &FileError{Field: c.Field, Reason: FileErrTotalTooLarge}
+		}
+		if c.SanitizeFilename {
+			file.Filename = sanitizeFilename(file.Filename)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// sanitizeFilename reduces a client-supplied filename to a safe base name: any
+// directory part (either slash style) is dropped, control characters and
+// characters reserved on common filesystems are removed, and leading dots are
+// trimmed so the result can't be a hidden or relative path. An empty result
+// becomes "file".
+func sanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"|?*`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if name == "" {
+		return "file"
+	}
+	return name
+}
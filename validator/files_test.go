@@ -0,0 +1,155 @@
+package validator_test
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFile struct {
+	name    string
+	content string
+}
+
+func buildMultiFile(t *testing.T, field string, files ...testFile) (*bytes.Buffer, string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for _, f := range files {
+		fw, err := w.CreateFormFile(field, f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body, w.FormDataContentType()
+}
+
+// serveFiles posts files to an app guarded by FilesField(c) and returns the
+// validated files (when the handler ran) and the error seen by OnError.
+func serveFiles(t *testing.T, c validator.FilesConstraint, files ...testFile) ([]validator.UploadedFile, error) {
+	t.Helper()
+	var (
+		got      []validator.UploadedFile
+		captured error
+	)
+	app := takibi.New(&Bindings{})
+	app.OnError(func(c MyContext, err error) error {
+		captured = err
+		return c.Status(http.StatusUnprocessableEntity).Text("err")
+	})
+	app.Post("/upload",
+		validator.FilesField[Bindings](c),
+		func(c MyContext) error {
+			got, _ = validator.Valid[[]validator.UploadedFile](c, validator.TargetFormFile)
+			return c.Text("ok")
+		},
+	)
+
+	body, contentType := buildMultiFile(t, "photos", files...)
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	app.ServeHTTP(httptest.NewRecorder(), req)
+	return got, captured
+}
+
+func TestFilesField_StoresEveryFile(t *testing.T) {
+	got, err := serveFiles(t, validator.FilesConstraint{
+		Field:        "photos",
+		MinCount:     1,
+		MaxCount:     3,
+		AllowedTypes: []string{"image/png"},
+	}, testFile{"a.png", pngBytes()}, testFile{"b.png", pngBytes()})
+
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "a.png", got[0].Filename)
+		assert.Equal(t, "b.png", got[1].Filename)
+		assert.Equal(t, "image/png", got[1].ContentType)
+	}
+}
+
+func TestFilesField_ConstraintViolations(t *testing.T) {
+	png := testFile{"a.png", pngBytes()}
+	text := testFile{"notes.txt", "plain text"}
+
+	tests := []struct {
+		name   string
+		c      validator.FilesConstraint
+		files  []testFile
+		reason string
+		index  *int
+	}{
+		{"too few", validator.FilesConstraint{MinCount: 2}, []testFile{png}, validator.FileErrTooFew, nil},
+		{"too many", validator.FilesConstraint{MaxCount: 1}, []testFile{png, png}, validator.FileErrTooMany, nil},
+		{"per-file size", validator.FilesConstraint{MaxBytes: 12}, []testFile{png, {"big.png", pngBytes() + "xx"}}, validator.FileErrTooLarge, intPtr(1)},
+		{"total size", validator.FilesConstraint{MaxTotalBytes: 20}, []testFile{png, png}, validator.FileErrTotalTooLarge, nil},
+		{"sniffed type", validator.FilesConstraint{AllowedTypes: []string{"image/png"}}, []testFile{png, png, text}, validator.FileErrUnsupportedType, intPtr(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.Field = "photos"
+
+			got, err := serveFiles(t, tt.c, tt.files...)
+
+			assert.Nil(t, got)
+			var fe *validator.FileError
+			if assert.True(t, errors.As(err, &fe)) {
+				assert.Equal(t, "photos", fe.Field)
+				assert.Equal(t, tt.reason, fe.Reason)
+				assert.Equal(t, tt.index, fe.Index)
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int { return &i }
+
+func TestFilesField_SanitizesFilenames(t *testing.T) {
+	got, err := serveFiles(t, validator.FilesConstraint{
+		Field:            "photos",
+		SanitizeFilename: true,
+	}, testFile{`..\..\windows\evil?.png`, pngBytes()}, testFile{"...", pngBytes()})
+
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "evil.png", got[0].Filename)
+		assert.Equal(t, "file", got[1].Filename)
+		assert.Equal(t, `..\..\windows\evil?.png`, got[0].Header.Filename)
+	}
+}
+
+func TestFiles_CustomErrorHandlerSeesIndex(t *testing.T) {
+	app := takibi.New(&Bindings{})
+	app.Post("/upload",
+		validator.FilesField(validator.FilesConstraint{Field: "photos", MaxBytes: 12},
+			validator.WithFileError(func(fe *validator.FileError, c MyContext) error {
+				c.Status(http.StatusRequestEntityTooLarge).Text(fe.Error())
+				return validator.ErrStop
+			}),
+		),
+		func(c MyContext) error { return c.Text("unreachable") },
+	)
+
+	body, contentType := buildMultiFile(t, "photos", testFile{"a.png", pngBytes()}, testFile{"b.png", pngBytes() + "xx"})
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, `file "photos"[1]: too_large`, w.Body.String())
+}
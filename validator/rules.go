@@ -0,0 +1,381 @@
+package validator
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/poteto0/takibi/interfaces"
+)
+
+// FieldError describes one failed `validate` rule. Path is a JSON pointer
+// (RFC 6901) to the offending value, built from `json` tag names (falling
+// back to `form` tags, then the Go field name), e.g. "/items/0/name".
+type FieldError struct {
+	Path  string
+	Rule  string
+	Param string
+}
+
+func (e *FieldError) Error() string {
+	if e.Param == "" {
+		return fmt.Sprintf("%s: failed on %q", e.Path, e.Rule)
+	}
+	return fmt.Sprintf("%s: failed on %q (%s)", e.Path, e.Rule, e.Param)
+}
+
+// ValidationErrors collects every FieldError found in one pass. It is the
+// error Validate (and therefore Unmarshall, UnmarshallForm and Bind) returns
+// when a struct breaks its `validate` rules, so app.OnError can render all of
+// them at once.
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// RuleFunc is a custom named rule. value is the field value (pointers are
+// dereferenced), param is the text after "=" in the tag and c is the request
+// context, so rules can consult ctx.Env(). Return false to report a
+// FieldError for the rule.
+type RuleFunc[Bindings any] func(value any, param string, c interfaces.IContext[Bindings]) bool
+
+// builtinRule reports whether v satisfies the rule. A non-nil error means the
+// rule itself is misconfigured (e.g. a non-numeric min).
+type builtinRule func(v reflect.Value, param string) (bool, error)
+
+var builtinRules = map[string]builtinRule{
+	"required": func(v reflect.Value, _ string) (bool, error) { return !isEmptyValue(v), nil },
+	"min":      compareRule(func(got, want float64) bool { return got >= want }),
+	"max":      compareRule(func(got, want float64) bool { return got <= want }),
+	"len":      compareRule(func(got, want float64) bool { return got == want }),
+	"gt":       compareRule(func(got, want float64) bool { return got > want }),
+	"lt":       compareRule(func(got, want float64) bool { return got < want }),
+	"oneof":    oneOfRule,
+	"email":    stringRule(isEmail),
+	"url":      stringRule(isURL),
+	"uuid":     stringRule(uuidPattern.MatchString),
+	"regexp":   regexpRule,
+}
+
+var (
+	customRulesMu sync.RWMutex
+	customRules   = map[string]any{}
+)
+
+// RegisterRule registers a custom rule usable as `validate:"name"` or
+// `validate:"name=param"`. The rule is bound to the Bindings type it is
+// registered with; using it from an app with different Bindings is reported
+// as a configuration error. Registering an existing name replaces it. Built-in
+// rule names cannot be overridden.
+func RegisterRule[Bindings any](name string, fn RuleFunc[Bindings]) error {
+	if _, ok := builtinRules[name]; ok {
+		return fmt.Errorf("validate: %q is a built-in rule", name)
+	}
+	customRulesMu.Lock()
+	defer customRulesMu.Unlock()
+	customRules[name] = fn
+	return nil
+}
+
+// Validate checks v (a struct or pointer to struct) against its `validate`
+// struct tags and returns ValidationErrors listing every violating field (the
+// first failed rule of each), or nil.
+//
+// Tags are a comma-separated rule list; "omitempty" skips the remaining rules
+// for a zero value. Validate descends into the nested structs and slices of
+// structs of tagged fields only; "dive" tags one without adding rules:
+//
+//	type SignUp struct {
+//	    Name  string `json:"name" validate:"required,min=3"`
+//	    Email string `json:"email" validate:"required,email"`
+//	    Role  string `json:"role" validate:"omitempty,oneof=admin member"`
+//	}
+//
+// Built-in rules: required, min, max, len, gt, lt (length for strings, slices
+// and maps; value for numbers), oneof (space-separated), email, url, uuid and
+// regexp (the pattern must not contain a comma). Misconfigured tags (unknown
+// rule, bad parameter) return a plain error instead of ValidationErrors.
+func Validate[Bindings any](c interfaces.IContext[Bindings], v any) error {
+	rv := reflect.ValueOf(v)
+	seen := map[uintptr]bool{}
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		seen[rv.Pointer()] = true
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := validateStruct(c, rv, "", seen, &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// parsedRule is one entry of a `validate` tag.
+type parsedRule struct {
+	name  string
+	param string
+}
+
+// fieldRules is the cached, parsed form of one struct field.
+type fieldRules struct {
+	index     int
+	name      string // JSON pointer token
+	omitEmpty bool
+	// nested is set for tagged fields, whose structs Validate descends into
+	nested bool
+	rules  []parsedRule
+}
+
+var ruleCache sync.Map // reflect.Type -> []fieldRules
+
+// rulesFor parses (once per type) the validate tags of t's exported fields.
+func rulesFor(t reflect.Type) []fieldRules {
+	if cached, ok := ruleCache.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fr := fieldRules{index: i, name: pointerToken(sf)}
+		for _, part := range strings.Split(sf.Tag.Get("validate"), ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			fr.nested = true
+			switch part {
+			case "omitempty":
+				fr.omitEmpty = true
+				continue
+			case "dive":
+				continue
+			}
+			name, param, _ := strings.Cut(part, "=")
+			fr.rules = append(fr.rules, parsedRule{name: name, param: param})
+		}
+		fields = append(fields, fr)
+	}
+	cached, _ := ruleCache.LoadOrStore(t, fields)
+	return cached.([]fieldRules)
+}
+
+// pointerToken returns the JSON pointer token naming sf.
+func pointerToken(sf reflect.StructField) string {
+	name := sf.Name
+	for _, tag := range []string{"json", "form"} {
+		if v, _, _ := strings.Cut(sf.Tag.Get(tag), ","); v != "" && v != "-" {
+			name = v
+			break
+		}
+	}
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func validateStruct[Bindings any](c interfaces.IContext[Bindings], rv reflect.Value, path string, seen map[uintptr]bool, errs *ValidationErrors) error {
+	for _, fr := range rulesFor(rv.Type()) {
+		field := rv.Field(fr.index)
+		fieldPath := path + "/" + fr.name
+		if err := validateField(c, field, fr, fieldPath, errs); err != nil {
+			return err
+		}
+		if !fr.nested {
+			continue
+		}
+		if err := validateNested(c, field, fieldPath, seen, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateField[Bindings any](c interfaces.IContext[Bindings], field reflect.Value, fr fieldRules, path string, errs *ValidationErrors) error {
+	if fr.omitEmpty && isEmptyValue(field) {
+		return nil
+	}
+	v := field
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	for _, rule := range fr.rules {
+		target := v
+		if rule.name == "required" {
+			// a non-nil pointer satisfies required even if it points at a
+			// zero value: presence is what optional pointer fields encode
+			target = field
+		} else if v.Kind() == reflect.Pointer {
+			// nil optional pointer: only "required" applies
+			continue
+		}
+		ok, err := runRule(c, rule, target)
+		if err != nil {
+			return fmt.Errorf("validate %s: %w", path, err)
+		}
+		if !ok {
+			// one error per field: later rules rarely add information once
+			// an earlier one (typically "required") has failed
+			*errs = append(*errs, &FieldError{Path: path, Rule: rule.name, Param: rule.param})
+			return nil
+		}
+	}
+	return nil
+}
+
+func runRule[Bindings any](c interfaces.IContext[Bindings], rule parsedRule, v reflect.Value) (bool, error) {
+	if fn, ok := builtinRules[rule.name]; ok {
+		return fn(v, rule.param)
+	}
+	customRulesMu.RLock()
+	registered, ok := customRules[rule.name]
+	customRulesMu.RUnlock()
+	if !ok {
+		return false, fmt.Errorf("unknown rule %q", rule.name)
+	}
+	fn, ok := registered.(RuleFunc[Bindings])
+	if !ok {
+		return false, fmt.Errorf("rule %q is registered for different Bindings", rule.name)
+	}
+	var value any
+	if v.IsValid() && v.CanInterface() {
+		value = v.Interface()
+	}
+	return fn(value, rule.param, c), nil
+}
+
+// validateNested descends into struct fields and slices/arrays of structs,
+// visiting each pointer once so cyclic values terminate.
+func validateNested[Bindings any](c interfaces.IContext[Bindings], v reflect.Value, path string, seen map[uintptr]bool, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() || seen[v.Pointer()] {
+			return nil
+		}
+		seen[v.Pointer()] = true
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == reflect.TypeFor[time.Time]() {
+			return nil
+		}
+		return validateStruct(c, v, path, seen, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateNested(c, v.Index(i), path+"/"+strconv.Itoa(i), seen, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Invalid:
+		return true
+	}
+	return v.IsZero()
+}
+
+// compareRule builds a rule comparing the length (strings, slices, maps,
+// arrays) or numeric value of v against the numeric param.
+func compareRule(cmp func(got, want float64) bool) builtinRule {
+	return func(v reflect.Value, param string) (bool, error) {
+		want, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid parameter %q", param)
+		}
+		var got float64
+		switch v.Kind() {
+		case reflect.String:
+			got = float64(utf8.RuneCountInString(v.String()))
+		case reflect.Slice, reflect.Map, reflect.Array:
+			got = float64(v.Len())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			got = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			got = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			got = v.Float()
+		default:
+			return false, fmt.Errorf("unsupported type %s", v.Type())
+		}
+		return cmp(got, want), nil
+	}
+}
+
+// stringRule adapts a string predicate; non-string fields are a config error.
+func stringRule(match func(string) bool) builtinRule {
+	return func(v reflect.Value, _ string) (bool, error) {
+		if v.Kind() != reflect.String {
+			return false, fmt.Errorf("unsupported type %s", v.Type())
+		}
+		return match(v.String()), nil
+	}
+}
+
+func oneOfRule(v reflect.Value, param string) (bool, error) {
+	if !v.IsValid() || !v.CanInterface() {
+		return false, nil
+	}
+	got := fmt.Sprint(v.Interface())
+	for _, want := range strings.Fields(param) {
+		if got == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	regexpCache sync.Map // pattern -> *regexp.Regexp
+)
+
+func regexpRule(v reflect.Value, param string) (bool, error) {
+	if v.Kind() != reflect.String {
+		return false, fmt.Errorf("unsupported type %s", v.Type())
+	}
+	re, ok := regexpCache.Load(param)
+	if !ok {
+		compiled, err := regexp.Compile(param)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", param, err)
+		}
+		re, _ = regexpCache.LoadOrStore(param, compiled)
+	}
+	return re.(*regexp.Regexp).MatchString(v.String()), nil
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
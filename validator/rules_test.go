@@ -0,0 +1,232 @@
+package validator_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/poteto0/takibi"
+	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+type Item struct {
+	Name string `json:"name" validate:"required"`
+	Qty  int    `json:"qty" validate:"min=1,max=10"`
+}
+
+type Order struct {
+	ID       string  `json:"id" validate:"required,uuid"`
+	Email    string  `json:"email" validate:"required,email"`
+	Website  string  `json:"website" validate:"omitempty,url"`
+	Status   string  `json:"status" validate:"oneof=open closed"`
+	Code     string  `json:"code" validate:"len=4,regexp=^[A-Z]+$"`
+	Note     *string `json:"note" validate:"omitempty,max=5"`
+	Items    []Item  `json:"items" validate:"min=1"`
+	internal string
+}
+
+func validOrder() Order {
+	return Order{
+		ID:     "0b8f5d6e-3f0a-4c3e-9d55-8a2f2d9c1e77",
+		Email:  "alice@example.com",
+		Status: "open",
+		Code:   "ABCD",
+		Items:  []Item{{Name: "tea", Qty: 2}},
+	}
+}
+
+func newTestContext() MyContext {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	return takibi.NewContext[Bindings](httptest.NewRecorder(), req, &Bindings{}, nil)
+}
+
+func TestValidate_PassesValidStruct(t *testing.T) {
+	o := validOrder()
+	assert.NoError(t, validator.Validate(newTestContext(), &o))
+}
+
+func TestValidate_ReportsEveryViolationWithJsonPointer(t *testing.T) {
+	note := "too long"
+	o := Order{
+		ID:      "not-a-uuid",
+		Website: "example.com",
+		Status:  "pending",
+		Code:    "abcd",
+		Note:    &note,
+		Items:   []Item{{Name: "tea", Qty: 2}, {Qty: 11}},
+	}
+
+	err := validator.Validate(newTestContext(), o)
+
+	var verrs validator.ValidationErrors
+	assert.True(t, errors.As(err, &verrs))
+	got := map[string]string{}
+	for _, fe := range verrs {
+		got[fe.Path] = fe.Rule
+	}
+	assert.Equal(t, map[string]string{
+		"/id":           "uuid",
+		"/email":        "required",
+		"/website":      "url",
+		"/status":       "oneof",
+		"/code":         "regexp",
+		"/note":         "max",
+		"/items/1/name": "required",
+		"/items/1/qty":  "max",
+	}, got)
+}
+
+type node struct {
+	Name string `json:"name" validate:"required"`
+	Next *node  `json:"next" validate:"dive"`
+}
+
+func TestValidate_DescendsOnlyIntoTaggedFields(t *testing.T) {
+	type Outer struct {
+		Tagged   Item `json:"tagged" validate:"dive"`
+		Untagged Item `json:"untagged"`
+	}
+
+	err := validator.Validate(newTestContext(), Outer{})
+
+	var verrs validator.ValidationErrors
+	assert.True(t, errors.As(err, &verrs))
+	for _, fe := range verrs {
+		assert.True(t, strings.HasPrefix(fe.Path, "/tagged/"), fe.Path)
+	}
+	assert.Len(t, verrs, 2)
+}
+
+func TestValidate_CyclicValueTerminates(t *testing.T) {
+	n := &node{}
+	n.Next = n
+
+	err := validator.Validate(newTestContext(), n)
+
+	var verrs validator.ValidationErrors
+	assert.True(t, errors.As(err, &verrs))
+	assert.Len(t, verrs, 1)
+	assert.Equal(t, "/name", verrs[0].Path)
+}
+
+func TestValidate_EscapesJsonPointerTokens(t *testing.T) {
+	type Odd struct {
+		Value string `json:"a/b~c" validate:"required"`
+	}
+
+	err := validator.Validate(newTestContext(), Odd{})
+
+	var verrs validator.ValidationErrors
+	assert.True(t, errors.As(err, &verrs))
+	assert.Equal(t, "/a~1b~0c", verrs[0].Path)
+}
+
+func TestValidate_MisconfiguredTagIsPlainError(t *testing.T) {
+	type Bad struct {
+		Name string `validate:"min=abc"`
+	}
+	type Unknown struct {
+		Name string `validate:"nope"`
+	}
+
+	for name, v := range map[string]any{"bad param": Bad{}, "unknown rule": Unknown{}} {
+		t.Run(name, func(t *testing.T) {
+			err := validator.Validate(newTestContext(), v)
+
+			var verrs validator.ValidationErrors
+			assert.Error(t, err)
+			assert.False(t, errors.As(err, &verrs))
+		})
+	}
+}
+
+type TenantBindings struct {
+	Tenants []string
+}
+
+type TenantContext = interfaces.IContext[TenantBindings]
+
+func TestRegisterRule_CustomRuleReceivesTypedContext(t *testing.T) {
+	err := validator.RegisterRule("tenant", func(value any, _ string, c TenantContext) bool {
+		s, _ := value.(string)
+		for _, tenant := range c.Env().Tenants {
+			if tenant == s {
+				return true
+			}
+		}
+		return false
+	})
+	assert.NoError(t, err)
+
+	type Req struct {
+		Tenant string `form:"tenant" validate:"required,tenant"`
+	}
+
+	app := takibi.New(&TenantBindings{Tenants: []string{"acme"}})
+	app.OnError(func(c TenantContext, err error) error {
+		var verrs validator.ValidationErrors
+		if errors.As(err, &verrs) {
+			return c.Status(http.StatusUnprocessableEntity).Text(verrs[0].Path)
+		}
+		return c.Status(http.StatusInternalServerError).Text(err.Error())
+	})
+	app.Post("/join",
+		validator.UnmarshallForm(func(in Req, c TenantContext) (Req, error) {
+			return in, nil
+		}),
+		func(c TenantContext) error {
+			return c.Text("welcome")
+		},
+	)
+
+	for tenant, want := range map[string]int{"acme": http.StatusOK, "evil": http.StatusUnprocessableEntity} {
+		form := url.Values{"tenant": {tenant}}
+		req := httptest.NewRequest(http.MethodPost, "/join", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		app.ServeHTTP(w, req)
+
+		assert.Equal(t, want, w.Code, tenant)
+	}
+}
+
+func TestRegisterRule_RejectsBuiltinName(t *testing.T) {
+	err := validator.RegisterRule("email", func(any, string, MyContext) bool { return true })
+	assert.Error(t, err)
+}
+
+func TestUnmarshall_ValidationErrorsReachErrorHandler(t *testing.T) {
+	nextCalled := false
+
+	app := takibi.New(&Bindings{})
+	app.OnError(func(c MyContext, err error) error {
+		var verrs validator.ValidationErrors
+		assert.True(t, errors.As(err, &verrs))
+		return c.Status(http.StatusUnprocessableEntity).Json(verrs)
+	})
+	app.Post("/orders",
+		validator.Unmarshall(func(in Order, c MyContext) (Order, error) {
+			return in, nil
+		}),
+		func(c MyContext) error {
+			nextCalled = true
+			return c.Text("ok")
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"status":"open"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, req)
+
+	assert.False(t, nextCalled)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"Path":"/email"`)
+}
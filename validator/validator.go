@@ -12,6 +12,7 @@ import (
 
 	"github.com/poteto0/takibi/constants"
 	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/thttp"
 )
 
 // ErrStop aliases constants.ErrStop for convenience — return it from a
@@ -21,9 +22,13 @@ var ErrStop = constants.ErrStop
 
 // Target keys used by the built-in validator factories.
 const (
+	TargetBind     = "bind"
+	TargetCookie   = "cookie"
 	TargetForm     = "form"
 	TargetFormFile = "formFile"
+	TargetHeader   = "header"
 	TargetJson     = "json"
+	TargetParam    = "param"
 	TargetQuery    = "query"
 )
 
@@ -87,6 +92,9 @@ const (
 	FileErrRequired        = "required"
 	FileErrTooLarge        = "too_large"
 	FileErrUnsupportedType = "unsupported_type"
+	FileErrTooFew          = "too_few"
+	FileErrTooMany         = "too_many"
+	FileErrTotalTooLarge   = "total_too_large"
 )
 
 // sniffLen is the number of leading bytes http.DetectContentType inspects.
@@ -120,16 +128,23 @@ func (u UploadedFile) Open() (multipart.File, error) {
 	return u.Header.Open()
 }
 
-// FileError is returned by File/FileField when an uploaded file violates a
-// FileConstraint. It flows to app.OnError so the application can render its own
-// error response. Reason is one of the FileErr* constants.
+// FileError is returned by File/FileField (and Files/FilesField) when an
+// uploaded file violates a FileConstraint (or FilesConstraint). It flows to
+// app.OnError so the application can render its own error response. Reason is
+// one of the FileErr* constants.
 type FileError struct {
 	Field  string
 	Reason string
+	// Index is the position of the rejected part of a Files field; it is
+	// nil for single-file fields and for count or total-size violations.
+	Index *int
 }
 
 func (e *FileError) Error() string {
-	return fmt.Sprintf("file %q: %s", e.Field, e.Reason)
+	if e.Index == nil {
+		return fmt.Sprintf("file %q: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("file %q[%d]: %s", e.Field, *e.Index, e.Reason)
 }
 
 // fileInput carries the validated file and the parsed form to the File fn.
@@ -223,22 +238,25 @@ func validateFile(form *multipart.Form, c FileConstraint) (UploadedFile, error)
 		}
 		return UploadedFile{}, nil
 	}
+	return checkFile(c.Field, nil, headers[0], c.MaxBytes, c.AllowedTypes)
+}
 
-	fh := headers[0]
-	if c.MaxBytes > 0 && fh.Size > c.MaxBytes {
-		return UploadedFile{}, &FileError{Field: c.Field, Reason: FileErrTooLarge}
+// checkFile validates one file part for size and (sniffed) content type.
+// index is reported in the FileError when the part is rejected; nil marks a
+// single-file field.
+func checkFile(field string, index *int, fh *multipart.FileHeader, maxBytes int64, allowedTypes []string) (UploadedFile, error) {
+	if maxBytes > 0 && fh.Size > maxBytes {
+		return UploadedFile{}, &FileError{Field: field, Reason: FileErrTooLarge, Index: index}
 	}
-
 	contentType, err := sniffContentType(fh)
 	if err != nil {
 		return UploadedFile{}, err
 	}
-	if len(c.AllowedTypes) > 0 && !slices.Contains(c.AllowedTypes, contentType) {
-		return UploadedFile{}, &FileError{Field: c.Field, Reason: FileErrUnsupportedType}
+	if len(allowedTypes) > 0 && !slices.Contains(allowedTypes, contentType) {
+		return UploadedFile{}, &FileError{Field: field, Reason: FileErrUnsupportedType, Index: index}
 	}
-
 	return UploadedFile{
-		Field:       c.Field,
+		Field:       field,
 		Filename:    fh.Filename,
 		ContentType: contentType,
 		Size:        fh.Size,
@@ -289,10 +307,97 @@ func Query[Bindings any, T any](
 	}, fn)
 }
 
+// Param returns a HandlerFunc that passes the matched path parameters to fn.
+// The returned value is stored under TargetParam ("param").
+func Param[Bindings any, T any](
+	fn func(map[string]string, interfaces.IContext[Bindings]) (T, error),
+) interfaces.HandlerFunc[Bindings] {
+	return newValidator(TargetParam, func(c interfaces.IContext[Bindings]) (map[string]string, error) {
+		return c.Param(), nil
+	}, fn)
+}
+
+// Header returns a HandlerFunc that passes the request headers to fn. The
+// returned value is stored under TargetHeader ("header").
+func Header[Bindings any, T any](
+	fn func(http.Header, interfaces.IContext[Bindings]) (T, error),
+) interfaces.HandlerFunc[Bindings] {
+	return newValidator(TargetHeader, func(c interfaces.IContext[Bindings]) (http.Header, error) {
+		return c.Req().Header(), nil
+	}, fn)
+}
+
+// Cookie returns a HandlerFunc that passes the request cookies to fn as a
+// name -> value map (the first cookie wins when a name repeats). The returned
+// value is stored under TargetCookie ("cookie").
+func Cookie[Bindings any, T any](
+	fn func(map[string]string, interfaces.IContext[Bindings]) (T, error),
+) interfaces.HandlerFunc[Bindings] {
+	return newValidator(TargetCookie, func(c interfaces.IContext[Bindings]) (map[string]string, error) {
+		cookies := c.Req().Raw().Cookies()
+		values := make(map[string]string, len(cookies))
+		for _, ck := range cookies {
+			if _, ok := values[ck.Name]; !ok {
+				values[ck.Name] = ck.Value
+			}
+		}
+		return values, nil
+	}, fn)
+}
+
+// unmarshallFrom builds the typed struct variant for a non-body source: it
+// binds T's fields tagged for source, checks its `validate` tags and stores
+// the value fn returns under target.
+func unmarshallFrom[Bindings any, T any](
+	target, source string,
+	fn func(T, interfaces.IContext[Bindings]) (T, error),
+) interfaces.HandlerFunc[Bindings] {
+	return newValidator(target, func(c interfaces.IContext[Bindings]) (T, error) {
+		var dest T
+		if err := c.Req().BindFrom(source, &dest); err != nil {
+			return dest, err
+		}
+		return dest, Validate(c, &dest)
+	}, fn)
+}
+
+// UnmarshallParam binds the path parameters into a value of type T via
+// `param` struct tags and passes it to fn. The returned value is stored under
+// TargetParam ("param").
+func UnmarshallParam[Bindings any, T any](
+	fn func(T, interfaces.IContext[Bindings]) (T, error),
+) interfaces.HandlerFunc[Bindings] {
+	return unmarshallFrom(TargetParam, thttp.BindSourceParam, fn)
+}
+
+// UnmarshallHeader binds the request headers into a value of type T via
+// `header` struct tags and passes it to fn. The returned value is stored under
+// TargetHeader ("header").
+//
+//	type Auth struct {
+//	    Token  string `header:"Authorization" validate:"required"`
+//	    Tenant string `header:"X-Tenant" validate:"required"`
+//	}
+func UnmarshallHeader[Bindings any, T any](
+	fn func(T, interfaces.IContext[Bindings]) (T, error),
+) interfaces.HandlerFunc[Bindings] {
+	return unmarshallFrom(TargetHeader, thttp.BindSourceHeader, fn)
+}
+
+// UnmarshallCookie binds the request cookies into a value of type T via
+// `cookie` struct tags and passes it to fn. The returned value is stored under
+// TargetCookie ("cookie").
+func UnmarshallCookie[Bindings any, T any](
+	fn func(T, interfaces.IContext[Bindings]) (T, error),
+) interfaces.HandlerFunc[Bindings] {
+	return unmarshallFrom(TargetCookie, thttp.BindSourceCookie, fn)
+}
+
 // Unmarshall returns a HandlerFunc that decodes the JSON request body into a
 // value of type T and passes it to fn. This is the typical typed-body pattern:
-// fn receives a fully populated T rather than a map. The returned value is
-// stored under TargetJson ("json").
+// fn receives a fully populated T rather than a map. T's `validate` tags are
+// checked before fn runs (see Validate); violations are returned as
+// ValidationErrors. The returned value is stored under TargetJson ("json").
 func Unmarshall[Bindings any, T any](
 	fn func(T, interfaces.IContext[Bindings]) (T, error),
 ) interfaces.HandlerFunc[Bindings] {
@@ -301,15 +406,16 @@ func Unmarshall[Bindings any, T any](
 		if err := c.Req().Unmarshall(&dest); err != nil {
 			return dest, err
 		}
-		return dest, nil
+		return dest, Validate(c, &dest)
 	}, fn)
 }
 
 // UnmarshallForm returns a HandlerFunc that binds the form request body
-// (urlencoded or multipart Value fields) into a value of type T via `form`
-// struct tags and passes it to fn. This is the typed-form counterpart of
-// Unmarshall: fn receives a fully populated T rather than url.Values. The
-// returned value is stored under TargetForm ("form").
+// (urlencoded or multipart, including file parts) into a value of type T via
+// `form` struct tags and passes it to fn. This is the typed-form counterpart of
+// Unmarshall: fn receives a fully populated T rather than url.Values. T's
+// `validate` tags are checked before fn runs. The returned value is stored
+// under TargetForm ("form").
 func UnmarshallForm[Bindings any, T any](
 	fn func(T, interfaces.IContext[Bindings]) (T, error),
 ) interfaces.HandlerFunc[Bindings] {
@@ -318,7 +424,24 @@ func UnmarshallForm[Bindings any, T any](
 		if err := c.Req().UnmarshallForm(&dest); err != nil {
 			return dest, err
 		}
-		return dest, nil
+		return dest, Validate(c, &dest)
+	}, fn)
+}
+
+// Bind returns a HandlerFunc that fills a value of type T from the whole
+// request via Req().BindAll — the body plus `param`, `query`, `header` and
+// `cookie` tagged fields — and passes it to fn. Binding failures are returned
+// as *thttp.BindError and `validate` tag violations as ValidationErrors; both
+// flow to app.OnError. The returned value is stored under TargetBind ("bind").
+func Bind[Bindings any, T any](
+	fn func(T, interfaces.IContext[Bindings]) (T, error),
+) interfaces.HandlerFunc[Bindings] {
+	return newValidator(TargetBind, func(c interfaces.IContext[Bindings]) (T, error) {
+		var dest T
+		if err := c.Req().BindAll(&dest); err != nil {
+			return dest, err
+		}
+		return dest, Validate(c, &dest)
 	}, fn)
 }
 
@@ -13,6 +13,7 @@ import (
 
 	"github.com/poteto0/takibi"
 	"github.com/poteto0/takibi/interfaces"
+	"github.com/poteto0/takibi/thttp"
 	"github.com/poteto0/takibi/validator"
 	"github.com/stretchr/testify/assert"
 )
@@ -620,6 +621,8 @@ func TestFileField_TooLarge_ReturnsFileError(t *testing.T) {
 	var fe *validator.FileError
 	assert.True(t, errors.As(captured, &fe))
 	assert.Equal(t, validator.FileErrTooLarge, fe.Reason)
+	assert.Nil(t, fe.Index)
+	assert.EqualError(t, fe, `file "avatar": too_large`)
 }
 
 func TestFileField_UnsupportedType_ReturnsFileError(t *testing.T) {
@@ -789,6 +792,211 @@ func TestFile_CombinesFileAndTextFields(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, w.Code)
 }
 
+type UpdateUser struct {
+	ID     int    `param:"id"`
+	Tenant string `header:"X-Tenant"`
+	Name   string `json:"name"`
+}
+
+func TestBind_StoresBoundData(t *testing.T) {
+	app := takibi.New(&Bindings{})
+	app.Put("/users/:id",
+		validator.Bind(func(in UpdateUser, c MyContext) (UpdateUser, error) {
+			return in, nil
+		}),
+		func(c MyContext) error {
+			in, ok := validator.Valid[UpdateUser](c, validator.TargetBind)
+			assert.True(t, ok)
+			assert.Equal(t, UpdateUser{ID: 7, Tenant: "acme", Name: "bob"}, in)
+			return c.Text("ok")
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodPut, "/users/7", strings.NewReader(`{"name":"bob"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", "acme")
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+}
+
+func TestBind_BindErrorReachesErrorHandler(t *testing.T) {
+	var bindErr *thttp.BindError
+
+	app := takibi.New(&Bindings{})
+	app.OnError(func(c MyContext, err error) error {
+		errors.As(err, &bindErr)
+		return c.Status(http.StatusBadRequest).Text("bad request")
+	})
+	app.Put("/users/:id",
+		validator.Bind(func(in UpdateUser, c MyContext) (UpdateUser, error) {
+			return in, nil
+		}),
+		func(c MyContext) error {
+			return c.Text("unreachable")
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodPut, "/users/abc", nil)
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	if assert.NotNil(t, bindErr) {
+		assert.Equal(t, thttp.BindSourceParam, bindErr.Source)
+		assert.Equal(t, "ID", bindErr.Field)
+	}
+}
+
+func TestParam_StoresValidatedData(t *testing.T) {
+	app := takibi.New(&Bindings{})
+	app.Get("/users/:id",
+		validator.Param(func(p map[string]string, c MyContext) (string, error) {
+			return p["id"], nil
+		}),
+		func(c MyContext) error {
+			id, ok := validator.Valid[string](c, validator.TargetParam)
+			assert.True(t, ok)
+			return c.Text("user " + id)
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, req)
+
+	assert.Equal(t, "user 42", w.Body.String())
+}
+
+func TestHeader_StoresValidatedData(t *testing.T) {
+	app := takibi.New(&Bindings{})
+	app.Get("/me",
+		validator.Header(func(h http.Header, c MyContext) (string, error) {
+			if h.Get("X-Tenant") == "" {
+				c.Status(http.StatusUnauthorized).Text("tenant required")
+				return "", validator.ErrStop
+			}
+			return h.Get("X-Tenant"), nil
+		}),
+		func(c MyContext) error {
+			tenant, ok := validator.Valid[string](c, validator.TargetHeader)
+			assert.True(t, ok)
+			return c.Text(tenant)
+		},
+	)
+
+	for tenant, want := range map[string]int{"acme": http.StatusOK, "": http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		w := httptest.NewRecorder()
+
+		app.ServeHTTP(w, req)
+
+		assert.Equal(t, want, w.Code)
+	}
+}
+
+func TestCookie_StoresValidatedData(t *testing.T) {
+	app := takibi.New(&Bindings{})
+	app.Get("/me",
+		validator.Cookie(func(cookies map[string]string, c MyContext) (string, error) {
+			return cookies["sid"], nil
+		}),
+		func(c MyContext) error {
+			sid, ok := validator.Valid[string](c, validator.TargetCookie)
+			assert.True(t, ok)
+			return c.Text(sid)
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "abc"})
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, req)
+
+	assert.Equal(t, "abc", w.Body.String())
+}
+
+type TenantHeader struct {
+	Tenant string `header:"X-Tenant" validate:"required"`
+	Token  string `header:"Authorization"`
+}
+
+type SessionCookie struct {
+	ID string `cookie:"sid" validate:"required"`
+}
+
+type UserParam struct {
+	ID int `param:"id" validate:"min=1"`
+}
+
+func TestUnmarshallHeaderParamCookie_StoreTypedData(t *testing.T) {
+	app := takibi.New(&Bindings{})
+	app.Get("/users/:id",
+		validator.UnmarshallParam(func(in UserParam, c MyContext) (UserParam, error) {
+			return in, nil
+		}),
+		validator.UnmarshallHeader(func(in TenantHeader, c MyContext) (TenantHeader, error) {
+			return in, nil
+		}),
+		validator.UnmarshallCookie(func(in SessionCookie, c MyContext) (SessionCookie, error) {
+			return in, nil
+		}),
+		func(c MyContext) error {
+			p, _ := validator.Valid[UserParam](c, validator.TargetParam)
+			h, _ := validator.Valid[TenantHeader](c, validator.TargetHeader)
+			ck, _ := validator.Valid[SessionCookie](c, validator.TargetCookie)
+			assert.Equal(t, UserParam{ID: 9}, p)
+			assert.Equal(t, TenantHeader{Tenant: "acme", Token: "Bearer x"}, h)
+			assert.Equal(t, SessionCookie{ID: "abc"}, ck)
+			return c.Text("ok")
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/9", nil)
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Authorization", "Bearer x")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "abc"})
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, req)
+
+	assert.Equal(t, "ok", w.Body.String())
+}
+
+func TestUnmarshallHeader_ValidationErrorReachesErrorHandler(t *testing.T) {
+	app := takibi.New(&Bindings{})
+	app.OnError(func(c MyContext, err error) error {
+		var verrs validator.ValidationErrors
+		assert.True(t, errors.As(err, &verrs))
+		return c.Status(http.StatusBadRequest).Text(verrs[0].Path)
+	})
+	app.Get("/me",
+		validator.UnmarshallHeader(func(in TenantHeader, c MyContext) (TenantHeader, error) {
+			return in, nil
+		}),
+		func(c MyContext) error {
+			return c.Text("unreachable")
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "/Tenant", w.Body.String())
+}
+
 func TestValid_ReturnsZeroWhenMissing(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	w := httptest.NewRecorder()